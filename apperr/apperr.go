@@ -0,0 +1,122 @@
+// Package apperr defines the API's error model: stable machine-readable codes,
+// field-level validation details and RFC 7807 problem+json responses.
+package apperr
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Code is a stable application error code, clients should branch on it rather than on messages
+type Code string
+
+// Error codes
+const (
+	// Request problems
+	CodeInvalidRequest   Code = "INVALID_REQUEST"
+	CodeValidationFailed Code = "VALIDATION_FAILED"
+	CodeUnknownResource  Code = "UNKNOWN_RESOURCE"
+	CodeUnknownArea      Code = "UNKNOWN_AREA"
+	CodeUnknownEventType Code = "UNKNOWN_EVENT_TYPE"
+	CodePayloadTooLarge  Code = "PAYLOAD_TOO_LARGE"
+	CodeInvalidImport    Code = "INVALID_IMPORT"
+	CodeImportRejected   Code = "IMPORT_REJECTED"
+
+	// Authentication
+	CodeUnauthenticated Code = "UNAUTHENTICATED"
+	CodeForbidden       Code = "FORBIDDEN"
+
+	// Missing entities
+	CodeAreaNotFound       Code = "AREA_NOT_FOUND"
+	CodeTruckNotFound      Code = "TRUCK_NOT_FOUND"
+	CodePlanNotFound       Code = "PLAN_NOT_FOUND"
+	CodeAssignmentNotFound Code = "ASSIGNMENT_NOT_FOUND"
+	CodeWebhookNotFound    Code = "WEBHOOK_NOT_FOUND"
+	CodeDeliveryNotFound   Code = "DELIVERY_NOT_FOUND"
+	CodeAPIKeyNotFound     Code = "API_KEY_NOT_FOUND"
+
+	// Conflicts with the current state
+	CodeAreaExists              Code = "AREA_EXISTS"
+	CodeTruckExists             Code = "TRUCK_EXISTS"
+	CodeAssignmentLocked        Code = "ASSIGNMENT_LOCKED"
+	CodeTruckCommitted          Code = "TRUCK_COMMITTED"
+	CodeInvalidStatusTransition Code = "INVALID_STATUS_TRANSITION"
+	CodeConflict                Code = "CONFLICT"
+	CodeReferenceNotFound       Code = "REFERENCE_NOT_FOUND"
+	CodeConstraintViolation     Code = "CONSTRAINT_VIOLATION"
+	CodeIdempotencyKeyReused    Code = "IDEMPOTENCY_KEY_REUSED"
+	CodeIdempotencyInProgress   Code = "IDEMPOTENCY_IN_PROGRESS"
+
+	// Throttling
+	CodeRateLimited Code = "RATE_LIMITED"
+
+	// Server side
+	CodeUnavailable Code = "SERVICE_UNAVAILABLE"
+	CodeInternal    Code = "INTERNAL_ERROR"
+)
+
+// FieldError describes one invalid field, Field uses the JSON name, e.g. "requiredResources[water]"
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// Error is an error that knows how it should be reported to API clients.
+// Cause is logged for server errors but never sent to the client.
+type Error struct {
+	Status  int
+	Code    Code
+	Detail  string
+	Fields  []FieldError
+	Details interface{}
+	Cause   error
+}
+
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Detail, e.Cause)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Detail)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// New returns an error with the given status, code and human readable detail
+func New(status int, code Code, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+// WithDetails attaches structured context, e.g. a rejected import's report
+func (e *Error) WithDetails(details interface{}) *Error {
+	e.Details = details
+	return e
+}
+
+// BadRequest is a malformed request, e.g. an unparsable path or query parameter
+func BadRequest(detail string) *Error {
+	return New(http.StatusBadRequest, CodeInvalidRequest, detail)
+}
+
+// NotFound is a missing entity
+func NotFound(code Code, detail string) *Error {
+	return New(http.StatusNotFound, code, detail)
+}
+
+// Conflict is a request that clashes with the current state
+func Conflict(code Code, detail string) *Error {
+	return New(http.StatusConflict, code, detail)
+}
+
+// Internal hides cause from the client behind detail, e.g. "Failed to create area"
+func Internal(cause error, detail string) *Error {
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Detail: detail, Cause: cause}
+}
+
+// Unavailable is a dependency outage the client may retry, cause is logged but not sent
+func Unavailable(cause error, detail string) *Error {
+	return &Error{Status: http.StatusServiceUnavailable, Code: CodeUnavailable, Detail: detail, Cause: cause}
+}
@@ -0,0 +1,73 @@
+package apperr
+
+import (
+	"database/sql/driver"
+	"errors"
+	"net/http"
+
+	"github.com/lib/pq"
+)
+
+// Postgres error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pqUniqueViolation      = "23505"
+	pqForeignKeyViolation  = "23503"
+	pqCheckViolation       = "23514"
+	pqNotNullViolation     = "23502"
+	pqExclusionViolation   = "23P01"
+	pqSerializationFailure = "40001"
+	pqDeadlockDetected     = "40P01"
+	pqQueryCanceled        = "57014"
+)
+
+// IsUniqueViolation reports whether err is a Postgres unique constraint violation
+func IsUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolation
+}
+
+// Constraint returns the name of the Postgres constraint err violated, if any
+func Constraint(err error) string {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Constraint
+	}
+	return ""
+}
+
+// Database maps a Postgres error to an API error by its SQLSTATE code. Anything unrecognised
+// becomes an internal error with detail as the message, the driver message is only logged.
+func Database(err error, detail string) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	if errors.Is(err, driver.ErrBadConn) {
+		return &Error{Status: http.StatusServiceUnavailable, Code: CodeUnavailable, Detail: "The database is unavailable, try again", Cause: err}
+	}
+
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return Internal(err, detail)
+	}
+
+	switch {
+	case pqErr.Code == pqUniqueViolation:
+		return &Error{Status: http.StatusConflict, Code: CodeConflict, Detail: "A record with the same key already exists", Cause: err}
+	case pqErr.Code == pqForeignKeyViolation:
+		return &Error{Status: http.StatusConflict, Code: CodeReferenceNotFound, Detail: "The request refers to a record that doesn't exist", Cause: err}
+	case pqErr.Code == pqCheckViolation, pqErr.Code == pqNotNullViolation, pqErr.Code == pqExclusionViolation:
+		detail := "The request violates a data constraint"
+		if pqErr.Constraint != "" {
+			detail += ": " + pqErr.Constraint
+		}
+		return &Error{Status: http.StatusUnprocessableEntity, Code: CodeConstraintViolation, Detail: detail, Cause: err}
+	case pqErr.Code.Class() == "22":
+		// Data exceptions, e.g. a value out of range for its column
+		return &Error{Status: http.StatusBadRequest, Code: CodeInvalidRequest, Detail: "The request contains a value the database can't store", Cause: err}
+	case pqErr.Code == pqSerializationFailure, pqErr.Code == pqDeadlockDetected, pqErr.Code == pqQueryCanceled, pqErr.Code.Class() == "08":
+		return &Error{Status: http.StatusServiceUnavailable, Code: CodeUnavailable, Detail: "The database is busy, try again", Cause: err}
+	}
+	return Internal(err, detail)
+}
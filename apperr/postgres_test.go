@@ -0,0 +1,61 @@
+package apperr
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestDatabase(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   Code
+		wantDetail string
+	}{
+		{"unique violation", &pq.Error{Code: "23505"}, http.StatusConflict, CodeConflict, "A record with the same key already exists"},
+		{"foreign key violation", &pq.Error{Code: "23503"}, http.StatusConflict, CodeReferenceNotFound, "The request refers to a record that doesn't exist"},
+		{"check violation names the constraint", &pq.Error{Code: "23514", Constraint: "areas_urgency_check"}, http.StatusUnprocessableEntity, CodeConstraintViolation, "The request violates a data constraint: areas_urgency_check"},
+		{"not null violation", &pq.Error{Code: "23502"}, http.StatusUnprocessableEntity, CodeConstraintViolation, "The request violates a data constraint"},
+		{"exclusion violation", &pq.Error{Code: "23P01"}, http.StatusUnprocessableEntity, CodeConstraintViolation, "The request violates a data constraint"},
+		{"data exception class", &pq.Error{Code: "22003"}, http.StatusBadRequest, CodeInvalidRequest, "The request contains a value the database can't store"},
+		{"serialization failure", &pq.Error{Code: "40001"}, http.StatusServiceUnavailable, CodeUnavailable, "The database is busy, try again"},
+		{"deadlock", &pq.Error{Code: "40P01"}, http.StatusServiceUnavailable, CodeUnavailable, "The database is busy, try again"},
+		{"query canceled", &pq.Error{Code: "57014"}, http.StatusServiceUnavailable, CodeUnavailable, "The database is busy, try again"},
+		{"connection exception class", &pq.Error{Code: "08006"}, http.StatusServiceUnavailable, CodeUnavailable, "The database is busy, try again"},
+		{"other integrity error stays internal", &pq.Error{Code: "23001"}, http.StatusInternalServerError, CodeInternal, "Failed to save"},
+		{"syntax error stays internal", &pq.Error{Code: "42601"}, http.StatusInternalServerError, CodeInternal, "Failed to save"},
+		{"wrapped pq error", fmt.Errorf("failed to save area: %w", &pq.Error{Code: "23505"}), http.StatusConflict, CodeConflict, "A record with the same key already exists"},
+		{"bad connection", driver.ErrBadConn, http.StatusServiceUnavailable, CodeUnavailable, "The database is unavailable, try again"},
+		{"non database error", errors.New("boom"), http.StatusInternalServerError, CodeInternal, "Failed to save"},
+		{"api error passes through", Conflict(CodeAreaExists, "Area ID already exists"), http.StatusConflict, CodeAreaExists, "Area ID already exists"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Database(tt.err, "Failed to save")
+			if got.Status != tt.wantStatus || got.Code != tt.wantCode || got.Detail != tt.wantDetail {
+				t.Errorf("Database() = %d %s %q, want %d %s %q", got.Status, got.Code, got.Detail, tt.wantStatus, tt.wantCode, tt.wantDetail)
+			}
+			if !errors.Is(got, tt.err) && got != tt.err {
+				t.Errorf("Database() lost the cause %v", tt.err)
+			}
+		})
+	}
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	if !IsUniqueViolation(fmt.Errorf("insert: %w", &pq.Error{Code: "23505", Constraint: "trucks_pkey"})) {
+		t.Error("wrapped unique violation not detected")
+	}
+	if IsUniqueViolation(&pq.Error{Code: "23503"}) || IsUniqueViolation(errors.New("23505")) {
+		t.Error("other errors reported as unique violations")
+	}
+	if got := Constraint(&pq.Error{Code: "23505", Constraint: "trucks_pkey"}); got != "trucks_pkey" {
+		t.Errorf("Constraint() = %q", got)
+	}
+}
@@ -0,0 +1,63 @@
+package apperr
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"workship-disaster-api/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContentType is the RFC 7807 media type of error responses
+const ContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem document, Code, RequestID, Errors and Details are extensions
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      Code         `json:"code"`
+	RequestID string       `json:"requestId,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	Details   interface{}  `json:"details,omitempty"`
+}
+
+// From returns err as an *Error, anything unexpected becomes an internal error
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return Internal(err, "An unexpected error occurred")
+}
+
+// Respond aborts the request with err as a problem+json document.
+// Server errors are logged with their cause, which is never sent to the client.
+func Respond(ctx *gin.Context, err error) {
+	appErr := From(err)
+	requestID := ctx.Writer.Header().Get("X-Request-ID")
+
+	if appErr.Status >= http.StatusInternalServerError {
+		logging.FromContext(ctx.Request.Context()).Error("request failed",
+			slog.String("code", string(appErr.Code)),
+			slog.Int("status", appErr.Status),
+			slog.Any("error", appErr),
+		)
+	}
+
+	ctx.Header("Content-Type", ContentType)
+	ctx.AbortWithStatusJSON(appErr.Status, Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(appErr.Status),
+		Status:    appErr.Status,
+		Detail:    appErr.Detail,
+		Instance:  ctx.Request.URL.Path,
+		Code:      appErr.Code,
+		RequestID: requestID,
+		Errors:    appErr.Fields,
+		Details:   appErr.Details,
+	})
+}
@@ -0,0 +1,161 @@
+package apperr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	// Report fields by the names clients send, not the Go struct field names
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(fieldName)
+	}
+}
+
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "form"} {
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// Binding converts an error from ShouldBindJSON or ShouldBindQuery into a client error
+func Binding(err error) *Error {
+	var appErr *Error
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	var timeErr *time.ParseError
+	var numErr *strconv.NumError
+	var tooLarge *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &appErr):
+		return appErr
+	case errors.As(err, &validationErrs):
+		fields := ValidationFields(validationErrs)
+		code := CodeValidationFailed
+		for _, field := range fields {
+			if field.Rule == "resource" {
+				code = CodeUnknownResource
+			}
+		}
+		return &Error{Status: http.StatusBadRequest, Code: code, Detail: "The request has invalid fields", Fields: fields}
+	case errors.As(err, &typeErr):
+		return &Error{Status: http.StatusBadRequest, Code: CodeValidationFailed, Detail: "The request has invalid fields", Fields: []FieldError{{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Param:   typeErr.Type.String(),
+			Message: "must be of type " + typeErr.Type.String(),
+		}}}
+	case errors.As(err, &timeErr):
+		return &Error{Status: http.StatusBadRequest, Code: CodeValidationFailed, Detail: fmt.Sprintf("%q is not an RFC 3339 time", timeErr.Value)}
+	case errors.As(err, &tooLarge):
+		return New(http.StatusRequestEntityTooLarge, CodePayloadTooLarge, fmt.Sprintf("The request body is larger than %d bytes", tooLarge.Limit))
+	case errors.Is(err, io.EOF):
+		return BadRequest("The request body is empty")
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return BadRequest("The request body is not valid JSON")
+	case errors.As(err, &numErr):
+		return BadRequest(fmt.Sprintf("%q is not a valid number", numErr.Num))
+	}
+	return BadRequest(err.Error())
+}
+
+// ValidationFields describes every failed validation rule, err must come from the binding validator
+func ValidationFields(err error) []FieldError {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return nil
+	}
+
+	fields := make([]FieldError, len(validationErrs))
+	for i, fe := range validationErrs {
+		// Drop the struct name, "CreateAreaRequest.requiredResources[water]" becomes "requiredResources[water]"
+		field := fe.Namespace()
+		if _, rest, found := strings.Cut(field, "."); found {
+			field = rest
+		}
+		fields[i] = FieldError{Field: field, Rule: fe.Tag(), Param: fe.Param(), Message: ruleMessage(fe)}
+	}
+	return fields
+}
+
+// Summary joins field errors into one line, e.g. for import reports
+func Summary(fields []FieldError) string {
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		parts[i] = field.Field + " " + field.Message
+	}
+	return strings.Join(parts, "; ")
+}
+
+func ruleMessage(fe validator.FieldError) string {
+	param := fe.Param()
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "required_with":
+		return "is required when " + lowerFirst(param) + " is set"
+	case "required_without":
+		return "is required when " + lowerFirst(param) + " is not set"
+	case "min", "gte":
+		return "must be at least " + param + sizeUnit(fe.Kind())
+	case "max", "lte":
+		return "must be at most " + param + sizeUnit(fe.Kind())
+	case "gt":
+		return "must be greater than " + param + sizeUnit(fe.Kind())
+	case "lt":
+		return "must be less than " + param + sizeUnit(fe.Kind())
+	case "gtefield":
+		return "must be at least " + lowerFirst(param)
+	case "oneof":
+		return "must be one of: " + strings.Join(strings.Fields(param), ", ")
+	case "datetime":
+		if param == "15:04" {
+			return "must be a time of day in HH:MM format"
+		}
+		return "must match the layout " + param
+	case "url", "http_url":
+		return "must be a valid URL"
+	case "resource":
+		return "is not a known resource type"
+	}
+	return "failed the " + fe.Tag() + " rule"
+}
+
+func sizeUnit(kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return " characters"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return " items"
+	}
+	return ""
+}
+
+func lowerFirst(name string) string {
+	if name == "" {
+		return name
+	}
+	runes := []rune(name)
+	runes[0] = unicode.ToLower(runes[0])
+	return string(runes)
+}
@@ -0,0 +1,140 @@
+package apperr
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+type validationRequest struct {
+	Name      string         `json:"name" binding:"required"`
+	Code      string         `json:"code" binding:"omitempty,max=3"`
+	Tags      []string       `json:"tags" binding:"omitempty,min=2"`
+	Count     int            `json:"count" binding:"omitempty,gt=5"`
+	Level     int            `json:"level" binding:"omitempty,lt=3"`
+	MinLevel  int            `form:"minLevel" binding:"omitempty,min=1"`
+	MaxLevel  int            `form:"maxLevel" binding:"omitempty,gtefield=MinLevel"`
+	Start     string         `json:"start" binding:"required_with=End"`
+	End       string         `json:"end"`
+	Fallback  string         `json:"fallback" binding:"required_without=Name"`
+	Status    string         `json:"status" binding:"omitempty,oneof=available busy"`
+	Shift     string         `json:"shift" binding:"omitempty,datetime=15:04"`
+	Day       string         `json:"day" binding:"omitempty,datetime=2006-01-02"`
+	URL       string         `json:"url" binding:"omitempty,url"`
+	Resources map[string]int `json:"resources" binding:"omitempty,dive,keys,resource,endkeys,min=0"`
+	Slug      string         `json:"slug" binding:"omitempty,alpha"`
+}
+
+func init() {
+	// The service package registers the real rule, here only "water" is known
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterValidation("resource", func(fl validator.FieldLevel) bool {
+			return fl.Field().String() == "water"
+		})
+	}
+}
+
+func TestBindingValidationMessages(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*validationRequest)
+		field   string
+		rule    string
+		message string
+	}{
+		{"required", func(r *validationRequest) { r.Name = "" }, "name", "required", "is required"},
+		{"max on a string counts characters", func(r *validationRequest) { r.Code = "ABCD" }, "code", "max", "must be at most 3 characters"},
+		{"min on a slice counts items", func(r *validationRequest) { r.Tags = []string{"a"} }, "tags", "min", "must be at least 2 items"},
+		{"gt", func(r *validationRequest) { r.Count = 1 }, "count", "gt", "must be greater than 5"},
+		{"lt", func(r *validationRequest) { r.Level = 4 }, "level", "lt", "must be less than 3"},
+		{"min on a number has no unit", func(r *validationRequest) { r.MinLevel = -1 }, "minLevel", "min", "must be at least 1"},
+		{"gtefield names the other field", func(r *validationRequest) { r.MinLevel, r.MaxLevel = 4, 2 }, "maxLevel", "gtefield", "must be at least minLevel"},
+		{"required_with", func(r *validationRequest) { r.End = "18:00" }, "start", "required_with", "is required when end is set"},
+		{"required_without", func(r *validationRequest) { r.Name, r.Fallback = "", "" }, "fallback", "required_without", "is required when name is not set"},
+		{"oneof", func(r *validationRequest) { r.Status = "parked" }, "status", "oneof", "must be one of: available, busy"},
+		{"datetime as a time of day", func(r *validationRequest) { r.Shift = "25:00" }, "shift", "datetime", "must be a time of day in HH:MM format"},
+		{"datetime with another layout", func(r *validationRequest) { r.Day = "01/02/2025" }, "day", "datetime", "must match the layout 2006-01-02"},
+		{"url", func(r *validationRequest) { r.URL = "not a url" }, "url", "url", "must be a valid URL"},
+		{"unknown rule", func(r *validationRequest) { r.Slug = "a-1" }, "slug", "alpha", "failed the alpha rule"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := validationRequest{Name: "ok"}
+			tt.modify(&req)
+
+			got := Binding(binding.Validator.ValidateStruct(&req))
+			if got.Status != http.StatusBadRequest || got.Code != CodeValidationFailed {
+				t.Fatalf("Binding() = %d %s, want 400 %s", got.Status, got.Code, CodeValidationFailed)
+			}
+			var field *FieldError
+			for i := range got.Fields {
+				if got.Fields[i].Field == tt.field {
+					field = &got.Fields[i]
+				}
+			}
+			if field == nil || field.Rule != tt.rule || field.Message != tt.message {
+				t.Errorf("fields = %+v, want %s %s %q", got.Fields, tt.field, tt.rule, tt.message)
+			}
+		})
+	}
+}
+
+func TestBindingUnknownResource(t *testing.T) {
+	got := Binding(binding.Validator.ValidateStruct(&validationRequest{Name: "ok", Resources: map[string]int{"watter": 1}}))
+	if got.Code != CodeUnknownResource || len(got.Fields) != 1 {
+		t.Fatalf("Binding() = %s %+v, want %s", got.Code, got.Fields, CodeUnknownResource)
+	}
+	if field := got.Fields[0]; field.Field != "resources[watter]" || field.Message != "is not a known resource type" {
+		t.Errorf("field = %+v, want the map key without the struct name", field)
+	}
+}
+
+func TestBindingErrorTypes(t *testing.T) {
+	var target struct {
+		Population int `json:"population"`
+	}
+	typeErr := json.Unmarshal([]byte(`{"population":"many"}`), &target)
+	syntaxErr := json.Unmarshal([]byte(`{"population":`+"\x00}"), &target)
+	_, timeErr := time.Parse(time.RFC3339, "yesterday")
+	_, numErr := strconv.Atoi("ten")
+	appErr := NotFound(CodeAreaNotFound, "Area not found")
+
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   Code
+		wantDetail string
+	}{
+		{"json type mismatch", typeErr, http.StatusBadRequest, CodeValidationFailed, "The request has invalid fields"},
+		{"json syntax", syntaxErr, http.StatusBadRequest, CodeInvalidRequest, "The request body is not valid JSON"},
+		{"truncated json", io.ErrUnexpectedEOF, http.StatusBadRequest, CodeInvalidRequest, "The request body is not valid JSON"},
+		{"empty body", io.EOF, http.StatusBadRequest, CodeInvalidRequest, "The request body is empty"},
+		{"time", timeErr, http.StatusBadRequest, CodeValidationFailed, `"yesterday" is not an RFC 3339 time`},
+		{"body too large", &http.MaxBytesError{Limit: 1024}, http.StatusRequestEntityTooLarge, CodePayloadTooLarge, "The request body is larger than 1024 bytes"},
+		{"number in a query", numErr, http.StatusBadRequest, CodeInvalidRequest, `"ten" is not a valid number`},
+		{"api error passes through", appErr, http.StatusNotFound, CodeAreaNotFound, "Area not found"},
+		{"anything else", errors.New("unsupported content type"), http.StatusBadRequest, CodeInvalidRequest, "unsupported content type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Binding(tt.err)
+			if got.Status != tt.wantStatus || got.Code != tt.wantCode || got.Detail != tt.wantDetail {
+				t.Errorf("Binding() = %d %s %q, want %d %s %q", got.Status, got.Code, got.Detail, tt.wantStatus, tt.wantCode, tt.wantDetail)
+			}
+		})
+	}
+
+	// The type mismatch names the field and the expected type
+	if fields := Binding(typeErr).Fields; len(fields) != 1 || fields[0].Field != "population" || fields[0].Rule != "type" || !strings.HasSuffix(fields[0].Message, "int") {
+		t.Errorf("fields = %+v", fields)
+	}
+}
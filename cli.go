@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/user"
+	"time"
+
+	"workship-disaster-api/db"
+	"workship-disaster-api/events"
+	"workship-disaster-api/middleware"
+	"workship-disaster-api/models"
+	"workship-disaster-api/service"
+	"workship-disaster-api/simulation"
+)
+
+// runCommand runs a CLI subcommand instead of the server, e.g. "go run . token -role admin"
+func runCommand(args []string) {
+	switch args[0] {
+	case "token":
+		tokenCommand(args[1:])
+	case "import":
+		importCommand(args[1:])
+	case "simulate":
+		simulateCommand(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\nCommands:\n  token     issue an HS256 JWT for local testing\n  import    bulk load areas or trucks from CSV, JSON or GeoJSON\n  simulate  replay a disaster scenario against the assignment engine\n", args[0])
+		os.Exit(2)
+	}
+}
+
+// tokenCommand signs a JWT with AUTH_JWT_HS256_SECRET, so auth can be tried without an identity provider
+func tokenCommand(args []string) {
+	flags := flag.NewFlagSet("token", flag.ExitOnError)
+	subject := flags.String("sub", "local-user", "token subject")
+	role := flags.String("role", "viewer", "viewer, dispatcher or admin")
+	ttl := flags.Duration("ttl", 8*time.Hour, "token lifetime")
+	flags.Parse(args)
+
+	cfg, err := middleware.LoadAuthConfig()
+	if err != nil {
+		fatal("failed to load auth config", err)
+	}
+
+	token, err := middleware.IssueHS256Token(cfg, *subject, *role, *ttl)
+	if err != nil {
+		fatal("failed to issue token", err)
+	}
+	fmt.Println(token)
+}
+
+// importCommand bulk loads a file straight into the database, e.g.
+// "go run . import -entity areas -file areas.csv -dry-run"
+func importCommand(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	entity := flags.String("entity", "", "areas or trucks")
+	path := flags.String("file", "", "file to import")
+	format := flags.String("format", "", "csv, json or geojson, inferred from the file extension when empty")
+	mode := flags.String("mode", models.ImportModeFail, "fail or upsert")
+	dryRun := flags.Bool("dry-run", false, "validate without writing")
+	flags.Parse(args)
+
+	if *path == "" || (*entity != "areas" && *entity != "trucks") {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if *mode != models.ImportModeFail && *mode != models.ImportModeUpsert {
+		fmt.Fprintf(os.Stderr, "unknown mode %q, expected fail or upsert\n", *mode)
+		os.Exit(2)
+	}
+	if *format == "" {
+		*format = service.DetectImportFormat("", *path)
+	}
+
+	file, err := os.Open(*path)
+	if err != nil {
+		fatal("failed to open import file", err)
+	}
+	defer file.Close()
+
+	dbConn, err := db.ConnectPostgres(slog.Default())
+	if err != nil {
+		fatal("failed to connect to postgres", err)
+	}
+	defer dbConn.Close()
+
+	if err := db.RunMigrations(dbConn, slog.Default()); err != nil {
+		fatal("failed to run migrations", err)
+	}
+
+	rdb, err := db.ConnectRedis()
+	if err != nil {
+		fatal("failed to connect to redis", err)
+	}
+	defer rdb.Close()
+
+	webhookConfig, err := service.LoadWebhookConfig()
+	if err != nil {
+		fatal("failed to load webhook config", err)
+	}
+	resourceTypes := service.LoadResourceTypes()
+	service.RegisterResourceValidation(resourceTypes)
+	unknownAreas, err := service.LoadUnknownAreaPolicy()
+	if err != nil {
+		fatal("failed to load unknown area policy", err)
+	}
+	demand, err := service.LoadDemandConfig(resourceTypes)
+	if err != nil {
+		fatal("failed to load demand rules", err)
+	}
+
+	// Imported rows reach live clients and webhooks like any other change
+	publisher := events.NewPublisher(rdb, service.NewWebhookService(dbConn, webhookConfig, slog.Default()))
+	importService := service.NewImportService(dbConn, publisher, unknownAreas, demand)
+
+	actor := "cli"
+	if current, err := user.Current(); err == nil {
+		actor = "cli:" + current.Username
+	}
+	ctx := service.WithAuditActor(context.Background(), service.AuditActor{Actor: actor, Role: models.RoleAdmin})
+
+	opts := models.ImportOptions{Format: *format, Mode: *mode, DryRun: *dryRun}
+	run := importService.ImportAreas
+	if *entity == "trucks" {
+		run = importService.ImportTrucks
+	}
+	result, err := run(ctx, file, opts)
+	if err != nil {
+		fatal("import failed", err)
+	}
+
+	// The server's planner only sees the change on its next run, drop the cached plan so reads replan now
+	if result.Committed {
+		if err := rdb.Del(ctx, service.PlanCacheKey).Err(); err != nil {
+			slog.Warn("failed to clear cached plan", slog.Any("error", err))
+		}
+	}
+
+	output, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(output))
+	if len(result.Errors) > 0 {
+		os.Exit(1)
+	}
+}
+
+// simulateCommand runs a scenario offline, no database needed, e.g.
+// "go run . simulate -scenario simulation/example.yaml -seed 7 -summary"
+func simulateCommand(args []string) {
+	flags := flag.NewFlagSet("simulate", flag.ExitOnError)
+	path := flags.String("scenario", "", "YAML or JSON scenario file")
+	seed := flags.Uint64("seed", 0, "random seed, overrides the scenario's seed when given, 0 included")
+	summary := flags.Bool("summary", false, "print the metrics only, without the timeline, log and area outcomes")
+	flags.Parse(args)
+
+	if *path == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	scenario, err := simulation.LoadScenario(*path)
+	if err != nil {
+		fatal("failed to load scenario", err)
+	}
+	priority, err := service.LoadPriorityConfig()
+	if err != nil {
+		fatal("failed to load priority config", err)
+	}
+	// -seed 0 is a valid seed, only an absent flag falls back to the scenario
+	seedSet := false
+	flags.Visit(func(f *flag.Flag) { seedSet = seedSet || f.Name == "seed" })
+	if !seedSet {
+		*seed = scenario.Seed
+	}
+
+	result, err := simulation.Run(scenario, *seed, priority)
+	if err != nil {
+		fatal("failed to run simulation", err)
+	}
+
+	var out any = result
+	if *summary {
+		out = map[string]any{"scenario": result.Scenario, "seed": result.Seed, "metrics": result.Metrics}
+	}
+	output, _ := json.MarshalIndent(out, "", "  ")
+	fmt.Println(string(output))
+}
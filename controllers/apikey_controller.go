@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"workship-disaster-api/apperr"
+	"workship-disaster-api/middleware"
+	"workship-disaster-api/models"
+	"workship-disaster-api/resp"
+	"workship-disaster-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyController lets admins issue and revoke API keys
+type APIKeyController struct {
+	apiKeyService *service.APIKeyService
+}
+
+// NewAPIKeyController ...
+func NewAPIKeyController(apiKeyService *service.APIKeyService) *APIKeyController {
+	return &APIKeyController{apiKeyService: apiKeyService}
+}
+
+// IssueAPIKey creates a key, the plaintext key is only shown in this response
+func (c *APIKeyController) IssueAPIKey(ctx *gin.Context) {
+	var req models.CreateAPIKeyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		apperr.Respond(ctx, apperr.Binding(err))
+		return
+	}
+
+	createdBy := ""
+	if principal := middleware.CurrentPrincipal(ctx); principal != nil {
+		createdBy = principal.Subject
+	}
+
+	apiKey, err := c.apiKeyService.CreateAPIKey(auditContext(ctx), req, createdBy)
+	if err != nil {
+		apperr.Respond(ctx, apperr.Database(err, "Failed to issue api key"))
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, resp.SuccessResponse{
+		Code:    http.StatusCreated,
+		Message: "API key issued successfully, store it now as it won't be shown again",
+		Data:    apiKey,
+	})
+}
+
+// ListAPIKeys returns every key without secrets
+func (c *APIKeyController) ListAPIKeys(ctx *gin.Context) {
+	keys, err := c.apiKeyService.ListAPIKeys()
+	if err != nil {
+		apperr.Respond(ctx, apperr.Database(err, "Failed to fetch api keys"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp.SuccessResponse{
+		Code:    http.StatusOK,
+		Message: "API keys retrieved successfully",
+		Data:    keys,
+	})
+}
+
+// RevokeAPIKey stops a key from authenticating
+func (c *APIKeyController) RevokeAPIKey(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("keyId"), 10, 64)
+	if err != nil {
+		apperr.Respond(ctx, apperr.BadRequest("Invalid key ID"))
+		return
+	}
+
+	if err := c.apiKeyService.RevokeAPIKey(auditContext(ctx), id); err != nil {
+		if errors.Is(err, service.ErrAPIKeyNotFound) {
+			apperr.Respond(ctx, apperr.NotFound(apperr.CodeAPIKeyNotFound, "Active api key not found"))
+			return
+		}
+		apperr.Respond(ctx, apperr.Database(err, "Failed to revoke api key"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp.SuccessResponse{
+		Code:    http.StatusOK,
+		Message: "API key revoked successfully",
+		Data:    gin.H{"id": id},
+	})
+}
@@ -2,88 +2,85 @@ package controllers
 
 import (
 	"database/sql"
-	"encoding/json"
 	"net/http"
-	"strings"
+	"time"
+	"workship-disaster-api/apperr"
+	"workship-disaster-api/events"
 	"workship-disaster-api/models"
 	"workship-disaster-api/resp"
+	"workship-disaster-api/service"
 
 	"github.com/gin-gonic/gin"
 )
 
 type AreaController struct {
-	db *sql.DB
+	db      *sql.DB
+	areas   *service.AreaService
+	planner *service.PlannerService
 }
 
-func NewAreaController(db *sql.DB) *AreaController {
-	return &AreaController{db: db}
+func NewAreaController(db *sql.DB, areas *service.AreaService, planner *service.PlannerService) *AreaController {
+	return &AreaController{db: db, areas: areas, planner: planner}
+}
+
+// ListAreas handles GET /api/areas, one page at a time, most urgent first by default
+func (c *AreaController) ListAreas(ctx *gin.Context) {
+	var filter models.AreaFilter
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		apperr.Respond(ctx, apperr.Binding(err))
+		return
+	}
+	filter.RequiresResource = splitList(filter.RequiresResource)
+
+	page, err := c.areas.ListAreas(ctx, filter)
+	if err != nil {
+		apperr.Respond(ctx, listError(err, "Failed to fetch areas"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp.SuccessResponse{
+		Code:    http.StatusOK,
+		Message: "Areas retrieved successfully",
+		Data:    page,
+	})
 }
 
 // CreateArea handles the creation of a new area
 func (c *AreaController) CreateArea(ctx *gin.Context) {
 	var req models.CreateAreaRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, resp.ErrorResponse{
-			Code:    http.StatusBadRequest,
-			Message: "Binding error.",
-			Error:   err.Error(),
-		})
+		apperr.Respond(ctx, apperr.Binding(err))
 		return
 	}
 
-	// Check if area ID already exists
-	var exists bool
-	err := c.db.QueryRow("SELECT EXISTS(SELECT 1 FROM areas WHERE area_id = $1)", req.AreaID).Scan(&exists)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, resp.ErrorResponse{
-			Code:    http.StatusInternalServerError,
-			Message: "Failed to check area ID",
-			Error:   err.Error(),
-		})
-		return
-	}
-	if exists {
-		ctx.JSON(http.StatusConflict, resp.ErrorResponse{
-			Code:    http.StatusConflict,
-			Message: "Area ID already exists",
-		})
+	// Derive required resources from the disaster type and population
+	if err := c.areas.EstimateDemand(&req); err != nil {
+		apperr.Respond(ctx, apperr.Binding(err))
 		return
 	}
 
-	// Convert RequiredResources to JSON
-	resourcesJSON, err := json.Marshal(req.RequiredResources)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, resp.ErrorResponse{
-			Code:    http.StatusInternalServerError,
-			Message: "Failed to process required resources",
-			Error:   err.Error(),
-		})
+	// Resolve the arrival window
+	if err := service.ResolveArrivalWindow(&req, time.Now()); err != nil {
+		apperr.Respond(ctx, apperr.Binding(err))
 		return
 	}
 
-	// Insert into database
-	_, err = c.db.Exec(
-		"INSERT INTO areas (area_id, urgency_level, required_resources, time_constraint) VALUES ($1, $2, $3, $4)",
-		req.AreaID, req.UrgencyLevel, resourcesJSON, req.TimeConstraint,
-	)
+	if req.VulnerableGroups == nil {
+		req.VulnerableGroups = map[string]int{}
+	}
 
-	if err != nil {
-		// Check if error is due to duplicate key
-		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
-			ctx.JSON(http.StatusConflict, resp.ErrorResponse{
-				Code:    http.StatusConflict,
-				Message: "Area ID already exists",
-			})
+	if err := c.areas.CreateArea(auditContext(ctx), req); err != nil {
+		// The primary key rejects IDs that already exist
+		if apperr.IsUniqueViolation(err) {
+			apperr.Respond(ctx, apperr.Conflict(apperr.CodeAreaExists, "Area ID already exists"))
 			return
 		}
-		ctx.JSON(http.StatusInternalServerError, resp.ErrorResponse{
-			Code:    http.StatusInternalServerError,
-			Message: "Failed to create area",
-			Error:   err.Error(),
-		})
+		apperr.Respond(ctx, apperr.Database(err, "Failed to create area"))
 		return
 	}
 
+	c.planner.NotifyChange(events.AreaCreated)
+
 	ctx.JSON(http.StatusCreated, resp.SuccessResponse{
 		Code:    http.StatusCreated,
 		Message: "Area created successfully",
@@ -3,8 +3,13 @@ package controllers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 	"time"
+	"workship-disaster-api/apperr"
+	"workship-disaster-api/events"
+	"workship-disaster-api/metrics"
 	"workship-disaster-api/models"
 	"workship-disaster-api/resp"
 	"workship-disaster-api/service"
@@ -17,102 +22,119 @@ import (
 type AssignmentController struct {
 	db                *sql.DB
 	rdb               *redis.Client
+	planner           *service.PlannerService
 	assignmentService *service.AssignmentService
 }
 
 // NewAssignmentController ...
-func NewAssignmentController(db *sql.DB, rdb *redis.Client) *AssignmentController {
-	areaService := service.NewAreaService(db)
-	truckService := service.NewTruckService(db)
-	assignmentService := service.NewAssignmentService(areaService, truckService)
-
+func NewAssignmentController(db *sql.DB, rdb *redis.Client, planner *service.PlannerService) *AssignmentController {
 	return &AssignmentController{
 		db:                db,
 		rdb:               rdb,
-		assignmentService: assignmentService,
+		planner:           planner,
+		assignmentService: planner.Assignments(),
 	}
 }
 
 // CreateAssignment handles the creation of truck assignments for areas
+// An optional planStart query parameter (RFC3339) previews the plan for that time, it isn't
+// saved or cached so confirmations and cached reads keep using the current plan.
 func (c *AssignmentController) CreateAssignment(ctx *gin.Context) {
+	if raw := ctx.Query("planStart"); raw != "" {
+		planStart, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			apperr.Respond(ctx, apperr.BadRequest("Invalid planStart, expected RFC3339 timestamp"))
+			return
+		}
+		assignments, err := c.assignmentService.PreviewAssignments(ctx.Request.Context(), planStart)
+		if err != nil {
+			apperr.Respond(ctx, apperr.Database(err, "Failed to create assignments"))
+			return
+		}
+		ctx.JSON(http.StatusOK, resp.SuccessResponse{
+			Code:    http.StatusOK,
+			Message: "Assignments previewed for planStart, the plan was not saved",
+			Data:    presentAssignments(ctx, assignments),
+		})
+		return
+	}
+
 	// Try to get from cache first
-	cacheKey := "assignments:latest"
-	cachedResult, err := c.rdb.Get(ctx, cacheKey).Result()
+	cacheKey := service.PlanCacheKey
+	cachedResult, err := c.readCache(ctx, cacheKey)
 	if err == nil {
 		var assignments []models.Assignment
 		if err := json.Unmarshal([]byte(cachedResult), &assignments); err == nil {
 			ctx.JSON(http.StatusOK, resp.SuccessResponse{
 				Code:    http.StatusOK,
 				Message: "Assignments retrieved from cache",
-				Data:    assignments,
+				Data:    presentAssignments(ctx, assignments),
 			})
 			return
 		}
 	}
 
-	// If not in cache or error, create new assignments
-	assignments, err := c.assignmentService.CreateAssignments()
+	// If not in cache or error, create a new plan, the planner caches and audits it
+	plan, err := c.planner.Replan(auditContext(ctx), "manual", time.Now())
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, resp.ErrorResponse{
-			Code:    http.StatusInternalServerError,
-			Message: "Failed to create assignments",
-			Error:   err.Error(),
-		})
+		apperr.Respond(ctx, apperr.Database(err, "Failed to create assignments"))
 		return
 	}
 
-	// Cache the new assignments expire time 30 mins
-	if jsonData, err := json.Marshal(assignments); err == nil {
-		c.rdb.Set(ctx, cacheKey, jsonData, 30*time.Minute)
-	}
-
 	ctx.JSON(http.StatusOK, resp.SuccessResponse{
 		Code:    http.StatusOK,
 		Message: "Assignments created successfully",
-		Data:    assignments,
+		Data:    presentAssignments(ctx, plan.Assignments),
 	})
 }
 
 // GetAssignments retrieves the latest assignments from cache
 func (c *AssignmentController) GetAssignments(ctx *gin.Context) {
-	cacheKey := "assignments:latest"
-	cachedResult, err := c.rdb.Get(ctx, cacheKey).Result()
+	cacheKey := service.PlanCacheKey
+	cachedResult, err := c.readCache(ctx, cacheKey)
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, resp.ErrorResponse{
-			Code:    http.StatusNotFound,
-			Message: "No assignments found in cache",
-			Error:   "Cache miss",
-		})
+		apperr.Respond(ctx, apperr.NotFound(apperr.CodePlanNotFound, "No assignments found in cache"))
 		return
 	}
 
 	var assignments []models.Assignment
 	if err := json.Unmarshal([]byte(cachedResult), &assignments); err != nil {
-		ctx.JSON(http.StatusInternalServerError, resp.ErrorResponse{
-			Code:    http.StatusInternalServerError,
-			Message: "Failed to parse cached assignments",
-			Error:   err.Error(),
-		})
+		apperr.Respond(ctx, apperr.Database(err, "Failed to parse cached assignments"))
 		return
 	}
 
 	ctx.JSON(http.StatusOK, resp.SuccessResponse{
 		Code:    http.StatusOK,
 		Message: "Assignments retrieved from cache",
-		Data:    assignments,
+		Data:    presentAssignments(ctx, assignments),
 	})
 }
 
+// readCache reads a cached plan and counts the lookup, a miss returns redis.Nil
+func (c *AssignmentController) readCache(ctx *gin.Context, key string) (string, error) {
+	value, err := c.rdb.Get(ctx, key).Result()
+	switch {
+	case err == redis.Nil:
+		metrics.CacheLookup(key, metrics.CacheMiss)
+	case err != nil:
+		metrics.CacheLookup(key, metrics.CacheError)
+	default:
+		metrics.CacheLookup(key, metrics.CacheHit)
+	}
+	return value, err
+}
+
 // DeleteAssignments clears the assignments from cache
 func (c *AssignmentController) DeleteAssignments(ctx *gin.Context) {
-	cacheKey := "assignments:latest"
-	err := c.rdb.Del(ctx, cacheKey).Err()
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, resp.ErrorResponse{
-			Code:    http.StatusInternalServerError,
-			Message: "Failed to clear assignments cache",
-			Error:   err.Error(),
-		})
+	// Redis can't join the transaction, so the entry is written first and a failed clear leaves
+	// an entry for an attempt that changed nothing rather than a change without one
+	cacheKey := service.PlanCacheKey
+	if err := service.RecordAudit(auditContext(ctx), c.db, service.AuditDelete, "plan_cache", cacheKey, nil, nil); err != nil {
+		apperr.Respond(ctx, apperr.Database(err, "Failed to clear assignments cache"))
+		return
+	}
+	if err := c.rdb.Del(ctx, cacheKey).Err(); err != nil {
+		apperr.Respond(ctx, apperr.Database(err, "Failed to clear assignments cache"))
 		return
 	}
 
@@ -122,3 +144,116 @@ func (c *AssignmentController) DeleteAssignments(ctx *gin.Context) {
 		Data:    nil,
 	})
 }
+
+// GetLatestPlan returns the most recent plan with its diff against the plan before it
+func (c *AssignmentController) GetLatestPlan(ctx *gin.Context) {
+	plan, err := c.planner.LatestPlan(ctx)
+	c.respondPlan(ctx, plan, err)
+}
+
+// GetPlan returns a stored plan by ID
+func (c *AssignmentController) GetPlan(ctx *gin.Context) {
+	planID, err := strconv.ParseInt(ctx.Param("planId"), 10, 64)
+	if err != nil {
+		apperr.Respond(ctx, apperr.BadRequest("Invalid plan ID"))
+		return
+	}
+
+	plan, err := c.planner.GetPlan(ctx, planID)
+	c.respondPlan(ctx, plan, err)
+}
+
+func (c *AssignmentController) respondPlan(ctx *gin.Context, plan *models.Plan, err error) {
+	if errors.Is(err, service.ErrPlanNotFound) {
+		apperr.Respond(ctx, apperr.NotFound(apperr.CodePlanNotFound, "Plan not found"))
+		return
+	}
+	if err != nil {
+		apperr.Respond(ctx, apperr.Database(err, "Failed to fetch plan"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp.SuccessResponse{
+		Code:    http.StatusOK,
+		Message: "Plan retrieved successfully",
+		Data:    presentPlan(ctx, plan),
+	})
+}
+
+// ConfirmAssignment locks the area's assignment from the latest plan so replans keep it
+func (c *AssignmentController) ConfirmAssignment(ctx *gin.Context) {
+	areaID := ctx.Param("areaId")
+
+	plan, err := c.planner.LatestPlan(ctx)
+	if err != nil && !errors.Is(err, service.ErrPlanNotFound) {
+		apperr.Respond(ctx, apperr.Database(err, "Failed to fetch latest plan"))
+		return
+	}
+
+	var planned *models.Assignment
+	if plan != nil {
+		for i := range plan.Assignments {
+			if plan.Assignments[i].AreaID == areaID && plan.Assignments[i].Status == models.AssignmentStatusPlanned {
+				planned = &plan.Assignments[i]
+				break
+			}
+		}
+	}
+	if planned == nil {
+		apperr.Respond(ctx, apperr.NotFound(apperr.CodeAssignmentNotFound, "Area has no planned assignment to confirm in the latest plan"))
+		return
+	}
+
+	if err := c.assignmentService.LockAssignment(auditContext(ctx), plan.ID, *planned); err != nil {
+		switch {
+		case errors.Is(err, service.ErrAssignmentLocked):
+			apperr.Respond(ctx, apperr.Conflict(apperr.CodeAssignmentLocked, "Area already has a confirmed assignment"))
+		case errors.Is(err, service.ErrTruckCommitted):
+			apperr.Respond(ctx, apperr.Conflict(apperr.CodeTruckCommitted, "Truck is already committed to another assignment"))
+		default:
+			apperr.Respond(ctx, apperr.Database(err, "Failed to confirm assignment"))
+		}
+		return
+	}
+
+	planned.Status = models.AssignmentStatusConfirmed
+	c.planner.NotifyChange(events.AssignmentConfirmed)
+
+	ctx.JSON(http.StatusOK, resp.SuccessResponse{
+		Code:    http.StatusOK,
+		Message: "Assignment confirmed successfully",
+		Data:    presentAssignment(ctx, *planned),
+	})
+}
+
+// UpdateAssignmentStatus moves a confirmed assignment to in_transit, delivered or cancelled
+func (c *AssignmentController) UpdateAssignmentStatus(ctx *gin.Context) {
+	areaID := ctx.Param("areaId")
+
+	var req models.UpdateAssignmentStatusRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		apperr.Respond(ctx, apperr.Binding(err))
+		return
+	}
+
+	_, err := c.assignmentService.UpdateLockedStatus(auditContext(ctx), areaID, req.Status)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrAssignmentNotFound):
+			apperr.Respond(ctx, apperr.NotFound(apperr.CodeAssignmentNotFound, "Area has no confirmed assignment"))
+		case errors.Is(err, service.ErrInvalidStatusTransition):
+			apperr.Respond(ctx, apperr.Conflict(apperr.CodeInvalidStatusTransition, "Assignment can't move to status "+req.Status))
+		default:
+			apperr.Respond(ctx, apperr.Database(err, "Failed to update assignment status"))
+		}
+		return
+	}
+
+	c.planner.NotifyChange("assignment." + req.Status)
+
+	ctx.JSON(http.StatusOK, resp.SuccessResponse{
+		Code:    http.StatusOK,
+		Message: "Assignment status updated successfully",
+		Data:    gin.H{areaIDField(ctx): areaID, "status": req.Status},
+	})
+}
@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"time"
+	"workship-disaster-api/apperr"
+	"workship-disaster-api/middleware"
+	"workship-disaster-api/models"
+	"workship-disaster-api/resp"
+	"workship-disaster-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditController exposes the audit trail
+type AuditController struct {
+	auditService *service.AuditService
+}
+
+// NewAuditController ...
+func NewAuditController(auditService *service.AuditService) *AuditController {
+	return &AuditController{auditService: auditService}
+}
+
+// ListAuditEvents returns audit events matching the query filters, ?format=csv downloads them
+func (c *AuditController) ListAuditEvents(ctx *gin.Context) {
+	var filter models.AuditFilter
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		apperr.Respond(ctx, apperr.Binding(err))
+		return
+	}
+	if filter.Limit == 0 {
+		filter.Limit = 100
+	}
+
+	auditEvents, err := c.auditService.ListEvents(ctx, filter)
+	if err != nil {
+		apperr.Respond(ctx, apperr.Database(err, "Failed to fetch audit events"))
+		return
+	}
+
+	if ctx.Query("format") == "csv" {
+		writeAuditCSV(ctx, auditEvents)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp.SuccessResponse{
+		Code:    http.StatusOK,
+		Message: "Audit events retrieved successfully",
+		Data:    auditEvents,
+	})
+}
+
+func writeAuditCSV(ctx *gin.Context, auditEvents []models.AuditEvent) {
+	filename := "audit-" + time.Now().UTC().Format("20060102-150405") + ".csv"
+	ctx.Header("Content-Type", "text/csv; charset=utf-8")
+	ctx.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	ctx.Status(http.StatusOK)
+
+	w := csv.NewWriter(ctx.Writer)
+	w.Write([]string{"id", "occurred_at", "actor", "actor_role", "action", "entity_type", "entity_id", "request_id", "before", "after"})
+	for _, event := range auditEvents {
+		w.Write([]string{
+			strconv.FormatInt(event.ID, 10),
+			event.OccurredAt.UTC().Format(time.RFC3339),
+			event.Actor,
+			event.ActorRole,
+			event.Action,
+			event.EntityType,
+			event.EntityID,
+			event.RequestID,
+			string(event.Before),
+			string(event.After),
+		})
+	}
+	w.Flush()
+}
+
+// auditContext carries the authenticated caller and request ID into services
+func auditContext(ctx *gin.Context) context.Context {
+	actor := service.AuditActor{RequestID: middleware.GetRequestID(ctx)}
+	if principal := middleware.CurrentPrincipal(ctx); principal != nil {
+		actor.Actor = principal.Subject
+		actor.Role = principal.Role
+	} else {
+		actor.Actor = "anonymous"
+	}
+	return service.WithAuditActor(ctx.Request.Context(), actor)
+}
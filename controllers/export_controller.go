@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"workship-disaster-api/apperr"
+	"workship-disaster-api/models"
+	"workship-disaster-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportController renders stored plans for drivers, command posts and GIS tools
+type ExportController struct {
+	planner *service.PlannerService
+	exports *service.ExportService
+}
+
+// NewExportController ...
+func NewExportController(planner *service.PlannerService, exports *service.ExportService) *ExportController {
+	return &ExportController{planner: planner, exports: exports}
+}
+
+// ExportPlan handles GET /api/assignments/plans/{id}/export?format=csv|excel|geojson|html.
+// The plan ID may be "latest", ?tz= sets the timezone of printed and spreadsheet times.
+func (c *ExportController) ExportPlan(ctx *gin.Context) {
+	format := ctx.DefaultQuery("format", service.ExportFormatCSV)
+	if format != service.ExportFormatCSV && format != service.ExportFormatExcel && format != service.ExportFormatGeoJSON && format != service.ExportFormatHTML {
+		apperr.Respond(ctx, apperr.BadRequest("Invalid format, expected csv, excel, geojson or html"))
+		return
+	}
+
+	loc, err := time.LoadLocation(ctx.DefaultQuery("tz", service.DefaultExportTimezone))
+	if err != nil {
+		apperr.Respond(ctx, apperr.BadRequest("Invalid timezone "+ctx.Query("tz")))
+		return
+	}
+
+	var plan *models.Plan
+	if ctx.Param("planId") == "latest" {
+		plan, err = c.planner.LatestPlan(ctx)
+	} else {
+		planID, parseErr := strconv.ParseInt(ctx.Param("planId"), 10, 64)
+		if parseErr != nil {
+			apperr.Respond(ctx, apperr.BadRequest("Invalid plan ID"))
+			return
+		}
+		plan, err = c.planner.GetPlan(ctx, planID)
+	}
+	if errors.Is(err, service.ErrPlanNotFound) {
+		apperr.Respond(ctx, apperr.NotFound(apperr.CodePlanNotFound, "Plan not found"))
+		return
+	}
+	if err != nil {
+		apperr.Respond(ctx, apperr.Database(err, "Failed to fetch plan"))
+		return
+	}
+
+	rows, err := c.exports.Rows(ctx, plan)
+	if err != nil {
+		apperr.Respond(ctx, apperr.Database(err, "Failed to fetch plan details"))
+		return
+	}
+
+	// Render into a buffer so a failure can still be reported as a problem document
+	var body bytes.Buffer
+	var contentType, extension string
+	switch format {
+	case service.ExportFormatCSV, service.ExportFormatExcel:
+		contentType, extension = "text/csv; charset=utf-8", "csv"
+		err = service.WritePlanCSV(&body, plan, rows, loc, format == service.ExportFormatExcel)
+	case service.ExportFormatGeoJSON:
+		contentType, extension = "application/geo+json", "geojson"
+		err = service.WritePlanGeoJSON(&body, plan, rows)
+	case service.ExportFormatHTML:
+		contentType, extension = "text/html; charset=utf-8", "html"
+		err = service.WritePlanHTML(&body, plan, rows, loc)
+	}
+	if err != nil {
+		apperr.Respond(ctx, apperr.Internal(err, "Failed to export plan"))
+		return
+	}
+
+	// The printable sheet opens in the browser, everything else downloads
+	disposition := "attachment"
+	if format == service.ExportFormatHTML {
+		disposition = "inline"
+	}
+	ctx.Header("Content-Disposition", fmt.Sprintf(`%s; filename="plan-%d.%s"`, disposition, plan.ID, extension))
+	ctx.Data(http.StatusOK, contentType, body.Bytes())
+}
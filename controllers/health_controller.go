@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+	"workship-disaster-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthController answers orchestrator probes, both are public and skip the response envelope
+type HealthController struct {
+	health  *service.HealthService
+	started time.Time
+}
+
+// NewHealthController ...
+func NewHealthController(health *service.HealthService) *HealthController {
+	return &HealthController{health: health, started: time.Now()}
+}
+
+// Livez handles GET /livez, the process is alive as long as it can answer.
+// Dependencies are not checked so an outage doesn't get every replica restarted.
+func (c *HealthController) Livez(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(http.StatusOK, gin.H{
+		"status":        service.HealthOK,
+		"uptimeSeconds": time.Since(c.started).Seconds(),
+	})
+}
+
+// Readyz handles GET /readyz, 503 while a critical dependency is down.
+// A degraded service, e.g. with a stuck planner worker, still reports 200.
+func (c *HealthController) Readyz(ctx *gin.Context) {
+	report := c.health.Check(ctx)
+
+	status := http.StatusOK
+	if report.Status == service.HealthDown {
+		status = http.StatusServiceUnavailable
+	}
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(status, report)
+}
@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"workship-disaster-api/apperr"
+	"workship-disaster-api/models"
+	"workship-disaster-api/resp"
+	"workship-disaster-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportSize caps uploaded import files
+const maxImportSize = 10 << 20
+
+// ImportController bulk loads areas and trucks from CSV, JSON and GeoJSON
+type ImportController struct {
+	importService *service.ImportService
+	planner       *service.PlannerService
+}
+
+// NewImportController ...
+func NewImportController(importService *service.ImportService, planner *service.PlannerService) *ImportController {
+	return &ImportController{importService: importService, planner: planner}
+}
+
+// ImportAreas handles POST /api/areas/import
+func (c *ImportController) ImportAreas(ctx *gin.Context) {
+	c.runImport(ctx, c.importService.ImportAreas, "areas.imported")
+}
+
+// ImportTrucks handles POST /api/trucks/import
+func (c *ImportController) ImportTrucks(ctx *gin.Context) {
+	c.runImport(ctx, c.importService.ImportTrucks, "trucks.imported")
+}
+
+type importFunc func(ctx context.Context, r io.Reader, opts models.ImportOptions) (*models.ImportResult, error)
+
+// runImport accepts the file as the raw body or as a multipart "file" field.
+// ?format= overrides the format inferred from the content type or file name,
+// ?mode=upsert replaces existing rows and ?dryRun=true validates without writing.
+func (c *ImportController) runImport(ctx *gin.Context, run importFunc, reason string) {
+	var opts models.ImportOptions
+	if err := ctx.ShouldBindQuery(&opts); err != nil {
+		apperr.Respond(ctx, apperr.Binding(err))
+		return
+	}
+	if opts.Mode == "" {
+		opts.Mode = models.ImportModeFail
+	}
+
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxImportSize)
+	body := io.Reader(ctx.Request.Body)
+	contentType, filename := ctx.ContentType(), ""
+	if contentType == "multipart/form-data" {
+		file, err := ctx.FormFile("file")
+		if err != nil {
+			apperr.Respond(ctx, apperr.BadRequest("Missing import file, send it as the multipart field \"file\""))
+			return
+		}
+		upload, err := file.Open()
+		if err != nil {
+			apperr.Respond(ctx, apperr.BadRequest("Failed to read import file"))
+			return
+		}
+		defer upload.Close()
+		body, contentType, filename = upload, file.Header.Get("Content-Type"), file.Filename
+	}
+
+	if opts.Format == "" {
+		opts.Format = service.DetectImportFormat(contentType, filename)
+	}
+	if opts.Format == "" {
+		apperr.Respond(ctx, apperr.BadRequest("Unknown import format, set ?format=csv, json or geojson"))
+		return
+	}
+
+	result, err := run(auditContext(ctx), body, opts)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			apperr.Respond(ctx, apperr.Binding(tooLarge))
+		case errors.Is(err, service.ErrInvalidImport):
+			// The file itself is unreadable, so its parse error is the useful detail
+			apperr.Respond(ctx, apperr.New(http.StatusBadRequest, apperr.CodeInvalidImport, err.Error()))
+		default:
+			apperr.Respond(ctx, apperr.Database(err, "Failed to import"))
+		}
+		return
+	}
+
+	if len(result.Errors) > 0 {
+		apperr.Respond(ctx, apperr.New(http.StatusUnprocessableEntity, apperr.CodeImportRejected, "Import rejected, no rows were written").WithDetails(result))
+		return
+	}
+
+	message := "Import validated, no rows were written"
+	if result.Committed {
+		message = "Import completed successfully"
+		if result.Total > 0 {
+			c.planner.NotifyChange(reason)
+		}
+	}
+
+	ctx.JSON(http.StatusOK, resp.SuccessResponse{
+		Code:    http.StatusOK,
+		Message: message,
+		Data:    result,
+	})
+}
@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"workship-disaster-api/apperr"
+	"workship-disaster-api/service"
+)
+
+// splitList accepts repeated query parameters as well as comma separated values
+func splitList(values []string) []string {
+	var items []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// listError maps list query errors, a bad sort or cursor is the caller's mistake
+func listError(err error, detail string) *apperr.Error {
+	switch {
+	case errors.Is(err, service.ErrInvalidSort):
+		return &apperr.Error{Status: http.StatusBadRequest, Code: apperr.CodeValidationFailed, Detail: "The request has invalid fields", Fields: []apperr.FieldError{{Field: "sort", Rule: "sort", Message: err.Error()}}}
+	case errors.Is(err, service.ErrInvalidCursor):
+		return &apperr.Error{Status: http.StatusBadRequest, Code: apperr.CodeValidationFailed, Detail: "The request has invalid fields", Fields: []apperr.FieldError{{Field: "cursor", Rule: "cursor", Message: err.Error()}}}
+	}
+	return apperr.Database(err, detail)
+}
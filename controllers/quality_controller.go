@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"net/http"
+	"workship-disaster-api/apperr"
+	"workship-disaster-api/resp"
+	"workship-disaster-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DataQualityController reports reference problems in the stored areas and trucks
+type DataQualityController struct {
+	quality *service.DataQualityService
+}
+
+// NewDataQualityController ...
+func NewDataQualityController(quality *service.DataQualityService) *DataQualityController {
+	return &DataQualityController{quality: quality}
+}
+
+// GetDataQuality handles GET /api/data-quality
+func (c *DataQualityController) GetDataQuality(ctx *gin.Context) {
+	report, err := c.quality.Report(ctx)
+	if err != nil {
+		apperr.Respond(ctx, apperr.Database(err, "Failed to check data quality"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp.SuccessResponse{
+		Code:    http.StatusOK,
+		Message: "Data quality report retrieved successfully",
+		Data:    report,
+	})
+}
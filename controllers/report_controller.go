@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"workship-disaster-api/apperr"
+	"workship-disaster-api/models"
+	"workship-disaster-api/resp"
+	"workship-disaster-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReportController serves situational reports built from the latest plan
+type ReportController struct {
+	coverage *service.CoverageService
+}
+
+// NewReportController ...
+func NewReportController(coverage *service.CoverageService) *ReportController {
+	return &ReportController{coverage: coverage}
+}
+
+// GetCoverage handles GET /api/reports/coverage
+func (c *ReportController) GetCoverage(ctx *gin.Context) {
+	var opts models.CoverageOptions
+	if err := ctx.ShouldBindQuery(&opts); err != nil {
+		apperr.Respond(ctx, apperr.Binding(err))
+		return
+	}
+
+	report, err := c.coverage.Report(ctx, opts)
+	if errors.Is(err, service.ErrPlanNotFound) {
+		apperr.Respond(ctx, apperr.NotFound(apperr.CodePlanNotFound, "No plan has been computed yet"))
+		return
+	}
+	if err != nil {
+		apperr.Respond(ctx, apperr.Database(err, "Failed to build coverage report"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp.SuccessResponse{
+		Code:    http.StatusOK,
+		Message: "Coverage report retrieved successfully",
+		Data:    report,
+	})
+}
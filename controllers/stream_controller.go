@@ -0,0 +1,223 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+	"workship-disaster-api/apperr"
+	"workship-disaster-api/events"
+	"workship-disaster-api/logging"
+	"workship-disaster-api/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	sseHeartbeat = 15 * time.Second
+	wsPingPeriod = 30 * time.Second
+	wsWriteWait  = 10 * time.Second
+)
+
+// StreamController pushes plan, assignment, area and truck events to dispatch screens
+type StreamController struct {
+	hub       *events.Hub
+	publisher *events.Publisher
+	upgrader  websocket.Upgrader
+}
+
+// NewStreamController ...
+// WS_ALLOWED_ORIGINS is a comma separated list of extra origins allowed to open WebSockets.
+func NewStreamController(hub *events.Hub, publisher *events.Publisher) *StreamController {
+	allowed := map[string]bool{}
+	for _, origin := range strings.Split(os.Getenv("WS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			allowed[origin] = true
+		}
+	}
+
+	return &StreamController{
+		hub:       hub,
+		publisher: publisher,
+		upgrader:  websocket.Upgrader{CheckOrigin: checkOrigin(allowed)},
+	}
+}
+
+// checkOrigin allows clients without an Origin, the listed origins and pages served from the
+// API's own host. The host is compared exactly, evil-api.example.com is not api.example.com.
+func checkOrigin(allowed map[string]bool) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" || allowed["*"] || allowed[origin] {
+			return true
+		}
+		parsed, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(parsed.Host, r.Host)
+	}
+}
+
+// StreamSSE streams events as Server-Sent Events, resuming after Last-Event-ID when given
+func (c *StreamController) StreamSSE(ctx *gin.Context) {
+	version := middleware.GetAPIVersion(ctx)
+	lastEventID := ctx.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = ctx.Query("lastEventId")
+	}
+
+	// Subscribe before replaying so nothing published in between is lost
+	live, unsubscribe := c.hub.Subscribe()
+	defer unsubscribe()
+
+	replay, err := c.replay(ctx, lastEventID)
+	if err != nil {
+		apperr.Respond(ctx, apperr.Database(err, "Failed to replay events"))
+		return
+	}
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+	fmt.Fprint(ctx.Writer, "retry: 3000\n\n")
+
+	lastSent := lastEventID
+	for _, event := range replay {
+		if err := writeSSE(ctx, version, event); err != nil {
+			return
+		}
+		lastSent = event.ID
+	}
+	ctx.Writer.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(ctx.Writer, ": keepalive\n\n"); err != nil {
+				return
+			}
+			ctx.Writer.Flush()
+		case event, ok := <-live:
+			if !ok {
+				// Dropped for being too slow, the browser reconnects with Last-Event-ID
+				return
+			}
+			if lastSent != "" && !events.After(event.ID, lastSent) {
+				continue
+			}
+			if err := writeSSE(ctx, version, event); err != nil {
+				return
+			}
+			lastSent = event.ID
+			ctx.Writer.Flush()
+		}
+	}
+}
+
+// StreamWebSocket streams the same events over a WebSocket, lastEventId resumes like SSE
+func (c *StreamController) StreamWebSocket(ctx *gin.Context) {
+	version := middleware.GetAPIVersion(ctx)
+	lastEventID := ctx.Query("lastEventId")
+
+	live, unsubscribe := c.hub.Subscribe()
+	defer unsubscribe()
+
+	replay, err := c.replay(ctx, lastEventID)
+	if err != nil {
+		apperr.Respond(ctx, apperr.Database(err, "Failed to replay events"))
+		return
+	}
+
+	conn, err := c.upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		// Upgrade already wrote the HTTP error
+		return
+	}
+	defer conn.Close()
+
+	// Clients only send control frames, reading detects disconnects
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	lastSent := lastEventID
+	for _, event := range replay {
+		if err := writeWS(conn, version, event); err != nil {
+			return
+		}
+		lastSent = event.ID
+	}
+
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+		case event, ok := <-live:
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "fell behind, reconnect with lastEventId"),
+					time.Now().Add(wsWriteWait))
+				return
+			}
+			if lastSent != "" && !events.After(event.ID, lastSent) {
+				continue
+			}
+			if err := writeWS(conn, version, event); err != nil {
+				return
+			}
+			lastSent = event.ID
+		}
+	}
+}
+
+func (c *StreamController) replay(ctx *gin.Context, lastEventID string) ([]events.Event, error) {
+	if lastEventID == "" {
+		return nil, nil
+	}
+	return c.publisher.Since(ctx, lastEventID)
+}
+
+func writeSSE(ctx *gin.Context, version int, event events.Event) error {
+	payload, err := json.Marshal(presentEvent(version, event))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(ctx.Writer, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, payload)
+	return err
+}
+
+func writeWS(conn *websocket.Conn, version int, event events.Event) error {
+	conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return conn.WriteJSON(presentEvent(version, event))
+}
+
+// broadcastEvents fans out events staged in the committed change without failing the request,
+// webhooks already have them queued
+func broadcastEvents(ctx *gin.Context, publisher *events.Publisher, staged ...events.Event) {
+	if err := publisher.Broadcast(ctx, staged...); err != nil {
+		logging.FromContext(ctx.Request.Context()).Error("failed to broadcast events", slog.Any("error", err))
+	}
+}
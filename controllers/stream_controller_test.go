@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"workship-disaster-api/events"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// TestStreamSSEReplaysThenSkipsDuplicates checks a reconnecting client gets the events after its
+// Last-Event-ID and that a live copy of a replayed event isn't sent twice
+func TestStreamSSEReplaysThenSkipsDuplicates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	publisher := events.NewPublisher(rdb)
+	hub := events.NewHub(rdb, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.Run(ctx)
+	deadline := time.Now().Add(time.Second)
+	for server.PubSubNumSub(events.Channel)[events.Channel] == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("hub did not subscribe")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	broadcast := func(truckID string) {
+		t.Helper()
+		event, _ := publisher.Stage(ctx, nil, events.TruckCreated, map[string]string{"truckId": truckID})
+		if err := publisher.Broadcast(ctx, event); err != nil {
+			t.Fatalf("Broadcast: %v", err)
+		}
+	}
+	broadcast("T1")
+	broadcast("T2")
+	logged, err := publisher.Since(ctx, "0-0")
+	if err != nil || len(logged) != 2 {
+		t.Fatalf("Since = %v, %v", logged, err)
+	}
+
+	r := gin.New()
+	r.GET("/stream", NewStreamController(hub, publisher).StreamSSE)
+	api := httptest.NewServer(r)
+	defer api.Close()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, api.URL+"/stream", nil)
+	req.Header.Set("Last-Event-ID", logged[0].ID)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	ids := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			if id, ok := strings.CutPrefix(scanner.Text(), "id: "); ok {
+				ids <- id
+			}
+		}
+		close(ids)
+	}()
+	next := func() string {
+		t.Helper()
+		select {
+		case id := <-ids:
+			return id
+		case <-time.After(2 * time.Second):
+			t.Fatal("no event received")
+			return ""
+		}
+	}
+
+	if id := next(); id != logged[1].ID {
+		t.Fatalf("replayed %s, want %s", id, logged[1].ID)
+	}
+	// The client subscribed before replaying, so an event published meanwhile also arrives live
+	duplicate, _ := json.Marshal(logged[1])
+	if err := rdb.Publish(ctx, events.Channel, duplicate).Err(); err != nil {
+		t.Fatal(err)
+	}
+	broadcast("T3")
+	if id := next(); !events.After(id, logged[1].ID) {
+		t.Errorf("got %s after the replay, want the new event and not the duplicate", id)
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	check := checkOrigin(map[string]bool{"https://dispatch.example.com": true})
+	tests := []struct {
+		name   string
+		origin string
+		want   bool
+	}{
+		{"no origin", "", true},
+		{"listed", "https://dispatch.example.com", true},
+		{"same host", "https://api.example.com", true},
+		{"same host other case", "https://API.example.com", true},
+		{"suffix of another host", "https://evil-api.example.com", false},
+		{"host in the path", "https://evil.example/x://api.example.com", false},
+		{"other port", "https://api.example.com:8443", false},
+		{"unlisted", "https://other.example.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://api.example.com/api/v1/assignments/ws", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if got := check(r); got != tt.want {
+				t.Errorf("checkOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+			}
+		})
+	}
+	r := httptest.NewRequest(http.MethodGet, "http://api.example.com/api/v1/assignments/ws", nil)
+	r.Header.Set("Origin", "https://other.example.com")
+	if !checkOrigin(map[string]bool{"*": true})(r) {
+		t.Error("* should allow any origin")
+	}
+}
@@ -1,104 +1,241 @@
 package controllers
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
-	"strings"
+	"time"
+	"workship-disaster-api/apperr"
+	"workship-disaster-api/events"
+	"workship-disaster-api/logging"
 	"workship-disaster-api/models"
 	"workship-disaster-api/resp"
+	"workship-disaster-api/service"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
 )
 
 type TruckController struct {
-	db *sql.DB
+	db        *sql.DB
+	rdb       *redis.Client
+	trucks    *service.TruckService
+	planner   *service.PlannerService
+	publisher *events.Publisher
 }
 
-func NewTruckController(db *sql.DB) *TruckController {
-	return &TruckController{db: db}
+func NewTruckController(db *sql.DB, rdb *redis.Client, trucks *service.TruckService, planner *service.PlannerService, publisher *events.Publisher) *TruckController {
+	return &TruckController{db: db, rdb: rdb, trucks: trucks, planner: planner, publisher: publisher}
+}
+
+// ListTrucks handles GET /api/trucks, one page at a time
+func (c *TruckController) ListTrucks(ctx *gin.Context) {
+	var filter models.TruckFilter
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		apperr.Respond(ctx, apperr.Binding(err))
+		return
+	}
+	filter.HasResource = splitList(filter.HasResource)
+
+	page, err := c.trucks.ListTrucks(ctx.Request.Context(), filter)
+	if err != nil {
+		apperr.Respond(ctx, listError(err, "Failed to fetch trucks"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp.SuccessResponse{
+		Code:    http.StatusOK,
+		Message: "Trucks retrieved successfully",
+		Data:    page,
+	})
 }
 
 // CreateTruck handles the creation of a new truck
 func (c *TruckController) CreateTruck(ctx *gin.Context) {
 	var req models.CreateTruckRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, resp.ErrorResponse{
-			Code:    http.StatusBadRequest,
-			Message: "Binding error.",
-			Error:   err.Error(),
-		})
+		apperr.Respond(ctx, apperr.Binding(err))
 		return
 	}
 
-	// Check if truck ID already exists
-	var exists bool
-	err := c.db.QueryRow("SELECT EXISTS(SELECT 1 FROM trucks WHERE truck_id = $1)", req.TruckID).Scan(&exists)
+	if req.Status == "" {
+		req.Status = models.TruckStatusAvailable
+	}
+
+	warnings, err := c.trucks.CreateTruck(auditContext(ctx), req)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, resp.ErrorResponse{
-			Code:    http.StatusInternalServerError,
-			Message: "Failed to check truck ID",
-			Error:   err.Error(),
-		})
+		// The primary key rejects IDs that already exist
+		if apperr.IsUniqueViolation(err) {
+			apperr.Respond(ctx, apperr.Conflict(apperr.CodeTruckExists, "Truck ID already exists"))
+			return
+		}
+		apperr.Respond(ctx, apperr.Database(err, "Failed to create truck"))
 		return
 	}
-	if exists {
-		ctx.JSON(http.StatusConflict, resp.ErrorResponse{
-			Code:    http.StatusConflict,
-			Message: "Truck ID already exists",
-		})
+
+	data := gin.H{"truckId": req.TruckID}
+	// Travel times to unknown areas are stored but never match until the area exists
+	if len(warnings) > 0 {
+		data["warnings"] = warnings
+	}
+
+	c.planner.NotifyChange(events.TruckCreated)
+
+	ctx.JSON(http.StatusCreated, resp.SuccessResponse{
+		Code:    http.StatusCreated,
+		Message: "Truck created successfully",
+		Data:    data,
+	})
+}
+
+// UpdateTruckStatus changes the status of a truck, any change invalidates the cached plan
+func (c *TruckController) UpdateTruckStatus(ctx *gin.Context) {
+	truckID := ctx.Param("truckId")
+
+	var req models.UpdateTruckStatusRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		apperr.Respond(ctx, apperr.Binding(err))
 		return
 	}
 
-	// Convert JSON fields
-	resourcesJSON, err := json.Marshal(req.AvailableResources)
+	data := gin.H{
+		"truckId":       truckID,
+		"status":        req.Status,
+		"availableFrom": req.AvailableFrom,
+	}
+
+	reqCtx := auditContext(ctx)
+	tx, err := c.db.BeginTx(reqCtx, nil)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, resp.ErrorResponse{
-			Code:    http.StatusInternalServerError,
-			Message: "Failed to process available resources",
-			Error:   err.Error(),
-		})
+		apperr.Respond(ctx, apperr.Database(err, "Failed to update truck status"))
 		return
 	}
+	defer tx.Rollback()
 
-	travelTimeJSON, err := json.Marshal(req.TravelTimeToArea)
+	// Return the previous values for the audit trail, written in the same transaction
+	var previousStatus string
+	var previousAvailableFrom *time.Time
+	err = tx.QueryRowContext(reqCtx, `
+		UPDATE trucks t SET status = $2, available_from = $3, updated_at = CURRENT_TIMESTAMP
+		FROM (SELECT truck_id, status, available_from FROM trucks WHERE truck_id = $1 FOR UPDATE) old
+		WHERE t.truck_id = old.truck_id
+		RETURNING old.status, old.available_from`,
+		truckID, req.Status, req.AvailableFrom,
+	).Scan(&previousStatus, &previousAvailableFrom)
+	if errors.Is(err, sql.ErrNoRows) {
+		apperr.Respond(ctx, apperr.NotFound(apperr.CodeTruckNotFound, "Truck not found"))
+		return
+	}
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, resp.ErrorResponse{
-			Code:    http.StatusInternalServerError,
-			Message: "Failed to process travel times",
-			Error:   err.Error(),
-		})
+		apperr.Respond(ctx, apperr.Database(err, "Failed to update truck status"))
 		return
 	}
+	err = service.RecordAudit(reqCtx, tx, service.AuditUpdate, "truck", truckID, gin.H{
+		"truckId":       truckID,
+		"status":        previousStatus,
+		"availableFrom": previousAvailableFrom,
+	}, data)
+	var event events.Event
+	if err == nil {
+		event, err = c.publisher.Stage(reqCtx, tx, events.TruckStatusChanged, data)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		apperr.Respond(ctx, apperr.Database(err, "Failed to update truck status"))
+		return
+	}
+	broadcastEvents(ctx, c.publisher, event)
+
+	// The cached plan either still uses a truck that can't go or misses one that is back
+	clearPlanCache(reqCtx, c.rdb)
+
+	c.planner.NotifyChange(events.TruckStatusChanged)
 
-	// Insert into database
-	_, err = c.db.Exec(
-		"INSERT INTO trucks (truck_id, available_resources, travel_time_to_area) VALUES ($1, $2, $3)",
-		req.TruckID, resourcesJSON, travelTimeJSON,
-	)
+	ctx.JSON(http.StatusOK, resp.SuccessResponse{
+		Code:    http.StatusOK,
+		Message: "Truck status updated successfully",
+		Data:    data,
+	})
+}
 
+// UpdateTruckShift sets or clears the driver shift window of a truck
+func (c *TruckController) UpdateTruckShift(ctx *gin.Context) {
+	truckID := ctx.Param("truckId")
+
+	var req models.UpdateTruckShiftRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		apperr.Respond(ctx, apperr.Binding(err))
+		return
+	}
+
+	data := gin.H{
+		"truckId":    truckID,
+		"shiftStart": req.ShiftStart,
+		"shiftEnd":   req.ShiftEnd,
+	}
+
+	reqCtx := auditContext(ctx)
+	tx, err := c.db.BeginTx(reqCtx, nil)
 	if err != nil {
-		// Check if error is due to duplicate key
-		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
-			ctx.JSON(http.StatusConflict, resp.ErrorResponse{
-				Code:    http.StatusConflict,
-				Message: "Truck ID already exists",
-			})
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, resp.ErrorResponse{
-			Code:    http.StatusInternalServerError,
-			Message: "Failed to create truck",
-			Error:   err.Error(),
-		})
+		apperr.Respond(ctx, apperr.Database(err, "Failed to update truck shift"))
 		return
 	}
+	defer tx.Rollback()
 
-	ctx.JSON(http.StatusCreated, resp.SuccessResponse{
-		Code:    http.StatusCreated,
-		Message: "Truck created successfully",
-		Data: gin.H{
-			"truckId": req.TruckID,
-		},
+	var previousStart, previousEnd sql.NullString
+	err = tx.QueryRowContext(reqCtx, `
+		UPDATE trucks t SET shift_start = $2, shift_end = $3, updated_at = CURRENT_TIMESTAMP
+		FROM (SELECT truck_id, shift_start, shift_end FROM trucks WHERE truck_id = $1 FOR UPDATE) old
+		WHERE t.truck_id = old.truck_id
+		RETURNING to_char(old.shift_start, 'HH24:MI'), to_char(old.shift_end, 'HH24:MI')`,
+		truckID, service.NullIfEmpty(req.ShiftStart), service.NullIfEmpty(req.ShiftEnd),
+	).Scan(&previousStart, &previousEnd)
+	if errors.Is(err, sql.ErrNoRows) {
+		apperr.Respond(ctx, apperr.NotFound(apperr.CodeTruckNotFound, "Truck not found"))
+		return
+	}
+	if err != nil {
+		apperr.Respond(ctx, apperr.Database(err, "Failed to update truck shift"))
+		return
+	}
+	err = service.RecordAudit(reqCtx, tx, service.AuditUpdate, "truck", truckID, gin.H{
+		"truckId":    truckID,
+		"shiftStart": previousStart.String,
+		"shiftEnd":   previousEnd.String,
+	}, data)
+	var event events.Event
+	if err == nil {
+		event, err = c.publisher.Stage(reqCtx, tx, events.TruckShiftChanged, data)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		apperr.Respond(ctx, apperr.Database(err, "Failed to update truck shift"))
+		return
+	}
+	broadcastEvents(ctx, c.publisher, event)
+
+	// The cached plan was fitted to the old shift
+	clearPlanCache(reqCtx, c.rdb)
+
+	c.planner.NotifyChange(events.TruckShiftChanged)
+
+	ctx.JSON(http.StatusOK, resp.SuccessResponse{
+		Code:    http.StatusOK,
+		Message: "Truck shift updated successfully",
+		Data:    data,
 	})
 }
+
+// clearPlanCache drops the cached plan after a committed change. The change stands either way,
+// a failure is logged and the cache expires on its own or is replaced by the next replan.
+func clearPlanCache(ctx context.Context, rdb *redis.Client) {
+	if err := rdb.Del(ctx, service.PlanCacheKey).Err(); err != nil {
+		logging.FromContext(ctx).Error("failed to clear assignments cache", slog.Any("error", err))
+	}
+}
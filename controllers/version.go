@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+	"workship-disaster-api/events"
+	"workship-disaster-api/middleware"
+	"workship-disaster-api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The handlers are shared by every API version, these render the payloads that differ.
+// v1 keeps the original snake_case assignment and plan fields, v2 is camelCase throughout.
+
+func presentAssignment(ctx *gin.Context, assignment models.Assignment) interface{} {
+	if middleware.GetAPIVersion(ctx) == middleware.APIVersion2 {
+		return assignment.V2()
+	}
+	return assignment
+}
+
+func presentAssignments(ctx *gin.Context, assignments []models.Assignment) interface{} {
+	if middleware.GetAPIVersion(ctx) == middleware.APIVersion2 {
+		return models.AssignmentsV2(assignments)
+	}
+	return assignments
+}
+
+func presentPlan(ctx *gin.Context, plan *models.Plan) interface{} {
+	if middleware.GetAPIVersion(ctx) == middleware.APIVersion2 {
+		return plan.V2()
+	}
+	return plan
+}
+
+// areaIDField is the area ID's field name in hand-built assignment payloads
+func areaIDField(ctx *gin.Context) string {
+	if middleware.GetAPIVersion(ctx) == middleware.APIVersion2 {
+		return "areaId"
+	}
+	return "area_id"
+}
+
+// eventV2 is the /api/v2 stream envelope
+type eventV2 struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// presentEvent renders a streamed event, the log and webhooks keep the v1 form.
+// v2 re-encodes the assignment payloads, every other event is already camelCase.
+func presentEvent(version int, event events.Event) interface{} {
+	if version != middleware.APIVersion2 {
+		return event
+	}
+
+	data := event.Data
+	var converted interface{}
+	switch {
+	case event.Type == events.PlanCreated:
+		var plan models.Plan
+		if json.Unmarshal(event.Data, &plan) == nil {
+			converted = plan.V2()
+		}
+	case event.Type == events.AssignmentUnassigned:
+		var change models.AssignmentChange
+		if json.Unmarshal(event.Data, &change) == nil {
+			converted = models.AssignmentChangeV2(change)
+		}
+	case event.Type == events.AssignmentConfirmed:
+		var assignment models.Assignment
+		if json.Unmarshal(event.Data, &assignment) == nil {
+			converted = assignment.V2()
+		}
+	case strings.HasPrefix(event.Type, "assignment."):
+		var update struct {
+			AreaID string `json:"area_id"`
+			Status string `json:"status"`
+		}
+		if json.Unmarshal(event.Data, &update) == nil {
+			converted = gin.H{"areaId": update.AreaID, "status": update.Status}
+		}
+	}
+	if converted != nil {
+		if encoded, err := json.Marshal(converted); err == nil {
+			data = encoded
+		}
+	}
+
+	return eventV2{
+		ID:         event.ID,
+		Type:       event.Type,
+		OccurredAt: event.OccurredAt,
+		Data:       data,
+	}
+}
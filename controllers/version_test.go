@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+	"workship-disaster-api/events"
+	"workship-disaster-api/middleware"
+	"workship-disaster-api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// jsonKeys returns every object key in the encoded value, nested ones included
+func jsonKeys(t *testing.T, value interface{}) []string {
+	t.Helper()
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+	var walk func(interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for key, child := range v {
+				keys = append(keys, key)
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(decoded)
+	sort.Strings(keys)
+	return keys
+}
+
+func snakeKeys(keys []string) []string {
+	var snake []string
+	for _, key := range keys {
+		if strings.Contains(key, "_") {
+			snake = append(snake, key)
+		}
+	}
+	return snake
+}
+
+func versionContext(version int) *gin.Context {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	middleware.APIVersion(version)(ctx)
+	return ctx
+}
+
+func versionFixture() *models.Plan {
+	departure := time.Date(2025, 1, 1, 12, 30, 0, 0, time.UTC)
+	assignment := models.Assignment{
+		AreaID: "A1", TruckID: "T1", ResourcesDelivered: map[string]int{"water": 100},
+		DepartureTime: &departure, EstimatedArrival: &departure, Status: models.AssignmentStatusPlanned,
+		Priority: &models.PriorityScore{Total: 0.8, TimeRemaining: 0.1},
+	}
+	return &models.Plan{
+		ID: 3, Trigger: "manual", PlanStart: departure, CreatedAt: departure,
+		Assignments: []models.Assignment{assignment},
+		Diff:        []models.AssignmentChange{{AreaID: "A1", Change: models.ChangeReassigned, PreviousTruckID: "T2", TruckID: "T1"}},
+	}
+}
+
+// TestPresentV2IsCamelCase checks v2 renders plans and assignments without a snake_case field
+// while v1 keeps the original field names
+func TestPresentV2IsCamelCase(t *testing.T) {
+	plan := versionFixture()
+	v1, v2 := versionContext(middleware.APIVersion1), versionContext(middleware.APIVersion2)
+
+	rendered := map[string][2]interface{}{
+		"plan":        {presentPlan(v1, plan), presentPlan(v2, plan)},
+		"assignments": {presentAssignments(v1, plan.Assignments), presentAssignments(v2, plan.Assignments)},
+		"assignment":  {presentAssignment(v1, plan.Assignments[0]), presentAssignment(v2, plan.Assignments[0])},
+		"status":      {gin.H{areaIDField(v1): "A1"}, gin.H{areaIDField(v2): "A1"}},
+	}
+	for name, pair := range rendered {
+		if snake := snakeKeys(jsonKeys(t, pair[1])); len(snake) > 0 {
+			t.Errorf("v2 %s has snake_case fields %v", name, snake)
+		}
+		if !strings.Contains(strings.Join(jsonKeys(t, pair[0]), ","), "area_id") {
+			t.Errorf("v1 %s lost area_id: %v", name, jsonKeys(t, pair[0]))
+		}
+	}
+
+	// The unversioned aliases answer like v1
+	legacy, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if _, ok := presentPlan(legacy, plan).(*models.Plan); !ok {
+		t.Error("an unversioned request should get the v1 plan")
+	}
+}
+
+// TestPresentEventV2 checks the v2 stream converts the envelope and the assignment payloads
+func TestPresentEventV2(t *testing.T) {
+	plan := versionFixture()
+	encode := func(v interface{}) json.RawMessage {
+		encoded, _ := json.Marshal(v)
+		return encoded
+	}
+	tests := []struct {
+		eventType string
+		data      json.RawMessage
+	}{
+		{events.PlanCreated, encode(plan)},
+		{events.AssignmentConfirmed, encode(plan.Assignments[0])},
+		{events.AssignmentUnassigned, encode(plan.Diff[0])},
+		{events.AssignmentDelivered, encode(map[string]string{"area_id": "A1", "status": models.AssignmentStatusDelivered})},
+		{events.AreaCreated, encode(map[string]string{"areaId": "A1"})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.eventType, func(t *testing.T) {
+			event := events.Event{ID: "1-0", Type: tt.eventType, OccurredAt: plan.CreatedAt, Data: tt.data}
+
+			v2 := presentEvent(middleware.APIVersion2, event)
+			keys := jsonKeys(t, v2)
+			if snake := snakeKeys(keys); len(snake) > 0 {
+				t.Errorf("v2 event has snake_case fields %v", snake)
+			}
+			if !strings.Contains(strings.Join(keys, ","), "occurredAt") {
+				t.Errorf("v2 envelope = %v, want occurredAt", keys)
+			}
+
+			if v1, ok := presentEvent(middleware.APIVersion1, event).(events.Event); !ok || string(v1.Data) != string(tt.data) {
+				t.Errorf("v1 event = %+v, want the logged event unchanged", v1)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"workship-disaster-api/apperr"
+	"workship-disaster-api/events"
+	"workship-disaster-api/models"
+	"workship-disaster-api/resp"
+	"workship-disaster-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebhookController manages partner webhook subscriptions and the delivery outbox
+type WebhookController struct {
+	webhookService *service.WebhookService
+}
+
+// NewWebhookController ...
+func NewWebhookController(webhookService *service.WebhookService) *WebhookController {
+	return &WebhookController{webhookService: webhookService}
+}
+
+// CreateWebhook handles the creation of a new subscription, the secret is only returned here
+func (c *WebhookController) CreateWebhook(ctx *gin.Context) {
+	var req models.CreateWebhookRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		apperr.Respond(ctx, apperr.Binding(err))
+		return
+	}
+	if !validEventTypes(ctx, req.EventTypes) {
+		return
+	}
+
+	subscription, err := c.webhookService.CreateSubscription(auditContext(ctx), req)
+	if err != nil {
+		apperr.Respond(ctx, apperr.Database(err, "Failed to create webhook"))
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, resp.SuccessResponse{
+		Code:    http.StatusCreated,
+		Message: "Webhook created successfully",
+		Data:    subscription,
+	})
+}
+
+// ListWebhooks returns every subscription
+func (c *WebhookController) ListWebhooks(ctx *gin.Context) {
+	subscriptions, err := c.webhookService.ListSubscriptions()
+	if err != nil {
+		apperr.Respond(ctx, apperr.Database(err, "Failed to fetch webhooks"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp.SuccessResponse{
+		Code:    http.StatusOK,
+		Message: "Webhooks retrieved successfully",
+		Data:    subscriptions,
+	})
+}
+
+// GetWebhook returns one subscription
+func (c *WebhookController) GetWebhook(ctx *gin.Context) {
+	id, ok := webhookID(ctx, "webhookId")
+	if !ok {
+		return
+	}
+
+	subscription, err := c.webhookService.GetSubscription(id)
+	if err != nil {
+		respondWebhookError(ctx, err, "Failed to fetch webhook")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp.SuccessResponse{
+		Code:    http.StatusOK,
+		Message: "Webhook retrieved successfully",
+		Data:    subscription,
+	})
+}
+
+// UpdateWebhook replaces a subscription's URL, event types and active flag
+func (c *WebhookController) UpdateWebhook(ctx *gin.Context) {
+	id, ok := webhookID(ctx, "webhookId")
+	if !ok {
+		return
+	}
+
+	var req models.UpdateWebhookRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		apperr.Respond(ctx, apperr.Binding(err))
+		return
+	}
+	if !validEventTypes(ctx, req.EventTypes) {
+		return
+	}
+
+	subscription, err := c.webhookService.UpdateSubscription(auditContext(ctx), id, req)
+	if err != nil {
+		respondWebhookError(ctx, err, "Failed to update webhook")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp.SuccessResponse{
+		Code:    http.StatusOK,
+		Message: "Webhook updated successfully",
+		Data:    subscription,
+	})
+}
+
+// DeleteWebhook removes a subscription and its queued deliveries
+func (c *WebhookController) DeleteWebhook(ctx *gin.Context) {
+	id, ok := webhookID(ctx, "webhookId")
+	if !ok {
+		return
+	}
+
+	if err := c.webhookService.DeleteSubscription(auditContext(ctx), id); err != nil {
+		respondWebhookError(ctx, err, "Failed to delete webhook")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp.SuccessResponse{
+		Code:    http.StatusOK,
+		Message: "Webhook deleted successfully",
+		Data:    nil,
+	})
+}
+
+// ListDeliveries shows the outbox, ?status=dead is the dead-letter view
+func (c *WebhookController) ListDeliveries(ctx *gin.Context) {
+	status := ctx.Query("status")
+	if status != "" && status != models.DeliveryStatusPending && status != models.DeliveryStatusDelivered && status != models.DeliveryStatusDead {
+		apperr.Respond(ctx, apperr.BadRequest("Invalid status, expected pending, delivered or dead"))
+		return
+	}
+
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 1000 {
+		apperr.Respond(ctx, apperr.BadRequest("Invalid limit, expected 1 to 1000"))
+		return
+	}
+
+	deliveries, err := c.webhookService.ListDeliveries(status, limit)
+	if err != nil {
+		apperr.Respond(ctx, apperr.Database(err, "Failed to fetch webhook deliveries"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp.SuccessResponse{
+		Code:    http.StatusOK,
+		Message: "Webhook deliveries retrieved successfully",
+		Data:    deliveries,
+	})
+}
+
+// RetryDelivery re-queues a dead delivery
+func (c *WebhookController) RetryDelivery(ctx *gin.Context) {
+	id, ok := webhookID(ctx, "deliveryId")
+	if !ok {
+		return
+	}
+
+	if err := c.webhookService.RetryDelivery(auditContext(ctx), id); err != nil {
+		if errors.Is(err, service.ErrWebhookNotFound) {
+			apperr.Respond(ctx, apperr.NotFound(apperr.CodeDeliveryNotFound, "Dead delivery not found"))
+			return
+		}
+		apperr.Respond(ctx, apperr.Database(err, "Failed to retry webhook delivery"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp.SuccessResponse{
+		Code:    http.StatusOK,
+		Message: "Webhook delivery queued for retry",
+		Data:    gin.H{"id": id},
+	})
+}
+
+func webhookID(ctx *gin.Context, param string) (int64, bool) {
+	id, err := strconv.ParseInt(ctx.Param(param), 10, 64)
+	if err != nil {
+		apperr.Respond(ctx, apperr.BadRequest("Invalid "+param))
+		return 0, false
+	}
+	return id, true
+}
+
+func validEventTypes(ctx *gin.Context, eventTypes []string) bool {
+	for _, eventType := range eventTypes {
+		if !events.IsKnownType(eventType) {
+			apperr.Respond(ctx, apperr.New(http.StatusBadRequest, apperr.CodeUnknownEventType, "Unknown event type "+eventType))
+			return false
+		}
+	}
+	return true
+}
+
+func respondWebhookError(ctx *gin.Context, err error, message string) {
+	if errors.Is(err, service.ErrWebhookNotFound) {
+		apperr.Respond(ctx, apperr.NotFound(apperr.CodeWebhookNotFound, "Webhook not found"))
+		return
+	}
+	apperr.Respond(ctx, apperr.Database(err, message))
+}
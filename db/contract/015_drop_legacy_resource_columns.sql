@@ -0,0 +1,14 @@
+-- Contract step of 012 and 014: drops the JSONB columns the tables replaced and the triggers
+-- that mirror them. Move this file into db/migrations one release after 014, once no instance of
+-- the previous version is left and rolling back to it is no longer planned.
+DROP TRIGGER IF EXISTS area_requirements_mirror ON area_requirements;
+DROP TRIGGER IF EXISTS truck_inventory_mirror ON truck_inventory;
+DROP TRIGGER IF EXISTS truck_area_travel_mirror ON truck_area_travel;
+DROP TRIGGER IF EXISTS truck_area_travel_unresolved_mirror ON truck_area_travel_unresolved;
+DROP FUNCTION IF EXISTS mirror_area_requirements();
+DROP FUNCTION IF EXISTS mirror_truck_relations();
+
+ALTER TABLE areas DROP COLUMN IF EXISTS required_resources;
+ALTER TABLE trucks
+    DROP COLUMN IF EXISTS available_resources,
+    DROP COLUMN IF EXISTS travel_time_to_area;
@@ -1,17 +1,18 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"path/filepath"
 	"sort"
 	"strings"
 )
 
 // RunMigrations executes all SQL migration files in the migrations directory
-func RunMigrations(db *sql.DB) error {
+func RunMigrations(db *sql.DB, logger *slog.Logger) error {
 	// Get all migration files
 	files, err := ioutil.ReadDir("db/migrations")
 	if err != nil {
@@ -49,7 +50,7 @@ func RunMigrations(db *sql.DB) error {
 		}
 
 		if count > 0 {
-			log.Printf("Skipping migration %s (already applied)", file.Name())
+			logger.Debug("skipping migration, already applied", slog.String("migration", file.Name()))
 			continue
 		}
 
@@ -84,8 +85,43 @@ func RunMigrations(db *sql.DB) error {
 			return fmt.Errorf("failed to commit migration %s: %v", file.Name(), err)
 		}
 
-		log.Printf("Applied migration %s", file.Name())
+		logger.Info("applied migration", slog.String("migration", file.Name()))
 	}
 
 	return nil
 }
+
+// PendingMigrations lists the migration files that have not been applied yet, in order
+func PendingMigrations(ctx context.Context, db *sql.DB) ([]string, error) {
+	files, err := ioutil.ReadDir("db/migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations directory: %v", err)
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT name FROM migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch applied migrations: %v", err)
+	}
+	defer rows.Close()
+
+	applied := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to parse applied migration: %v", err)
+		}
+		applied[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to fetch applied migrations: %v", err)
+	}
+
+	pending := []string{}
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), ".sql") && !applied[file.Name()] {
+			pending = append(pending, file.Name())
+		}
+	}
+	sort.Strings(pending)
+	return pending, nil
+}
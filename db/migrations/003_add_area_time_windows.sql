@@ -0,0 +1,6 @@
+ALTER TABLE areas
+    ADD COLUMN IF NOT EXISTS earliest_arrival TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS latest_arrival TIMESTAMP WITH TIME ZONE;
+
+-- Areas created before time windows existed keep the relative time_constraint
+-- (minutes from plan start), so latest_arrival is intentionally left NULL here.
@@ -0,0 +1,7 @@
+ALTER TABLE trucks
+    ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'available'
+        CHECK (status IN ('available', 'busy', 'maintenance', 'offline')),
+    ADD COLUMN IF NOT EXISTS shift_start TIME,
+    ADD COLUMN IF NOT EXISTS shift_end TIME,
+    ADD COLUMN IF NOT EXISTS available_from TIMESTAMP WITH TIME ZONE,
+    ADD CONSTRAINT truck_shift_complete CHECK ((shift_start IS NULL) = (shift_end IS NULL));
@@ -0,0 +1,3 @@
+ALTER TABLE areas
+    ADD COLUMN IF NOT EXISTS population INTEGER NOT NULL DEFAULT 0 CHECK (population >= 0),
+    ADD COLUMN IF NOT EXISTS vulnerable_groups JSONB NOT NULL DEFAULT '{}'::jsonb;
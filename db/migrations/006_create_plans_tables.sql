@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS plans (
+    id BIGSERIAL PRIMARY KEY,
+    trigger TEXT NOT NULL,
+    plan_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    assignments JSONB NOT NULL,
+    diff JSONB NOT NULL DEFAULT '[]'::jsonb,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Assignments a coordinator has confirmed are locked, the planner carries them over unchanged
+CREATE TABLE IF NOT EXISTS locked_assignments (
+    area_id VARCHAR(255) PRIMARY KEY REFERENCES areas(area_id) ON DELETE CASCADE,
+    truck_id VARCHAR(255) NOT NULL REFERENCES trucks(truck_id) ON DELETE CASCADE,
+    plan_id BIGINT REFERENCES plans(id) ON DELETE SET NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('confirmed', 'in_transit', 'delivered')),
+    resources_delivered JSONB NOT NULL,
+    departure_time TIMESTAMP WITH TIME ZONE,
+    estimated_arrival TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- A truck can only be on one active trip at a time
+CREATE UNIQUE INDEX IF NOT EXISTS locked_assignments_active_truck
+    ON locked_assignments (truck_id)
+    WHERE status IN ('confirmed', 'in_transit');
@@ -0,0 +1,10 @@
+-- WGS84 coordinates, set by GeoJSON imports or the latitude/longitude fields
+ALTER TABLE areas
+    ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION CHECK (latitude BETWEEN -90 AND 90),
+    ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION CHECK (longitude BETWEEN -180 AND 180),
+    ADD CONSTRAINT area_coordinates_complete CHECK ((latitude IS NULL) = (longitude IS NULL));
+
+ALTER TABLE trucks
+    ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION CHECK (latitude BETWEEN -90 AND 90),
+    ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION CHECK (longitude BETWEEN -180 AND 180),
+    ADD CONSTRAINT truck_coordinates_complete CHECK ((latitude IS NULL) = (longitude IS NULL));
@@ -0,0 +1,10 @@
+-- Keyset pagination sorts on created_at, rows from before the default existed get the migration time
+UPDATE areas SET created_at = CURRENT_TIMESTAMP WHERE created_at IS NULL;
+UPDATE trucks SET created_at = CURRENT_TIMESTAMP WHERE created_at IS NULL;
+ALTER TABLE areas ALTER COLUMN created_at SET NOT NULL;
+ALTER TABLE trucks ALTER COLUMN created_at SET NOT NULL;
+
+-- Sort orders of GET /api/areas and /api/trucks, the primary key breaks ties
+CREATE INDEX IF NOT EXISTS areas_urgency_level ON areas (urgency_level, area_id);
+CREATE INDEX IF NOT EXISTS areas_created_at ON areas (created_at, area_id);
+CREATE INDEX IF NOT EXISTS trucks_created_at ON trucks (created_at, truck_id);
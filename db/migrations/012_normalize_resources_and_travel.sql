@@ -0,0 +1,100 @@
+-- Resources and travel times move out of the JSONB columns into tables with foreign keys
+CREATE TABLE IF NOT EXISTS area_requirements (
+    area_id VARCHAR(255) NOT NULL REFERENCES areas (area_id) ON DELETE CASCADE,
+    resource VARCHAR(100) NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity >= 0),
+    PRIMARY KEY (area_id, resource)
+);
+
+CREATE TABLE IF NOT EXISTS truck_inventory (
+    truck_id VARCHAR(255) NOT NULL REFERENCES trucks (truck_id) ON DELETE CASCADE,
+    resource VARCHAR(100) NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity >= 0),
+    PRIMARY KEY (truck_id, resource)
+);
+
+CREATE TABLE IF NOT EXISTS truck_area_travel (
+    truck_id VARCHAR(255) NOT NULL REFERENCES trucks (truck_id) ON DELETE CASCADE,
+    area_id VARCHAR(255) NOT NULL REFERENCES areas (area_id) ON DELETE CASCADE,
+    minutes INTEGER NOT NULL CHECK (minutes >= 0),
+    PRIMARY KEY (truck_id, area_id)
+);
+
+-- Trucks may list travel times to areas that aren't registered yet, those wait here
+-- and move into truck_area_travel when the area is created. area_id has no foreign key on purpose,
+-- UNKNOWN_AREA_POLICY decides what gets in: warn stores the rows, reject never writes any and
+-- ignore stores them without telling the client.
+CREATE TABLE IF NOT EXISTS truck_area_travel_unresolved (
+    truck_id VARCHAR(255) NOT NULL REFERENCES trucks (truck_id) ON DELETE CASCADE,
+    area_id VARCHAR(255) NOT NULL,
+    minutes INTEGER NOT NULL CHECK (minutes >= 0),
+    PRIMARY KEY (truck_id, area_id)
+);
+
+-- "Areas needing water", "trucks carrying water" and "trucks with a route to A1"
+CREATE INDEX IF NOT EXISTS area_requirements_resource ON area_requirements (resource, area_id) WHERE quantity > 0;
+CREATE INDEX IF NOT EXISTS truck_inventory_resource ON truck_inventory (resource, truck_id) WHERE quantity > 0;
+CREATE INDEX IF NOT EXISTS truck_area_travel_area ON truck_area_travel (area_id, truck_id);
+CREATE INDEX IF NOT EXISTS truck_area_travel_unresolved_area ON truck_area_travel_unresolved (area_id);
+
+-- Data migration
+INSERT INTO area_requirements (area_id, resource, quantity)
+SELECT a.area_id, r.key, r.value::int
+FROM areas a, jsonb_each_text(a.required_resources) r
+ON CONFLICT DO NOTHING;
+
+INSERT INTO truck_inventory (truck_id, resource, quantity)
+SELECT t.truck_id, r.key, r.value::int
+FROM trucks t, jsonb_each_text(t.available_resources) r
+ON CONFLICT DO NOTHING;
+
+INSERT INTO truck_area_travel (truck_id, area_id, minutes)
+SELECT t.truck_id, tr.key, tr.value::int
+FROM trucks t, jsonb_each_text(t.travel_time_to_area) tr
+WHERE EXISTS (SELECT 1 FROM areas a WHERE a.area_id = tr.key)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO truck_area_travel_unresolved (truck_id, area_id, minutes)
+SELECT t.truck_id, tr.key, tr.value::int
+FROM trucks t, jsonb_each_text(t.travel_time_to_area) tr
+WHERE NOT EXISTS (SELECT 1 FROM areas a WHERE a.area_id = tr.key)
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE areas DROP COLUMN IF EXISTS required_resources;
+ALTER TABLE trucks
+    DROP COLUMN IF EXISTS available_resources,
+    DROP COLUMN IF EXISTS travel_time_to_area;
+
+-- Registering an area resolves the travel times trucks already listed for it
+CREATE OR REPLACE FUNCTION resolve_truck_area_travel() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO truck_area_travel (truck_id, area_id, minutes)
+    SELECT truck_id, area_id, minutes FROM truck_area_travel_unresolved WHERE area_id = NEW.area_id
+    ON CONFLICT DO NOTHING;
+    DELETE FROM truck_area_travel_unresolved WHERE area_id = NEW.area_id;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS areas_resolve_travel ON areas;
+CREATE TRIGGER areas_resolve_travel
+    AFTER INSERT ON areas
+    FOR EACH ROW EXECUTE FUNCTION resolve_truck_area_travel();
+
+-- The API payloads keep their maps, these rebuild them from the tables
+CREATE OR REPLACE FUNCTION area_requirements_json(area VARCHAR) RETURNS JSONB AS $$
+    SELECT COALESCE(jsonb_object_agg(resource, quantity), '{}'::jsonb) FROM area_requirements WHERE area_id = area
+$$ LANGUAGE sql STABLE;
+
+CREATE OR REPLACE FUNCTION truck_inventory_json(truck VARCHAR) RETURNS JSONB AS $$
+    SELECT COALESCE(jsonb_object_agg(resource, quantity), '{}'::jsonb) FROM truck_inventory WHERE truck_id = truck
+$$ LANGUAGE sql STABLE;
+
+-- Unresolved travel times are included so trucks read back as they were written
+CREATE OR REPLACE FUNCTION truck_travel_json(truck VARCHAR) RETURNS JSONB AS $$
+    SELECT COALESCE(jsonb_object_agg(area_id, minutes), '{}'::jsonb) FROM (
+        SELECT area_id, minutes FROM truck_area_travel WHERE truck_id = truck
+        UNION ALL
+        SELECT area_id, minutes FROM truck_area_travel_unresolved WHERE truck_id = truck
+    ) travel
+$$ LANGUAGE sql STABLE;
@@ -0,0 +1,7 @@
+-- Areas may give a disaster type and duration instead of exact unit counts
+ALTER TABLE areas
+    ADD COLUMN IF NOT EXISTS disaster_type VARCHAR(50),
+    ADD COLUMN IF NOT EXISTS duration_days INTEGER CHECK (duration_days > 0);
+
+-- Marks quantities derived from population and disaster type rather than entered by hand
+ALTER TABLE area_requirements ADD COLUMN IF NOT EXISTS estimated BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,61 @@
+-- Expand step of 012: puts the JSONB columns 012 dropped back for one release, so the previous
+-- version can still be rolled back to. They are filled from the tables and the application only
+-- writes the tables, the triggers below copy every change back into the columns. Rows the previous
+-- version writes after a rollback are not copied into the tables.
+-- db/contract/015_drop_legacy_resource_columns.sql drops the columns in the following release.
+ALTER TABLE areas ADD COLUMN IF NOT EXISTS required_resources JSONB NOT NULL DEFAULT '{}'::jsonb;
+ALTER TABLE trucks
+    ADD COLUMN IF NOT EXISTS available_resources JSONB NOT NULL DEFAULT '{}'::jsonb,
+    ADD COLUMN IF NOT EXISTS travel_time_to_area JSONB NOT NULL DEFAULT '{}'::jsonb;
+
+UPDATE areas SET required_resources = area_requirements_json(area_id);
+UPDATE trucks SET available_resources = truck_inventory_json(truck_id), travel_time_to_area = truck_travel_json(truck_id);
+
+CREATE OR REPLACE FUNCTION mirror_area_requirements() RETURNS trigger AS $$
+DECLARE
+    area VARCHAR;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        area := OLD.area_id;
+    ELSE
+        area := NEW.area_id;
+    END IF;
+    UPDATE areas SET required_resources = area_requirements_json(area) WHERE area_id = area;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION mirror_truck_relations() RETURNS trigger AS $$
+DECLARE
+    truck VARCHAR;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        truck := OLD.truck_id;
+    ELSE
+        truck := NEW.truck_id;
+    END IF;
+    UPDATE trucks SET available_resources = truck_inventory_json(truck), travel_time_to_area = truck_travel_json(truck)
+    WHERE truck_id = truck;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS area_requirements_mirror ON area_requirements;
+CREATE TRIGGER area_requirements_mirror
+    AFTER INSERT OR UPDATE OR DELETE ON area_requirements
+    FOR EACH ROW EXECUTE FUNCTION mirror_area_requirements();
+
+DROP TRIGGER IF EXISTS truck_inventory_mirror ON truck_inventory;
+CREATE TRIGGER truck_inventory_mirror
+    AFTER INSERT OR UPDATE OR DELETE ON truck_inventory
+    FOR EACH ROW EXECUTE FUNCTION mirror_truck_relations();
+
+DROP TRIGGER IF EXISTS truck_area_travel_mirror ON truck_area_travel;
+CREATE TRIGGER truck_area_travel_mirror
+    AFTER INSERT OR UPDATE OR DELETE ON truck_area_travel
+    FOR EACH ROW EXECUTE FUNCTION mirror_truck_relations();
+
+DROP TRIGGER IF EXISTS truck_area_travel_unresolved_mirror ON truck_area_travel_unresolved;
+CREATE TRIGGER truck_area_travel_unresolved_mirror
+    AFTER INSERT OR UPDATE OR DELETE ON truck_area_travel_unresolved
+    FOR EACH ROW EXECUTE FUNCTION mirror_truck_relations();
@@ -3,14 +3,18 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"log/slog"
 	"os"
 
+	"github.com/XSAM/otelsql"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+
 	// Import postgres driver
 	_ "github.com/lib/pq"
 )
 
 // createDatabase creates the database if it doesn't exist
-func createDatabase() error {
+func createDatabase(logger *slog.Logger) error {
 	// Connect to default postgres database
 	postgresURL := fmt.Sprintf("postgres://%s:%s@%s:%s/postgres?sslmode=disable",
 		os.Getenv("POSTGRES_USER"),
@@ -38,16 +42,16 @@ func createDatabase() error {
 		if err != nil {
 			return fmt.Errorf("failed to create database: %v", err)
 		}
-		fmt.Printf("Created database %s\n", os.Getenv("POSTGRES_DB"))
+		logger.Info("created database", slog.String("database", os.Getenv("POSTGRES_DB")))
 	}
 
 	return nil
 }
 
 // ConnectPostgres establishes a connection to PostgreSQL database
-func ConnectPostgres() (*sql.DB, error) {
+func ConnectPostgres(logger *slog.Logger) (*sql.DB, error) {
 	// Create database if it doesn't exist
-	if err := createDatabase(); err != nil {
+	if err := createDatabase(logger); err != nil {
 		return nil, err
 	}
 
@@ -58,7 +62,11 @@ func ConnectPostgres() (*sql.DB, error) {
 		os.Getenv("POSTGRES_PORT"),
 		os.Getenv("POSTGRES_DB"))
 
-	db, err := sql.Open("postgres", postgresURL)
+	// Every query and exec becomes a span of the caller's trace
+	db, err := otelsql.Open("postgres", postgresURL,
+		otelsql.WithAttributes(semconv.DBSystemPostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{OmitConnResetSession: true}),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to PostgreSQL: %v", err)
 	}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"workship-disaster-api/tracing"
 
 	"github.com/go-redis/redis/v8"
 )
@@ -21,6 +22,9 @@ func ConnectRedis() (*redis.Client, error) {
 		DB:       0, // use default DB
 	})
 
+	// Every command becomes a span of the caller's trace
+	rdb.AddHook(tracing.RedisHook{})
+
 	// Test the connection
 	if err := rdb.Ping(ctx).Err(); err != nil {
 		return nil, fmt.Errorf("failed to connect to Redis: %v", err)
@@ -0,0 +1,15 @@
+// Package docs embeds the OpenAPI document and the Swagger UI page served next to the API.
+// Keep openapi.json in step with router.SetupRouter, the router tests fail on undocumented routes.
+package docs
+
+import _ "embed"
+
+// OpenAPI is the OpenAPI 3 document served at /openapi.json
+//
+//go:embed openapi.json
+var OpenAPI []byte
+
+// SwaggerUI is the interactive documentation page served at /docs, it loads /openapi.json
+//
+//go:embed swagger.html
+var SwaggerUI []byte
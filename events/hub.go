@@ -0,0 +1,120 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// subscriberBuffer is how many events a slow client may fall behind before it is dropped
+const subscriberBuffer = 64
+
+// Hub fans events received over Redis pub/sub out to the clients connected to this replica
+type Hub struct {
+	rdb    *redis.Client
+	logger *slog.Logger
+
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+// NewHub ...
+func NewHub(rdb *redis.Client, logger *slog.Logger) *Hub {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Hub{
+		rdb:         rdb,
+		logger:      logger.With(slog.String("component", "event_hub")),
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// Run listens on the events channel until ctx is done, reconnecting on errors
+func (h *Hub) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		h.listen(ctx)
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers {
+		close(ch)
+		delete(h.subscribers, ch)
+	}
+}
+
+func (h *Hub) listen(ctx context.Context) {
+	pubsub := h.rdb.Subscribe(ctx, Channel)
+	defer pubsub.Close()
+
+	if _, err := pubsub.Receive(ctx); err != nil {
+		if ctx.Err() == nil {
+			h.logger.Error("failed to subscribe", slog.Any("error", err))
+		}
+		return
+	}
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message, ok := <-messages:
+			if !ok {
+				return
+			}
+
+			var event Event
+			if err := json.Unmarshal([]byte(message.Payload), &event); err != nil {
+				h.logger.Warn("dropped malformed event", slog.Any("error", err))
+				continue
+			}
+			h.broadcast(event)
+		}
+	}
+}
+
+func (h *Hub) broadcast(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// The client is too slow, it can reconnect with its last event ID
+			close(ch)
+			delete(h.subscribers, ch)
+		}
+	}
+}
+
+// Subscribe registers a client, the channel is closed when the client falls behind or the hub stops
+func (h *Hub) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	h.mu.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+
+	unsubscribe := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subscribers[ch]; ok {
+			close(ch)
+			delete(h.subscribers, ch)
+		}
+	}
+
+	return ch, unsubscribe
+}
@@ -0,0 +1,99 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestHubDropsSlowSubscriber checks a client that stops reading is closed once its buffer is full,
+// while a client that keeps up still gets every event
+func TestHubDropsSlowSubscriber(t *testing.T) {
+	hub := NewHub(nil, nil)
+	slow, _ := hub.Subscribe()
+	fast, unsubscribe := hub.Subscribe()
+	defer unsubscribe()
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		hub.broadcast(Event{ID: fmt.Sprintf("%d-0", i+1), Type: AreaCreated})
+		if event := <-fast; event.ID != fmt.Sprintf("%d-0", i+1) {
+			t.Fatalf("fast subscriber got %s", event.ID)
+		}
+	}
+
+	received := 0
+	for range slow {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Errorf("slow subscriber got %d events before being dropped, want %d", received, subscriberBuffer)
+	}
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if len(hub.subscribers) != 1 {
+		t.Errorf("hub keeps %d subscribers, want only the fast one", len(hub.subscribers))
+	}
+}
+
+// TestHubRelaysBroadcastEvents checks events broadcast by any replica reach this replica's clients
+// with their stream ID, so clients can resume and skip duplicates with After
+func TestHubRelaysBroadcastEvents(t *testing.T) {
+	publisher, server := newTestPublisher(t)
+	hub := NewHub(publisher.rdb, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.Run(ctx)
+
+	live, unsubscribe := hub.Subscribe()
+	defer unsubscribe()
+	waitForSubscriber(t, server.PubSubNumSub)
+
+	event, _ := publisher.Stage(ctx, fakeTx{}, TruckCreated, map[string]string{"truckId": "T1"})
+	if err := publisher.Broadcast(ctx, event); err != nil {
+		t.Fatalf("Broadcast: %v", err)
+	}
+	logged, err := publisher.Since(ctx, "0-0")
+	if err != nil || len(logged) != 1 {
+		t.Fatalf("Since = %v, %v", logged, err)
+	}
+
+	select {
+	case got := <-live:
+		if got.ID != logged[0].ID || got.Type != TruckCreated || string(got.Data) != `{"truckId":"T1"}` {
+			t.Errorf("relayed %+v, want the logged event %+v", got, logged[0])
+		}
+	case <-time.After(time.Second):
+		t.Fatal("event was not relayed")
+	}
+}
+
+// waitForSubscriber waits until the hub listens on Channel, events published earlier are not relayed
+func waitForSubscriber(t *testing.T, numSub func(...string) map[string]int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for numSub(Channel)[Channel] == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("hub did not subscribe")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestAfter(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"2-0", "1-0", true},
+		{"1-1", "1-0", true},
+		{"1-0", "1-0", false},
+		{"1-0", "1-1", false},
+		{"10-0", "9-5", true},
+	}
+	for _, tt := range tests {
+		if got := After(tt.a, tt.b); got != tt.want {
+			t.Errorf("After(%s, %s) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
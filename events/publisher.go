@@ -0,0 +1,208 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Channel is the Redis pub/sub channel every API replica publishes to
+const Channel = "assignments:events"
+
+// StreamKey keeps recent events so clients can resume from the last event ID they saw
+const StreamKey = "assignments:events:log"
+
+// streamMaxLen is roughly how many events are kept for resuming
+const streamMaxLen = 1000
+
+// Event types
+const (
+	PlanCreated          = "plan.created"
+	AreaCreated          = "area.created"
+	AreaUpdated          = "area.updated"
+	TruckCreated         = "truck.created"
+	TruckUpdated         = "truck.updated"
+	TruckStatusChanged   = "truck.status_changed"
+	TruckShiftChanged    = "truck.shift_changed"
+	AssignmentConfirmed  = "assignment.confirmed"
+	AssignmentInTransit  = "assignment.in_transit"
+	AssignmentDelivered  = "assignment.delivered"
+	AssignmentCancelled  = "assignment.cancelled"
+	AssignmentUnassigned = "assignment.unassigned"
+)
+
+// Types lists every event type, "*" subscribes to all of them
+var Types = []string{
+	PlanCreated,
+	AreaCreated,
+	AreaUpdated,
+	TruckCreated,
+	TruckUpdated,
+	TruckStatusChanged,
+	TruckShiftChanged,
+	AssignmentConfirmed,
+	AssignmentInTransit,
+	AssignmentDelivered,
+	AssignmentCancelled,
+	AssignmentUnassigned,
+}
+
+// IsKnownType reports whether eventType can be subscribed to
+func IsKnownType(eventType string) bool {
+	if eventType == "*" {
+		return true
+	}
+	for _, known := range Types {
+		if known == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Execer is the transaction of the change an event describes, e.g. a *sql.Tx
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Sink durably stores every staged event in the caller's transaction, e.g. the webhook outbox
+type Sink interface {
+	Enqueue(ctx context.Context, tx Execer, event Event) error
+}
+
+// Event is the envelope published for every change. ID is a random outbox ID once staged,
+// Broadcast replaces it with the Redis stream entry ID that streaming clients resume from.
+type Event struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// Publisher writes events to the durable sinks and fans them out through Redis
+type Publisher struct {
+	rdb   *redis.Client
+	sinks []Sink
+}
+
+// NewPublisher ...
+func NewPublisher(rdb *redis.Client, sinks ...Sink) *Publisher {
+	return &Publisher{rdb: rdb, sinks: sinks}
+}
+
+// Stage writes the event to every sink in tx, so it commits or rolls back with the change.
+// Pass the result to Broadcast once tx has committed.
+func (p *Publisher) Stage(ctx context.Context, tx Execer, eventType string, data interface{}) (Event, error) {
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to encode event %s: %w", eventType, err)
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return Event{}, fmt.Errorf("failed to generate event ID: %w", err)
+	}
+	event := Event{
+		ID:         hex.EncodeToString(id),
+		Type:       eventType,
+		OccurredAt: time.Now().UTC(),
+		Data:       dataJSON,
+	}
+
+	for _, sink := range p.sinks {
+		if err := sink.Enqueue(ctx, tx, event); err != nil {
+			return Event{}, fmt.Errorf("failed to enqueue event %s: %w", eventType, err)
+		}
+	}
+	return event, nil
+}
+
+// Broadcast appends staged events to the resume log and sends them to every subscriber.
+// It is best effort, the sinks already hold the events when Redis is unavailable.
+func (p *Publisher) Broadcast(ctx context.Context, staged ...Event) error {
+	var errs []error
+	for _, event := range staged {
+		if err := p.broadcast(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Publisher) broadcast(ctx context.Context, event Event) error {
+	id, err := p.rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: StreamKey,
+		MaxLen: streamMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{
+			"type":        event.Type,
+			"occurred_at": event.OccurredAt.Format(time.RFC3339Nano),
+			"data":        string(event.Data),
+		},
+	}).Result()
+	if err != nil {
+		return fmt.Errorf("failed to append event %s: %w", event.Type, err)
+	}
+	event.ID = id
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event %s: %w", event.Type, err)
+	}
+
+	if err := p.rdb.Publish(ctx, Channel, payload).Err(); err != nil {
+		return fmt.Errorf("failed to publish event %s: %w", event.Type, err)
+	}
+	return nil
+}
+
+// Since returns the logged events after the given event ID, oldest first
+func (p *Publisher) Since(ctx context.Context, lastEventID string) ([]Event, error) {
+	messages, err := p.rdb.XRange(ctx, StreamKey, lastEventID, "+").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read event log: %w", err)
+	}
+
+	replay := make([]Event, 0, len(messages))
+	for _, message := range messages {
+		// XRANGE is inclusive, the client already has lastEventID
+		if message.ID == lastEventID {
+			continue
+		}
+
+		event := Event{ID: message.ID}
+		event.Type, _ = message.Values["type"].(string)
+		if occurredAt, ok := message.Values["occurred_at"].(string); ok {
+			event.OccurredAt, _ = time.Parse(time.RFC3339Nano, occurredAt)
+		}
+		if data, ok := message.Values["data"].(string); ok {
+			event.Data = json.RawMessage(data)
+		}
+		replay = append(replay, event)
+	}
+
+	return replay, nil
+}
+
+// After reports whether stream ID a comes after b
+func After(a, b string) bool {
+	aMs, aSeq := splitID(a)
+	bMs, bSeq := splitID(b)
+	if aMs != bMs {
+		return aMs > bMs
+	}
+	return aSeq > bSeq
+}
+
+func splitID(id string) (uint64, uint64) {
+	var ms, seq uint64
+	fmt.Sscanf(id, "%d-%d", &ms, &seq)
+	return ms, seq
+}
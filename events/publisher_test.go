@@ -0,0 +1,110 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// recordingSink keeps what was enqueued and which transaction it was enqueued in
+type recordingSink struct {
+	events []Event
+	txs    []Execer
+	err    error
+}
+
+func (s *recordingSink) Enqueue(ctx context.Context, tx Execer, event Event) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.events = append(s.events, event)
+	s.txs = append(s.txs, tx)
+	return nil
+}
+
+// fakeTx stands in for the caller's transaction, the sinks only receive it
+type fakeTx struct{}
+
+func (fakeTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return nil, nil
+}
+
+func newTestPublisher(t *testing.T, sinks ...Sink) (*Publisher, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	return NewPublisher(redis.NewClient(&redis.Options{Addr: server.Addr()}), sinks...), server
+}
+
+func TestStageEnqueuesInCallerTransaction(t *testing.T) {
+	sink := &recordingSink{}
+	publisher, server := newTestPublisher(t, sink)
+	tx := fakeTx{}
+
+	event, err := publisher.Stage(context.Background(), tx, AreaCreated, map[string]string{"areaId": "A1"})
+	if err != nil {
+		t.Fatalf("Stage: %v", err)
+	}
+	if len(sink.events) != 1 || sink.events[0].ID != event.ID || sink.txs[0] != Execer(tx) {
+		t.Fatalf("sink got %+v, want the staged event in the caller's transaction", sink.events)
+	}
+	if event.ID == "" || string(event.Data) != `{"areaId":"A1"}` {
+		t.Errorf("staged event = %+v", event)
+	}
+	// Nothing reaches Redis before the caller commits and broadcasts
+	if server.Exists(StreamKey) {
+		t.Error("Stage appended to the stream before Broadcast")
+	}
+}
+
+func TestStageFailsWhenSinkFails(t *testing.T) {
+	publisher, _ := newTestPublisher(t, &recordingSink{err: errors.New("insert failed")})
+
+	if _, err := publisher.Stage(context.Background(), fakeTx{}, AreaCreated, nil); err == nil {
+		t.Fatal("Stage succeeded although the outbox write failed, the caller would commit without it")
+	}
+}
+
+func TestBroadcastAppendsToLog(t *testing.T) {
+	publisher, _ := newTestPublisher(t)
+	ctx := context.Background()
+
+	first, _ := publisher.Stage(ctx, fakeTx{}, TruckCreated, map[string]string{"truckId": "T1"})
+	second, _ := publisher.Stage(ctx, fakeTx{}, TruckStatusChanged, map[string]string{"truckId": "T1"})
+	if err := publisher.Broadcast(ctx, first, second); err != nil {
+		t.Fatalf("Broadcast: %v", err)
+	}
+
+	logged, err := publisher.Since(ctx, "0-0")
+	if err != nil {
+		t.Fatalf("Since: %v", err)
+	}
+	if len(logged) != 2 || logged[0].Type != TruckCreated || logged[1].Type != TruckStatusChanged {
+		t.Fatalf("logged %+v, want both events in order", logged)
+	}
+	if string(logged[0].Data) != string(first.Data) || !After(logged[1].ID, logged[0].ID) {
+		t.Errorf("logged %+v, want the staged data under increasing stream IDs", logged)
+	}
+}
+
+func TestBroadcastFailureKeepsStagedEvents(t *testing.T) {
+	sink := &recordingSink{}
+	publisher, server := newTestPublisher(t, sink)
+	ctx := context.Background()
+
+	event, err := publisher.Stage(ctx, fakeTx{}, AssignmentConfirmed, map[string]string{"areaId": "A1"})
+	if err != nil {
+		t.Fatalf("Stage: %v", err)
+	}
+	server.Close()
+
+	if err := publisher.Broadcast(ctx, event); err == nil {
+		t.Fatal("Broadcast succeeded with Redis down")
+	}
+	if len(sink.events) != 1 {
+		t.Errorf("sink has %d events, the outbox row must not depend on Redis", len(sink.events))
+	}
+}
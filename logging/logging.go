@@ -0,0 +1,110 @@
+// Package logging builds the service's structured logger. Logs are JSON lines on stdout,
+// request handlers get a logger carrying the request and trace IDs from their context.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Formats
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Redacted replaces the value of sensitive attributes
+const Redacted = "[REDACTED]"
+
+// Config selects the level and format of logs
+type Config struct {
+	Level  slog.Level
+	Format string
+}
+
+// LoadConfig reads LOG_LEVEL (debug, info, warn or error) and LOG_FORMAT (json or text)
+func LoadConfig() (Config, error) {
+	cfg := Config{Level: slog.LevelInfo, Format: FormatJSON}
+
+	if raw := os.Getenv("LOG_LEVEL"); raw != "" {
+		if err := cfg.Level.UnmarshalText([]byte(raw)); err != nil {
+			return cfg, fmt.Errorf("invalid LOG_LEVEL: %q, expected debug, info, warn or error", raw)
+		}
+	}
+
+	if raw := strings.ToLower(os.Getenv("LOG_FORMAT")); raw != "" {
+		if raw != FormatJSON && raw != FormatText {
+			return cfg, fmt.Errorf("invalid LOG_FORMAT: %q, expected json or text", raw)
+		}
+		cfg.Format = raw
+	}
+
+	return cfg, nil
+}
+
+// New returns a logger writing to w that redacts sensitive attributes
+func New(cfg Config, w io.Writer) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: cfg.Level, ReplaceAttr: redact}
+	if cfg.Format == FormatText {
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
+}
+
+// sensitiveKeys are matched case-insensitively against attribute keys, ignoring - and _
+var sensitiveKeys = []string{"password", "secret", "token", "authorization", "apikey", "cookie", "credential", "dsn"}
+
+// IsSensitive reports whether an attribute or header named key must not be logged
+func IsSensitive(key string) bool {
+	key = strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(key))
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	if attr.Value.Kind() != slog.KindGroup && IsSensitive(attr.Key) {
+		return slog.String(attr.Key, Redacted)
+	}
+	return attr
+}
+
+// RedactQuery returns the raw query with sensitive parameters, e.g. access_token, redacted
+func RedactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return Redacted
+	}
+	for key := range values {
+		if IsSensitive(key) {
+			values[key] = []string{Redacted}
+		}
+	}
+	return strings.ReplaceAll(values.Encode(), url.QueryEscape(Redacted), Redacted)
+}
+
+type contextKey struct{}
+
+// WithLogger returns a copy of ctx carrying logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or the default logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestNewRedactsSensitiveAttributes(t *testing.T) {
+	var out bytes.Buffer
+	logger := New(Config{Level: slog.LevelInfo, Format: FormatJSON}, &out)
+	logger.Info("connected",
+		slog.String("database_dsn", "postgres://admin:hunter2@db/app"),
+		slog.String("X-API-Key", "wk_live_123"),
+		slog.Group("auth", slog.String("access_token", "eyJ")),
+		slog.String("area_id", "A1"),
+	)
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatalf("log line is not JSON: %v", err)
+	}
+	if line["database_dsn"] != Redacted || line["X-API-Key"] != Redacted {
+		t.Errorf("sensitive attributes logged: %v", line)
+	}
+	if group, _ := line["auth"].(map[string]interface{}); group["access_token"] != Redacted {
+		t.Errorf("attribute inside a group logged: %v", line["auth"])
+	}
+	if line["area_id"] != "A1" {
+		t.Errorf("area_id = %v, other attributes must be kept", line["area_id"])
+	}
+}
+
+func TestRedactQuery(t *testing.T) {
+	tests := []struct {
+		raw, want string
+	}{
+		{"", ""},
+		{"access_token=eyJ&status=planned", "access_token=[REDACTED]&status=planned"},
+		{"apiKey=1&apiKey=2", "apiKey=[REDACTED]"},
+		{"%zz", Redacted},
+	}
+	for _, tt := range tests {
+		if got := RedactQuery(tt.raw); got != tt.want {
+			t.Errorf("RedactQuery(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		level, format string
+		want          Config
+		wantErr       bool
+	}{
+		{"", "", Config{Level: slog.LevelInfo, Format: FormatJSON}, false},
+		{"debug", "TEXT", Config{Level: slog.LevelDebug, Format: FormatText}, false},
+		{"verbose", "", Config{}, true},
+		{"", "xml", Config{}, true},
+	}
+	for _, tt := range tests {
+		t.Setenv("LOG_LEVEL", tt.level)
+		t.Setenv("LOG_FORMAT", tt.format)
+		got, err := LoadConfig()
+		if (err != nil) != tt.wantErr || (!tt.wantErr && got != tt.want) {
+			t.Errorf("LoadConfig(%q, %q) = %+v, %v", tt.level, tt.format, got, err)
+		}
+	}
+}
@@ -1,43 +1,204 @@
 package main
 
 import (
-	"fmt"
-	"log"
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
 
 	"workship-disaster-api/db"
+	"workship-disaster-api/events"
+	"workship-disaster-api/logging"
+	"workship-disaster-api/metrics"
+	"workship-disaster-api/middleware"
+	"workship-disaster-api/models"
 	"workship-disaster-api/router"
+	"workship-disaster-api/service"
+	"workship-disaster-api/tracing"
 
 	"github.com/joho/godotenv"
 )
 
 func main() {
-	// Load .env file
-	if err := godotenv.Load(); err != nil {
-		log.Fatal("Error loading .env file")
+	// Load .env file, subcommands like simulate also run without one
+	if err := godotenv.Load(); err != nil && (len(os.Args) == 1 || !errors.Is(err, fs.ErrNotExist)) {
+		fatal("failed to load .env file", err)
 	}
 
+	// Structured logs, everything else logs through the default logger.
+	// Subcommands print their result on stdout so they log to stderr.
+	logConfig, err := logging.LoadConfig()
+	if err != nil {
+		fatal("failed to load logging config", err)
+	}
+	logOutput := os.Stdout
+	if len(os.Args) > 1 {
+		logOutput = os.Stderr
+	}
+	logger := logging.New(logConfig, logOutput)
+	slog.SetDefault(logger)
+
+	// Subcommands don't start the server
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1:])
+		return
+	}
+
+	// Tracing goes first so connection setup is already instrumented
+	tracingConfig, err := tracing.LoadConfig()
+	if err != nil {
+		fatal("failed to load tracing config", err)
+	}
+	shutdownTracing, err := tracing.Setup(context.Background(), tracingConfig)
+	if err != nil {
+		fatal("failed to set up tracing", err)
+	}
+	defer shutdownTracing(context.Background())
+
 	// เชื่อมต่อ PostgreSQL
-	dbConn, err := db.ConnectPostgres()
+	dbConn, err := db.ConnectPostgres(logger)
 	if err != nil {
-		log.Fatal(err)
+		fatal("failed to connect to postgres", err)
 	}
 	defer dbConn.Close()
 
+	// Connection pool stats for /metrics
+	metrics.RegisterDB(dbConn, os.Getenv("POSTGRES_DB"))
+
 	// Run database migrations
-	if err := db.RunMigrations(dbConn); err != nil {
-		log.Fatal("Error running migrations:", err)
+	if err := db.RunMigrations(dbConn, logger); err != nil {
+		fatal("failed to run migrations", err)
 	}
 
 	// เชื่อมต่อ Redis
 	rdb, err := db.ConnectRedis()
 	if err != nil {
-		log.Fatal(err)
+		fatal("failed to connect to redis", err)
 	}
 	defer rdb.Close()
 
+	// Known resource types, unknown ones are rejected with UNKNOWN_RESOURCE
+	resourceTypes := service.LoadResourceTypes()
+	service.RegisterResourceValidation(resourceTypes)
+
+	// Per-capita rules for areas that give a disaster type instead of unit counts
+	demand, err := service.LoadDemandConfig(resourceTypes)
+	if err != nil {
+		fatal("failed to load demand rules", err)
+	}
+
+	// Priority scoring weights
+	priority, err := service.LoadPriorityConfig()
+	if err != nil {
+		fatal("failed to load priority config", err)
+	}
+
+	plannerConfig, err := service.LoadPlannerConfig()
+	if err != nil {
+		fatal("failed to load planner config", err)
+	}
+
+	webhookConfig, err := service.LoadWebhookConfig()
+	if err != nil {
+		fatal("failed to load webhook config", err)
+	}
+
+	authConfig, err := middleware.LoadAuthConfig()
+	if err != nil {
+		fatal("failed to load auth config", err)
+	}
+
+	idempotencyConfig, err := middleware.LoadIdempotencyConfig()
+	if err != nil {
+		fatal("failed to load idempotency config", err)
+	}
+
+	deprecationConfig, err := middleware.LoadDeprecationConfig()
+	if err != nil {
+		fatal("failed to load deprecation config", err)
+	}
+
+	rateLimitConfig, err := middleware.LoadRateLimitConfig()
+	if err != nil {
+		fatal("failed to load rate limit config", err)
+	}
+
+	unknownAreas, err := service.LoadUnknownAreaPolicy()
+	if err != nil {
+		fatal("failed to load unknown area policy", err)
+	}
+	shiftLocation, err := service.LoadShiftLocation()
+	if err != nil {
+		fatal("failed to load shift time zone", err)
+	}
+
+	healthConfig, err := service.LoadHealthConfig()
+	if err != nil {
+		fatal("failed to load health config", err)
+	}
+
+	// Services shared by the API and the background planner
+	webhookService := service.NewWebhookService(dbConn, webhookConfig, logger)
+	publisher := events.NewPublisher(rdb, webhookService)
+	areaService := service.NewAreaService(dbConn, demand, publisher)
+	truckService := service.NewTruckService(dbConn, unknownAreas, shiftLocation, publisher)
+	assignmentService := service.NewAssignmentService(dbConn, areaService, truckService, priority, publisher)
+	auditService := service.NewAuditService(dbConn)
+	planner := service.NewPlannerService(dbConn, rdb, assignmentService, publisher, plannerConfig, logger)
+	hub := events.NewHub(rdb, logger)
+	apiKeyService := service.NewAPIKeyService(dbConn)
+
+	// The bootstrap key lets the first admin in to issue real keys, removing the setting revokes it
+	if authConfig.BootstrapKey != "" {
+		if err := apiKeyService.EnsureAPIKey("bootstrap-admin", authConfig.BootstrapKey, models.RoleAdmin); err != nil {
+			fatal("failed to create bootstrap API key", err)
+		}
+	} else if revoked, err := apiKeyService.RevokeConfiguredAPIKey("bootstrap-admin"); err != nil {
+		fatal("failed to revoke bootstrap API key", err)
+	} else if revoked > 0 {
+		logger.Info("revoked bootstrap API key, AUTH_BOOTSTRAP_ADMIN_KEY is no longer set")
+	}
+
+	// Background planner worker, live event fan-out and webhook outbox delivery
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go planner.Run(ctx)
+	go hub.Run(ctx)
+	go webhookService.Run(ctx)
+
 	// สร้าง API
-	r := router.SetupRouter(dbConn, rdb)
+	r := router.SetupRouter(router.Dependencies{
+		DB:          dbConn,
+		Redis:       rdb,
+		Areas:       areaService,
+		Trucks:      truckService,
+		Planner:     planner,
+		Publisher:   publisher,
+		Hub:         hub,
+		Webhooks:    webhookService,
+		APIKeys:     apiKeyService,
+		Audit:       auditService,
+		Imports:     service.NewImportService(dbConn, publisher, unknownAreas, demand),
+		Exports:     service.NewExportService(areaService, truckService),
+		DataQuality: service.NewDataQualityService(dbConn),
+		Coverage:    service.NewCoverageService(planner, areaService, truckService),
+		Auth:        middleware.NewAuthenticator(apiKeyService, authConfig),
+		Idempotency: middleware.NewIdempotency(rdb, idempotencyConfig),
+		RateLimiter: middleware.NewRateLimiter(rdb, rateLimitConfig),
+		Deprecation: deprecationConfig,
+		Health:      service.NewHealthService(dbConn, rdb, planner, healthConfig),
+		Logger:      logger,
+	})
+
+	logger.Info("server is running", slog.String("addr", ":8080"))
+	if err := r.Run(":8080"); err != nil {
+		fatal("server stopped", err)
+	}
+}
 
-	fmt.Println("Server is running on port 8080")
-	r.Run(":8080")
+// fatal logs err through the default logger and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, slog.Any("error", err))
+	os.Exit(1)
 }
@@ -0,0 +1,144 @@
+// Package metrics defines the Prometheus metrics served at /metrics.
+// Collectors live on Registry, the HTTP middleware and the services record into them.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "disaster_api"
+
+// Cache lookup results
+const (
+	CacheHit   = "hit"
+	CacheMiss  = "miss"
+	CacheError = "error"
+)
+
+// Registry holds every metric this service exposes, plus Go runtime and process metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequestDuration is labelled by route template, e.g. /api/v1/trucks/:truckId/status,
+	// so paths with IDs don't create new series
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by method, route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_in_flight",
+		Help:      "HTTP requests currently being served, including open streams.",
+	})
+
+	rateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "rate_limited_total",
+		Help:      "Requests rejected with 429 by rate limit class (read, write or compute).",
+	}, []string{"class"})
+
+	cacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "lookups_total",
+		Help:      "Redis cache lookups by key and result (hit, miss or error).",
+	}, []string{"key", "result"})
+
+	plannerDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "planner",
+		Name:      "computation_duration_seconds",
+		Help:      "Time to compute and store a plan.",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	})
+
+	plannerRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "planner",
+		Name:      "runs_total",
+		Help:      "Planner runs by result (success or error).",
+	}, []string{"result"})
+
+	plannerAreas = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "planner",
+		Name:      "areas",
+		Help:      "Areas in the latest plan by outcome (served or unserved).",
+	}, []string{"outcome"})
+
+	plannerUnmetUnits = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "planner",
+		Name:      "unmet_resource_units",
+		Help:      "Resource units required by areas the latest plan could not serve, by resource name.",
+	}, []string{"resource"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		HTTPRequestsInFlight,
+		rateLimited,
+		cacheLookups,
+		plannerDuration,
+		plannerRuns,
+		plannerAreas,
+		plannerUnmetUnits,
+	)
+}
+
+// RegisterDB exposes the connection pool stats of db, e.g. open, in-use and wait counts
+func RegisterDB(db *sql.DB, name string) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler serves the registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// RateLimited counts a request rejected by the rate limiter
+func RateLimited(class string) {
+	rateLimited.WithLabelValues(class).Inc()
+}
+
+// CacheLookup counts a Redis cache read, result is CacheHit, CacheMiss or CacheError
+func CacheLookup(key, result string) {
+	cacheLookups.WithLabelValues(key, result).Inc()
+}
+
+// PlannerRun records a planner run, failed runs only count towards runs_total
+func PlannerRun(duration time.Duration, err error) {
+	if err != nil {
+		plannerRuns.WithLabelValues("error").Inc()
+		return
+	}
+	plannerRuns.WithLabelValues("success").Inc()
+	plannerDuration.Observe(duration.Seconds())
+}
+
+// PlanOutcome replaces the served, unserved and unmet resource gauges with those of the latest plan
+func PlanOutcome(served, unserved int, unmet map[string]int) {
+	plannerAreas.WithLabelValues("served").Set(float64(served))
+	plannerAreas.WithLabelValues("unserved").Set(float64(unserved))
+
+	// Resources that are no longer short drop out instead of reporting stale values
+	plannerUnmetUnits.Reset()
+	for resource, units := range unmet {
+		plannerUnmetUnits.WithLabelValues(resource).Set(float64(units))
+	}
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// TestPlanOutcomeDropsStaleResources checks a resource that is no longer short stops being reported
+func TestPlanOutcomeDropsStaleResources(t *testing.T) {
+	PlanOutcome(3, 2, map[string]int{"water": 500, "food": 40})
+	PlanOutcome(4, 1, map[string]int{"water": 200})
+
+	if got := testutil.CollectAndCount(plannerUnmetUnits); got != 1 {
+		t.Errorf("unmet_resource_units has %d series, want only water", got)
+	}
+	if got := testutil.ToFloat64(plannerUnmetUnits.WithLabelValues("water")); got != 200 {
+		t.Errorf("unmet water = %v, want 200", got)
+	}
+	if got := testutil.ToFloat64(plannerAreas.WithLabelValues("unserved")); got != 1 {
+		t.Errorf("unserved areas = %v, want 1", got)
+	}
+}
+
+// TestPlannerRunCountsFailures checks failed runs are counted without a duration sample
+func TestPlannerRunCountsFailures(t *testing.T) {
+	PlannerRun(time.Second, nil)
+	PlannerRun(time.Second, errors.New("database unavailable"))
+
+	if got := testutil.ToFloat64(plannerRuns.WithLabelValues("error")); got != 1 {
+		t.Errorf("failed runs = %v, want 1", got)
+	}
+	scrape := httptest.NewRecorder()
+	Handler().ServeHTTP(scrape, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if want := "disaster_api_planner_computation_duration_seconds_count 1\n"; !strings.Contains(scrape.Body.String(), want) {
+		t.Errorf("metrics are missing %q, only the successful run is timed", want)
+	}
+}
@@ -0,0 +1,254 @@
+package middleware
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"workship-disaster-api/apperr"
+	"workship-disaster-api/models"
+	"workship-disaster-api/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// principalKey is where the authenticated caller is stored on the gin context
+const principalKey = "principal"
+
+// Principal is the authenticated caller
+type Principal struct {
+	Subject string `json:"subject"`
+	Role    string `json:"role"`
+	// Method is "api_key" or "jwt"
+	Method string `json:"method"`
+	KeyID  int64  `json:"keyId,omitempty"`
+}
+
+// Claims expected in JWTs, role must be one of viewer, dispatcher or admin
+type Claims struct {
+	Role string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// AuthConfig configures which JWT algorithms are accepted
+type AuthConfig struct {
+	HS256Secret  []byte
+	RS256Public  *rsa.PublicKey
+	Issuer       string
+	Audience     string
+	BootstrapKey string
+}
+
+// LoadAuthConfig reads AUTH_* environment variables
+func LoadAuthConfig() (AuthConfig, error) {
+	cfg := AuthConfig{
+		HS256Secret:  []byte(os.Getenv("AUTH_JWT_HS256_SECRET")),
+		Issuer:       os.Getenv("AUTH_JWT_ISSUER"),
+		Audience:     os.Getenv("AUTH_JWT_AUDIENCE"),
+		BootstrapKey: os.Getenv("AUTH_BOOTSTRAP_ADMIN_KEY"),
+	}
+
+	if len(cfg.HS256Secret) > 0 && len(cfg.HS256Secret) < 32 {
+		return cfg, errors.New("AUTH_JWT_HS256_SECRET must be at least 32 bytes")
+	}
+
+	if cfg.BootstrapKey != "" && (!strings.HasPrefix(cfg.BootstrapKey, "dk_") || len(cfg.BootstrapKey) < 24) {
+		return cfg, errors.New("AUTH_BOOTSTRAP_ADMIN_KEY must start with dk_ and be at least 24 characters")
+	}
+
+	if path := os.Getenv("AUTH_JWT_RS256_PUBLIC_KEY_FILE"); path != "" {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to read AUTH_JWT_RS256_PUBLIC_KEY_FILE: %v", err)
+		}
+		if cfg.RS256Public, err = jwt.ParseRSAPublicKeyFromPEM(pem); err != nil {
+			return cfg, fmt.Errorf("invalid AUTH_JWT_RS256_PUBLIC_KEY_FILE: %v", err)
+		}
+	}
+
+	return cfg, nil
+}
+
+// IssueHS256Token signs a token for local use, e.g. from the "token" CLI subcommand
+func IssueHS256Token(cfg AuthConfig, subject, role string, ttl time.Duration) (string, error) {
+	if len(cfg.HS256Secret) == 0 {
+		return "", errors.New("AUTH_JWT_HS256_SECRET is not set")
+	}
+	if models.RoleRank(role) == 0 {
+		return "", fmt.Errorf("unknown role %q", role)
+	}
+
+	now := time.Now()
+	claims := Claims{
+		Role: role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			Issuer:    cfg.Issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	if cfg.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{cfg.Audience}
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(cfg.HS256Secret)
+}
+
+// Authenticator checks API keys and JWTs and enforces roles
+type Authenticator struct {
+	keys   *service.APIKeyService
+	config AuthConfig
+	parser *jwt.Parser
+}
+
+// NewAuthenticator ...
+func NewAuthenticator(keys *service.APIKeyService, config AuthConfig) *Authenticator {
+	var methods []string
+	if len(config.HS256Secret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if config.RS256Public != nil {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+
+	options := []jwt.ParserOption{jwt.WithValidMethods(methods), jwt.WithExpirationRequired()}
+	if config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(config.Issuer))
+	}
+	if config.Audience != "" {
+		options = append(options, jwt.WithAudience(config.Audience))
+	}
+
+	return &Authenticator{
+		keys:   keys,
+		config: config,
+		parser: jwt.NewParser(options...),
+	}
+}
+
+// Require rejects callers without a role of at least minRole
+func (a *Authenticator) Require(minRole string) gin.HandlerFunc {
+	return a.require(minRole, false)
+}
+
+// RequireStream is Require for SSE and WebSocket routes, where browsers can't set headers,
+// so an access_token query parameter is also accepted
+func (a *Authenticator) RequireStream(minRole string) gin.HandlerFunc {
+	return a.require(minRole, true)
+}
+
+func (a *Authenticator) require(minRole string, allowQuery bool) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		principal, err := a.authenticate(ctx, allowQuery)
+		if err != nil {
+			ctx.Header("WWW-Authenticate", `Bearer realm="disaster-api"`)
+			apperr.Respond(ctx, apperr.New(http.StatusUnauthorized, apperr.CodeUnauthenticated, "Authentication required: "+err.Error()))
+			return
+		}
+
+		if models.RoleRank(principal.Role) < models.RoleRank(minRole) {
+			apperr.Respond(ctx, apperr.New(http.StatusForbidden, apperr.CodeForbidden, "Role "+minRole+" required"))
+			return
+		}
+
+		ctx.Set(principalKey, principal)
+		ctx.Next()
+	}
+}
+
+func (a *Authenticator) authenticate(ctx *gin.Context, allowQuery bool) (*Principal, error) {
+	if key := ctx.GetHeader("X-API-Key"); key != "" {
+		return a.authenticateKey(key)
+	}
+
+	credential := ""
+	if header := ctx.GetHeader("Authorization"); header != "" {
+		scheme, value, found := strings.Cut(header, " ")
+		if !found {
+			return nil, errors.New("malformed Authorization header")
+		}
+		switch strings.ToLower(scheme) {
+		case "apikey":
+			return a.authenticateKey(value)
+		case "bearer":
+			credential = value
+		default:
+			return nil, errors.New("unsupported Authorization scheme")
+		}
+	} else if allowQuery {
+		credential = ctx.Query("access_token")
+	}
+
+	if credential == "" {
+		return nil, errors.New("missing credentials")
+	}
+
+	// API keys may also be sent as bearer tokens
+	if strings.HasPrefix(credential, "dk_") {
+		return a.authenticateKey(credential)
+	}
+	return a.authenticateJWT(credential)
+}
+
+func (a *Authenticator) authenticateKey(key string) (*Principal, error) {
+	apiKey, err := a.keys.Authenticate(key)
+	if err != nil {
+		if errors.Is(err, service.ErrAPIKeyInvalid) {
+			return nil, err
+		}
+		// Don't leak database errors to unauthenticated callers
+		return nil, errors.New("failed to check api key")
+	}
+
+	return &Principal{
+		Subject: "api_key:" + apiKey.Name,
+		Role:    apiKey.Role,
+		Method:  "api_key",
+		KeyID:   apiKey.ID,
+	}, nil
+}
+
+func (a *Authenticator) authenticateJWT(token string) (*Principal, error) {
+	if len(a.config.HS256Secret) == 0 && a.config.RS256Public == nil {
+		return nil, errors.New("JWT authentication is not configured")
+	}
+
+	var claims Claims
+	_, err := a.parser.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		switch t.Method.Alg() {
+		case jwt.SigningMethodHS256.Alg():
+			return a.config.HS256Secret, nil
+		case jwt.SigningMethodRS256.Alg():
+			return a.config.RS256Public, nil
+		}
+		return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %v", err)
+	}
+
+	if claims.Subject == "" || models.RoleRank(claims.Role) == 0 {
+		return nil, errors.New("token must carry a subject and a known role")
+	}
+
+	return &Principal{
+		Subject: claims.Subject,
+		Role:    claims.Role,
+		Method:  "jwt",
+	}, nil
+}
+
+// CurrentPrincipal returns the authenticated caller, if any
+func CurrentPrincipal(ctx *gin.Context) *Principal {
+	value, ok := ctx.Get(principalKey)
+	if !ok {
+		return nil
+	}
+	principal, _ := value.(*Principal)
+	return principal
+}
@@ -0,0 +1,217 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"time"
+	"workship-disaster-api/apperr"
+	"workship-disaster-api/logging"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// Idempotency headers, the replayed header marks responses served from the store
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+)
+
+const maxIdempotencyKeyLength = 255
+
+// IdempotencyConfig controls how long responses are kept for replay
+type IdempotencyConfig struct {
+	// Retention is how long a completed response is replayed for its key
+	Retention time.Duration
+	// LockTimeout releases a key whose first request never finished, e.g. after a crash.
+	// It must be longer than any request may run, or a retry would run the handler again.
+	LockTimeout time.Duration
+}
+
+// LoadIdempotencyConfig reads IDEMPOTENCY_RETENTION and IDEMPOTENCY_LOCK_TIMEOUT
+func LoadIdempotencyConfig() (IdempotencyConfig, error) {
+	cfg := IdempotencyConfig{
+		Retention:   24 * time.Hour,
+		LockTimeout: 5 * time.Minute,
+	}
+
+	durations := map[string]*time.Duration{
+		"IDEMPOTENCY_RETENTION":    &cfg.Retention,
+		"IDEMPOTENCY_LOCK_TIMEOUT": &cfg.LockTimeout,
+	}
+	for key, target := range durations {
+		raw := os.Getenv(key)
+		if raw == "" {
+			continue
+		}
+		value, err := time.ParseDuration(raw)
+		if err != nil || value <= 0 {
+			return cfg, fmt.Errorf("invalid %s: %q", key, raw)
+		}
+		*target = value
+	}
+	if cfg.LockTimeout > cfg.Retention {
+		return cfg, fmt.Errorf("invalid IDEMPOTENCY_LOCK_TIMEOUT: %s, must not exceed IDEMPOTENCY_RETENTION %s", cfg.LockTimeout, cfg.Retention)
+	}
+
+	return cfg, nil
+}
+
+// idempotencyRecord is what is stored in Redis for a key, Status is 0 while the first request runs
+type idempotencyRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// Idempotency replays the stored response when a request is retried with the same
+// Idempotency-Key and body. Reusing a key with a different body is rejected with 422,
+// and a retry that arrives while the first request is still running gets 409.
+// Keys are scoped to the caller and route, requests without the header pass through.
+// It must run after authentication.
+type Idempotency struct {
+	rdb    *redis.Client
+	config IdempotencyConfig
+}
+
+// NewIdempotency ...
+func NewIdempotency(rdb *redis.Client, config IdempotencyConfig) *Idempotency {
+	return &Idempotency{rdb: rdb, config: config}
+}
+
+// Handler returns the middleware
+func (i *Idempotency) Handler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		key := ctx.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			ctx.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			apperr.Respond(ctx, apperr.BadRequest(fmt.Sprintf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLength)))
+			return
+		}
+
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			apperr.Respond(ctx, apperr.Binding(err))
+			return
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		fingerprint := sha256.Sum256(body)
+		record := idempotencyRecord{Fingerprint: hex.EncodeToString(fingerprint[:])}
+		storeKey := i.storeKey(ctx, key)
+
+		// Claim the key, only the first request runs the handler
+		claim, _ := json.Marshal(record)
+		claimed, err := i.rdb.SetNX(ctx, storeKey, claim, i.config.LockTimeout).Result()
+		if err != nil {
+			// Running without the store could dispatch twice, so refuse instead
+			apperr.Respond(ctx, apperr.Unavailable(err, "Idempotency store unavailable, retry later"))
+			return
+		}
+		if !claimed {
+			i.replay(ctx, storeKey, record.Fingerprint)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: ctx.Writer}
+		ctx.Writer = recorder
+		ctx.Next()
+
+		// Server errors are not kept so the client can retry them
+		// Use a fresh context, the request's may already be cancelled
+		storeCtx := context.Background()
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			if err := i.rdb.Del(storeCtx, storeKey).Err(); err != nil {
+				logging.FromContext(ctx.Request.Context()).Error("failed to release idempotency key", slog.Any("error", err))
+			}
+			return
+		}
+
+		record.Status = status
+		record.ContentType = recorder.Header().Get("Content-Type")
+		record.Body = recorder.body.Bytes()
+		stored, _ := json.Marshal(record)
+		if err := i.rdb.Set(storeCtx, storeKey, stored, i.config.Retention).Err(); err != nil {
+			logging.FromContext(ctx.Request.Context()).Error("failed to store idempotent response", slog.Any("error", err))
+		}
+	}
+}
+
+// replay answers a retry from the stored record
+func (i *Idempotency) replay(ctx *gin.Context, storeKey, fingerprint string) {
+	raw, err := i.rdb.Get(ctx, storeKey).Bytes()
+	if err == redis.Nil {
+		// The first request just failed and released the key
+		apperr.Respond(ctx, apperr.Conflict(apperr.CodeIdempotencyInProgress, "A request with this Idempotency-Key is still being processed, retry later"))
+		return
+	}
+	if err != nil {
+		apperr.Respond(ctx, apperr.Unavailable(err, "Idempotency store unavailable, retry later"))
+		return
+	}
+
+	var record idempotencyRecord
+	if err := json.Unmarshal(raw, &record); err != nil {
+		apperr.Respond(ctx, apperr.Internal(err, "Failed to read stored response"))
+		return
+	}
+
+	if record.Fingerprint != fingerprint {
+		apperr.Respond(ctx, apperr.New(http.StatusUnprocessableEntity, apperr.CodeIdempotencyKeyReused, "Idempotency-Key was already used with a different request body"))
+		return
+	}
+	if record.Status == 0 {
+		ctx.Header("Retry-After", "1")
+		apperr.Respond(ctx, apperr.Conflict(apperr.CodeIdempotencyInProgress, "A request with this Idempotency-Key is still being processed, retry later"))
+		return
+	}
+
+	ctx.Header(IdempotencyReplayedHeader, "true")
+	ctx.Data(record.Status, record.ContentType, record.Body)
+	ctx.Abort()
+}
+
+// apiVersionPrefix matches /api, /api/v1, /api/v2 and so on at the start of a path
+var apiVersionPrefix = regexp.MustCompile(`^/api(/v[0-9]+)?/`)
+
+// storeKey scopes the key to the caller and route so keys can't collide across clients.
+// The API version is left out, a retry of a v1 request through v2 or the legacy alias replays it.
+func (i *Idempotency) storeKey(ctx *gin.Context, key string) string {
+	subject := ""
+	if principal := CurrentPrincipal(ctx); principal != nil {
+		subject = principal.Subject
+	}
+	route := apiVersionPrefix.ReplaceAllString(ctx.Request.URL.Path, "/")
+	scope := sha256.Sum256([]byte(subject + "\x00" + ctx.Request.Method + "\x00" + route + "\x00" + key))
+	return "idempotency:" + hex.EncodeToString(scope[:])
+}
+
+// responseRecorder keeps a copy of the response body while writing it through
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+func idempotentRouter(t *testing.T, calls *int) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	server := miniredis.RunT(t)
+	idempotency := NewIdempotency(redis.NewClient(&redis.Options{Addr: server.Addr()}), IdempotencyConfig{Retention: time.Hour, LockTimeout: time.Minute})
+
+	router := gin.New()
+	handler := func(ctx *gin.Context) {
+		*calls++
+		ctx.JSON(http.StatusCreated, gin.H{"area": ctx.Param("areaId"), "call": *calls})
+	}
+	for _, prefix := range []string{"/api", "/api/v1", "/api/v2"} {
+		router.POST(prefix+"/assignments/:areaId/confirm", idempotency.Handler(), handler)
+	}
+	return router
+}
+
+func postWithKey(router *gin.Engine, path, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set(IdempotencyKeyHeader, key)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+// TestIdempotencyKeyIgnoresVersion checks a retry through another API version replays the first response
+func TestIdempotencyKeyIgnoresVersion(t *testing.T) {
+	var calls int
+	router := idempotentRouter(t, &calls)
+
+	first := postWithKey(router, "/api/v1/assignments/A1/confirm", "retry-1", "{}")
+	for _, path := range []string{"/api/v2/assignments/A1/confirm", "/api/assignments/A1/confirm"} {
+		retry := postWithKey(router, path, "retry-1", "{}")
+		if retry.Header().Get(IdempotencyReplayedHeader) != "true" || retry.Body.String() != first.Body.String() {
+			t.Errorf("%s: got %d %s, want the v1 response replayed", path, retry.Code, retry.Body)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("handler ran %d times, want once", calls)
+	}
+}
+
+func TestIdempotencyKeyScopedToPath(t *testing.T) {
+	var calls int
+	router := idempotentRouter(t, &calls)
+
+	postWithKey(router, "/api/v1/assignments/A1/confirm", "retry-1", "{}")
+	other := postWithKey(router, "/api/v1/assignments/A2/confirm", "retry-1", "{}")
+	if other.Header().Get(IdempotencyReplayedHeader) != "" || calls != 2 {
+		t.Errorf("the same key on another area was replayed, calls %d", calls)
+	}
+
+	reused := postWithKey(router, "/api/v2/assignments/A1/confirm", "retry-1", `{"note":"changed"}`)
+	if reused.Code != http.StatusUnprocessableEntity {
+		t.Errorf("reused key with another body got %d, want 422", reused.Code)
+	}
+}
+
+func TestLoadIdempotencyConfig(t *testing.T) {
+	tests := []struct {
+		env     map[string]string
+		want    IdempotencyConfig
+		wantErr bool
+	}{
+		{env: nil, want: IdempotencyConfig{Retention: 24 * time.Hour, LockTimeout: 5 * time.Minute}},
+		{env: map[string]string{"IDEMPOTENCY_LOCK_TIMEOUT": "15m"}, want: IdempotencyConfig{Retention: 24 * time.Hour, LockTimeout: 15 * time.Minute}},
+		{env: map[string]string{"IDEMPOTENCY_LOCK_TIMEOUT": "0s"}, wantErr: true},
+		{env: map[string]string{"IDEMPOTENCY_RETENTION": "1m"}, wantErr: true},
+		{env: map[string]string{"IDEMPOTENCY_RETENTION": "soon"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.env), func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			got, err := LoadIdempotencyConfig()
+			if (err != nil) != tt.wantErr || (!tt.wantErr && got != tt.want) {
+				t.Errorf("got %+v, %v", got, err)
+			}
+		})
+	}
+}
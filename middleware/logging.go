@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+	"workship-disaster-api/apperr"
+	"workship-disaster-api/logging"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Logger gives each request a logger carrying its request and trace IDs, handlers and
+// services get it with logging.FromContext. One access line is logged per request.
+// It must run after RequestID and the tracing middleware.
+func Logger(base *slog.Logger) gin.HandlerFunc {
+	if base == nil {
+		base = slog.Default()
+	}
+
+	return func(ctx *gin.Context) {
+		start := time.Now()
+
+		attrs := []any{slog.String("request_id", GetRequestID(ctx))}
+		if span := trace.SpanContextFromContext(ctx.Request.Context()); span.HasTraceID() {
+			attrs = append(attrs, slog.String("trace_id", span.TraceID().String()), slog.String("span_id", span.SpanID().String()))
+		}
+		logger := base.With(attrs...)
+		ctx.Request = ctx.Request.WithContext(logging.WithLogger(ctx.Request.Context(), logger))
+
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		logger.LogAttrs(ctx.Request.Context(), level, "request",
+			slog.String("method", ctx.Request.Method),
+			slog.String("route", route),
+			slog.String("path", ctx.Request.URL.Path),
+			slog.String("query", logging.RedactQuery(ctx.Request.URL.RawQuery)),
+			slog.Int("status", status),
+			slog.Int("bytes", max(ctx.Writer.Size(), 0)),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("client_ip", ctx.ClientIP()),
+		)
+	}
+}
+
+// Recovery turns a panicking handler into a 500 problem document, the panic and
+// stack are logged with the request ID
+func Recovery() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			logging.FromContext(ctx.Request.Context()).Error("panic recovered",
+				slog.Any("panic", recovered),
+				slog.String("stack", string(debug.Stack())),
+			)
+			if ctx.Writer.Written() {
+				ctx.Abort()
+				return
+			}
+			apperr.Respond(ctx, apperr.Internal(fmt.Errorf("panic: %v", recovered), "An unexpected error occurred"))
+		}()
+		ctx.Next()
+	}
+}
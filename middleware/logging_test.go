@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"workship-disaster-api/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestLoggerCarriesRequestID checks handler logs and the access line share the request ID and
+// that tokens in the query string are redacted
+func TestLoggerCarriesRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var out bytes.Buffer
+	r := gin.New()
+	r.Use(RequestID(), Logger(logging.New(logging.Config{Level: slog.LevelInfo, Format: logging.FormatJSON}, &out)))
+	r.GET("/trucks/:truckId", func(ctx *gin.Context) {
+		logging.FromContext(ctx.Request.Context()).Info("handler")
+		ctx.Status(http.StatusNotFound)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/trucks/T1?access_token=eyJ", nil)
+	req.Header.Set(RequestIDHeader, "req-42")
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+
+	if got := res.Header().Get(RequestIDHeader); got != "req-42" {
+		t.Errorf("%s = %q, want the caller's ID echoed", RequestIDHeader, got)
+	}
+	var lines []map[string]interface{}
+	for decoder := json.NewDecoder(&out); decoder.More(); {
+		var line map[string]interface{}
+		if err := decoder.Decode(&line); err != nil {
+			t.Fatalf("log line is not JSON: %v", err)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("logged %d lines, want the handler's and the access line", len(lines))
+	}
+	for _, line := range lines {
+		if line["request_id"] != "req-42" {
+			t.Errorf("%v has request_id %v", line["msg"], line["request_id"])
+		}
+	}
+	access := lines[1]
+	if access["level"] != "WARN" || access["route"] != "/trucks/:truckId" || access["query"] != "access_token="+logging.Redacted {
+		t.Errorf("access line = %v", access)
+	}
+}
+
+func TestRequestIDReplacesUnsafeIDs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestID())
+	r.GET("/", func(ctx *gin.Context) { ctx.String(http.StatusOK, GetRequestID(ctx)) })
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "bad id\nforged=1")
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+
+	if id := res.Body.String(); len(id) != 32 || id != res.Header().Get(RequestIDHeader) {
+		t.Errorf("request ID = %q, want a generated one in the body and header", id)
+	}
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+	"workship-disaster-api/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unmatchedRoute labels requests that matched no route, so scanners can't create new series
+const unmatchedRoute = "unmatched"
+
+// Metrics records the latency of every request by method, route template and status
+func Metrics() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		started := time.Now()
+		metrics.HTTPRequestsInFlight.Inc()
+		defer metrics.HTTPRequestsInFlight.Dec()
+
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		metrics.HTTPRequestDuration.
+			WithLabelValues(ctx.Request.Method, route, strconv.Itoa(ctx.Writer.Status())).
+			Observe(time.Since(started).Seconds())
+	}
+}
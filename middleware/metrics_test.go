@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"workship-disaster-api/metrics"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// TestMetricsLabelsRouteTemplate checks requests are counted under their route template, so IDs
+// and unmatched paths can't create new series
+func TestMetricsLabelsRouteTemplate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Metrics())
+	r.GET("/trucks/:truckId", func(ctx *gin.Context) { ctx.Status(http.StatusNoContent) })
+
+	for _, path := range []string{"/trucks/T1", "/trucks/T2", "/wp-login.php", "/.env"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	scrape := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(scrape, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := scrape.Body.String()
+	for _, want := range []string{
+		`disaster_api_http_request_duration_seconds_count{method="GET",route="/trucks/:truckId",status="204"} 2`,
+		`disaster_api_http_request_duration_seconds_count{method="GET",route="unmatched",status="404"} 2`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics are missing %s", want)
+		}
+	}
+	for _, path := range []string{"T1", "wp-login"} {
+		if strings.Contains(body, path) {
+			t.Errorf("metrics carry the request path %s", path)
+		}
+	}
+	if got := testutil.ToFloat64(metrics.HTTPRequestsInFlight); got != 0 {
+		t.Errorf("requests_in_flight = %v after every request finished", got)
+	}
+}
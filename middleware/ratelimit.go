@@ -0,0 +1,209 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"workship-disaster-api/apperr"
+	"workship-disaster-api/logging"
+	"workship-disaster-api/metrics"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// Rate limit classes, each has its own budget per client
+const (
+	RateClassRead    = "read"
+	RateClassWrite   = "write"
+	RateClassCompute = "compute"
+)
+
+// RateBudget is a token bucket holding up to Requests tokens, refilled evenly over Per.
+// A zero budget disables limiting for its class.
+type RateBudget struct {
+	Requests int
+	Per      time.Duration
+}
+
+// RateLimitConfig holds the budget of every class and the request body limit
+type RateLimitConfig struct {
+	Budgets map[string]RateBudget
+	// MaxBodyBytes caps JSON request bodies, imports have their own larger limit
+	MaxBodyBytes int64
+}
+
+// LoadRateLimitConfig reads RATE_LIMIT_READ, RATE_LIMIT_WRITE, RATE_LIMIT_COMPUTE and MAX_BODY_BYTES.
+// Budgets are "<requests>/<duration>", e.g. "600/1m", or "off".
+func LoadRateLimitConfig() (RateLimitConfig, error) {
+	cfg := RateLimitConfig{
+		Budgets: map[string]RateBudget{
+			RateClassRead:    {Requests: 600, Per: time.Minute},
+			RateClassWrite:   {Requests: 120, Per: time.Minute},
+			RateClassCompute: {Requests: 10, Per: time.Minute},
+		},
+		MaxBodyBytes: 1 << 20,
+	}
+
+	for class, env := range map[string]string{
+		RateClassRead:    "RATE_LIMIT_READ",
+		RateClassWrite:   "RATE_LIMIT_WRITE",
+		RateClassCompute: "RATE_LIMIT_COMPUTE",
+	} {
+		raw := os.Getenv(env)
+		if raw == "" {
+			continue
+		}
+		budget, err := parseRateBudget(raw)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid %s: %q, expected <requests>/<duration> or off", env, raw)
+		}
+		cfg.Budgets[class] = budget
+	}
+
+	if raw := os.Getenv("MAX_BODY_BYTES"); raw != "" {
+		value, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || value <= 0 {
+			return cfg, fmt.Errorf("invalid MAX_BODY_BYTES: %q", raw)
+		}
+		cfg.MaxBodyBytes = value
+	}
+
+	return cfg, nil
+}
+
+func parseRateBudget(raw string) (RateBudget, error) {
+	if strings.EqualFold(raw, "off") {
+		return RateBudget{}, nil
+	}
+	requests, per, ok := strings.Cut(raw, "/")
+	if !ok {
+		return RateBudget{}, fmt.Errorf("missing /")
+	}
+	n, err := strconv.Atoi(requests)
+	if err != nil || n <= 0 {
+		return RateBudget{}, fmt.Errorf("invalid request count")
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return RateBudget{}, fmt.Errorf("invalid duration")
+	}
+	return RateBudget{Requests: n, Per: d}, nil
+}
+
+// tokenBucket takes one token from the bucket at KEYS[1] if it has one.
+// ARGV: capacity, refill rate in tokens per millisecond. The time comes from the Redis server,
+// replica clocks may drift apart and would hand out tokens early or late.
+// Returns whether the request is allowed, the tokens left and the milliseconds until the next token.
+var tokenBucket = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(bucket[1]) or capacity
+local ts = tonumber(bucket[2]) or now
+if now > ts then
+	tokens = math.min(capacity, tokens + (now - ts) * rate)
+	ts = now
+end
+
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate)
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", ts)
+redis.call("PEXPIRE", KEYS[1], math.ceil(capacity / rate) + 1000)
+return {allowed, math.floor(tokens), wait}
+`)
+
+// RateLimiter throttles each client with token buckets kept in Redis, so the budget holds
+// across replicas. Clients are identified by API key or token subject, anonymous callers by IP.
+// When Redis is unavailable requests are let through rather than failing the API.
+type RateLimiter struct {
+	rdb    *redis.Client
+	config RateLimitConfig
+}
+
+// NewRateLimiter ...
+func NewRateLimiter(rdb *redis.Client, config RateLimitConfig) *RateLimiter {
+	return &RateLimiter{rdb: rdb, config: config}
+}
+
+// Limit charges every request to the class budget. It must run after authentication.
+func (l *RateLimiter) Limit(class string) gin.HandlerFunc {
+	budget := l.config.Budgets[class]
+	if budget.Requests == 0 {
+		return func(ctx *gin.Context) { ctx.Next() }
+	}
+	perMillisecond := float64(budget.Requests) / float64(budget.Per.Milliseconds())
+
+	return func(ctx *gin.Context) {
+		client := "ip:" + ctx.ClientIP()
+		if principal := CurrentPrincipal(ctx); principal != nil {
+			client = principal.Subject
+		}
+		key := "ratelimit:" + class + ":" + client
+
+		result, err := tokenBucket.Run(ctx, l.rdb, []string{key}, budget.Requests, perMillisecond).Int64Slice()
+		if err != nil {
+			logging.FromContext(ctx.Request.Context()).Warn("rate limiter unavailable, request let through", slog.String("class", class), slog.Any("error", err))
+			ctx.Next()
+			return
+		}
+		allowed, remaining, wait := result[0] == 1, result[1], result[2]
+
+		ctx.Header("RateLimit-Limit", strconv.Itoa(budget.Requests))
+		ctx.Header("RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+		ctx.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", budget.Requests, int(budget.Per.Seconds())))
+		if !allowed {
+			retryAfter := int(math.Max(1, math.Ceil(float64(wait)/1000)))
+			ctx.Header("Retry-After", strconv.Itoa(retryAfter))
+			metrics.RateLimited(class)
+			apperr.Respond(ctx, apperr.New(http.StatusTooManyRequests, apperr.CodeRateLimited,
+				fmt.Sprintf("Too many %s requests, retry in %d seconds", class, retryAfter)))
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// ByMethod charges safe methods to the read budget and everything else to the write budget
+func (l *RateLimiter) ByMethod() gin.HandlerFunc {
+	read, write := l.Limit(RateClassRead), l.Limit(RateClassWrite)
+	return func(ctx *gin.Context) {
+		switch ctx.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			read(ctx)
+		default:
+			write(ctx)
+		}
+	}
+}
+
+// BodyLimit rejects request bodies over the configured size with 413. Declared lengths are
+// checked up front, chunked bodies fail when the handler reads past the limit.
+func (l *RateLimiter) BodyLimit() gin.HandlerFunc {
+	limit := l.config.MaxBodyBytes
+	return func(ctx *gin.Context) {
+		if ctx.Request.ContentLength > limit {
+			apperr.Respond(ctx, apperr.New(http.StatusRequestEntityTooLarge, apperr.CodePayloadTooLarge, fmt.Sprintf("The request body is larger than %d bytes", limit)))
+			return
+		}
+		if ctx.Request.Body != nil {
+			ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, limit)
+		}
+		ctx.Next()
+	}
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestParseRateBudget(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    RateBudget
+		wantErr bool
+	}{
+		{raw: "600/1m", want: RateBudget{Requests: 600, Per: time.Minute}},
+		{raw: "5/30s", want: RateBudget{Requests: 5, Per: 30 * time.Second}},
+		{raw: "OFF", want: RateBudget{}},
+		{raw: "600", wantErr: true},
+		{raw: "0/1m", wantErr: true},
+		{raw: "-1/1m", wantErr: true},
+		{raw: "ten/1m", wantErr: true},
+		{raw: "10/0s", wantErr: true},
+		{raw: "10/minute", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseRateBudget(tt.raw)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseRateBudget(%q) = %+v, %v, want %+v, error %v", tt.raw, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// TestTokenBucket checks the bucket empties, refills on the Redis server's clock and reports the wait
+func TestTokenBucket(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := miniredis.RunT(t)
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	server.SetTime(start)
+
+	limiter := NewRateLimiter(redis.NewClient(&redis.Options{Addr: server.Addr()}), RateLimitConfig{
+		Budgets: map[string]RateBudget{RateClassCompute: {Requests: 2, Per: time.Minute}},
+	})
+	router := gin.New()
+	router.POST("/plan", limiter.Limit(RateClassCompute), func(ctx *gin.Context) { ctx.Status(http.StatusNoContent) })
+	request := func() *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/plan", nil))
+		return recorder
+	}
+
+	for i, wantRemaining := range []string{"1", "0"} {
+		if got := request(); got.Code != http.StatusNoContent || got.Header().Get("RateLimit-Remaining") != wantRemaining {
+			t.Fatalf("request %d = %d, remaining %q, want allowed with %s left", i+1, got.Code, got.Header().Get("RateLimit-Remaining"), wantRemaining)
+		}
+	}
+	limited := request()
+	if limited.Code != http.StatusTooManyRequests || limited.Header().Get("Retry-After") != "30" {
+		t.Fatalf("third request = %d, Retry-After %q, want 429 after 30 seconds", limited.Code, limited.Header().Get("Retry-After"))
+	}
+
+	// Half the refill interval isn't enough for a token
+	server.SetTime(start.Add(15 * time.Second))
+	if got := request(); got.Code != http.StatusTooManyRequests || got.Header().Get("Retry-After") != "15" {
+		t.Fatalf("after 15s = %d, Retry-After %q, want 429 for another 15 seconds", got.Code, got.Header().Get("Retry-After"))
+	}
+	server.SetTime(start.Add(30 * time.Second))
+	if got := request(); got.Code != http.StatusNoContent {
+		t.Fatalf("after 30s = %d, want a refilled token", got.Code)
+	}
+
+	// A long pause refills to capacity, not beyond
+	server.SetTime(start.Add(time.Hour))
+	if got := request(); got.Header().Get("RateLimit-Remaining") != "1" {
+		t.Errorf("after an hour remaining %q, want the bucket capped at 2", got.Header().Get("RateLimit-Remaining"))
+	}
+}
+
+func TestRateLimitOff(t *testing.T) {
+	limiter := NewRateLimiter(nil, RateLimitConfig{Budgets: map[string]RateBudget{}})
+	router := gin.New()
+	router.GET("/areas", limiter.Limit(RateClassRead), func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/areas", nil))
+	if recorder.Code != http.StatusOK || recorder.Header().Get("RateLimit-Limit") != "" {
+		t.Errorf("got %d with headers %v, want the request through without touching Redis", recorder.Code, recorder.Header())
+	}
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const apiVersionKey = "apiVersion"
+
+// Versions of the API, v1 keeps the original payloads and v2 is camelCase throughout
+const (
+	APIVersion1 = 1
+	APIVersion2 = 2
+)
+
+// DeprecationConfig dates the unversioned /api aliases
+type DeprecationConfig struct {
+	// DeprecatedAt is when the aliases were deprecated
+	DeprecatedAt time.Time
+	// Sunset is when they will be removed
+	Sunset time.Time
+}
+
+// LoadDeprecationConfig reads API_LEGACY_SUNSET, a YYYY-MM-DD date
+func LoadDeprecationConfig() (DeprecationConfig, error) {
+	cfg := DeprecationConfig{
+		DeprecatedAt: time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC),
+		Sunset:       time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC),
+	}
+
+	if raw := os.Getenv("API_LEGACY_SUNSET"); raw != "" {
+		value, err := time.Parse("2006-01-02", raw)
+		if err != nil || !value.After(cfg.DeprecatedAt) {
+			return cfg, fmt.Errorf("invalid API_LEGACY_SUNSET: %q", raw)
+		}
+		cfg.Sunset = value
+	}
+
+	return cfg, nil
+}
+
+// APIVersion tags the requests of a versioned route group
+func APIVersion(version int) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Set(apiVersionKey, version)
+		ctx.Next()
+	}
+}
+
+// GetAPIVersion returns the version the request was made against, unversioned aliases are v1
+func GetAPIVersion(ctx *gin.Context) int {
+	if version := ctx.GetInt(apiVersionKey); version != 0 {
+		return version
+	}
+	return APIVersion1
+}
+
+// Deprecated marks responses of the unversioned aliases with Deprecation (RFC 9745) and
+// Sunset (RFC 8594) headers, and links the /api/v1 route that replaces them
+func Deprecated(config DeprecationConfig, prefix, successor string) gin.HandlerFunc {
+	deprecation := fmt.Sprintf("@%d", config.DeprecatedAt.Unix())
+	sunset := config.Sunset.UTC().Format(http.TimeFormat)
+	return func(ctx *gin.Context) {
+		ctx.Header("Deprecation", deprecation)
+		ctx.Header("Sunset", sunset)
+		if rest, ok := strings.CutPrefix(ctx.Request.URL.Path, prefix); ok {
+			ctx.Header("Link", fmt.Sprintf(`<%s%s>; rel="successor-version"`, successor, rest))
+		}
+		ctx.Next()
+	}
+}
@@ -1,17 +1,58 @@
 package models
 
+import "time"
+
 // Area for get areas
 type Area struct {
 	AreaID            string         `json:"areaId"`
 	UrgencyLevel      int            `json:"urgencyLevel"`
 	RequiredResources map[string]int `json:"requiredResources"`
 	TimeConstraint    int            `json:"timeConstraint"`
+	EarliestArrival   *time.Time     `json:"earliestArrival,omitempty"`
+	LatestArrival     *time.Time     `json:"latestArrival,omitempty"`
+	Population        int            `json:"population"`
+	VulnerableGroups  map[string]int `json:"vulnerableGroups,omitempty"`
+	Latitude          *float64       `json:"latitude,omitempty"`
+	Longitude         *float64       `json:"longitude,omitempty"`
+	DisasterType      string         `json:"disasterType,omitempty"`
+	DurationDays      int            `json:"durationDays,omitempty"`
+	// EstimatedResources are the required resources derived from population and disaster type
+	EstimatedResources []string  `json:"estimatedResources,omitempty"`
+	CreatedAt          time.Time `json:"createdAt"`
+}
+
+// AreaFilter for list areas, zero values don't filter.
+// Sort is one of areaId, urgencyLevel, population, timeConstraint or createdAt, "-" sorts descending.
+type AreaFilter struct {
+	MinUrgency       int        `form:"minUrgency" binding:"omitempty,min=1,max=5"`
+	MaxUrgency       int        `form:"maxUrgency" binding:"omitempty,min=1,max=5,gtefield=MinUrgency"`
+	RequiresResource []string   `form:"requiresResource"`
+	HasRoute         *bool      `form:"hasRoute"`
+	CreatedSince     *time.Time `form:"createdSince" time_format:"2006-01-02T15:04:05Z07:00"`
+	Sort             string     `form:"sort"`
+	Cursor           string     `form:"cursor"`
+	Limit            int        `form:"limit" binding:"omitempty,min=1,max=1000"`
 }
 
 // CreateAreaRequest for create area
+// TimeConstraint is the legacy shorthand for "latestArrival = now + timeConstraint minutes"
+// and is only required when latestArrival is not given.
+// With a DisasterType the required resources are estimated from the population over DurationDays,
+// quantities given in RequiredResources override the estimate.
 type CreateAreaRequest struct {
 	AreaID            string         `json:"areaId" binding:"required"`
 	UrgencyLevel      int            `json:"urgencyLevel" binding:"required,min=1,max=5"`
-	RequiredResources map[string]int `json:"requiredResources" binding:"required,dive,min=0"`
-	TimeConstraint    int            `json:"timeConstraint" binding:"required,min=0"`
+	RequiredResources map[string]int `json:"requiredResources" binding:"required_without=DisasterType,omitempty,dive,keys,resource,endkeys,min=0"`
+	TimeConstraint    int            `json:"timeConstraint" binding:"required_without=LatestArrival,omitempty,min=0"`
+	EarliestArrival   *time.Time     `json:"earliestArrival"`
+	LatestArrival     *time.Time     `json:"latestArrival"`
+	Population        int            `json:"population" binding:"required_with=DisasterType,omitempty,min=0"`
+	VulnerableGroups  map[string]int `json:"vulnerableGroups" binding:"omitempty,dive,min=0"`
+	Latitude          *float64       `json:"latitude" binding:"required_with=Longitude,omitempty,min=-90,max=90"`
+	Longitude         *float64       `json:"longitude" binding:"required_with=Latitude,omitempty,min=-180,max=180"`
+	DisasterType      string         `json:"disasterType" binding:"omitempty,max=50"`
+	DurationDays      int            `json:"durationDays" binding:"omitempty,min=1,max=365"`
+
+	// EstimatedResources is set by the demand estimator, never by clients
+	EstimatedResources []string `json:"-"`
 }
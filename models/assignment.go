@@ -1,9 +1,89 @@
 package models
 
+import "time"
+
+// Assignment statuses, planned assignments may still change on the next replan
+const (
+	AssignmentStatusPlanned   = "planned"
+	AssignmentStatusConfirmed = "confirmed"
+	AssignmentStatusInTransit = "in_transit"
+	AssignmentStatusDelivered = "delivered"
+	AssignmentStatusCancelled = "cancelled"
+)
+
 // Assignment model
 type Assignment struct {
 	AreaID             string         `json:"area_id"`
 	TruckID            string         `json:"truck_id"`
 	ResourcesDelivered map[string]int `json:"resources_delivered"`
+	DepartureTime      *time.Time     `json:"departure_time,omitempty"`
+	EstimatedArrival   *time.Time     `json:"estimated_arrival,omitempty"`
+	Status             string         `json:"status,omitempty"`
+	Priority           *PriorityScore `json:"priority,omitempty"`
 	Message            string         `json:"message,omitempty"`
 }
+
+// PriorityScore is the breakdown of how an area was ranked, every component is already weighted
+// so they add up to Total
+type PriorityScore struct {
+	Total         float64 `json:"total"`
+	Urgency       float64 `json:"urgency"`
+	Population    float64 `json:"population"`
+	Vulnerable    float64 `json:"vulnerable"`
+	TimeRemaining float64 `json:"time_remaining"`
+	Waiting       float64 `json:"waiting"`
+}
+
+// UpdateAssignmentStatusRequest for move a locked assignment forward, cancelled releases the lock
+type UpdateAssignmentStatusRequest struct {
+	Status string `json:"status" binding:"required,oneof=in_transit delivered cancelled"`
+}
+
+// AssignmentV2 is Assignment as served by /api/v2, camelCase like every other payload
+type AssignmentV2 struct {
+	AreaID             string           `json:"areaId"`
+	TruckID            string           `json:"truckId"`
+	ResourcesDelivered map[string]int   `json:"resourcesDelivered"`
+	DepartureTime      *time.Time       `json:"departureTime,omitempty"`
+	EstimatedArrival   *time.Time       `json:"estimatedArrival,omitempty"`
+	Status             string           `json:"status,omitempty"`
+	Priority           *PriorityScoreV2 `json:"priority,omitempty"`
+	Message            string           `json:"message,omitempty"`
+}
+
+// PriorityScoreV2 is PriorityScore as served by /api/v2
+type PriorityScoreV2 struct {
+	Total         float64 `json:"total"`
+	Urgency       float64 `json:"urgency"`
+	Population    float64 `json:"population"`
+	Vulnerable    float64 `json:"vulnerable"`
+	TimeRemaining float64 `json:"timeRemaining"`
+	Waiting       float64 `json:"waiting"`
+}
+
+// V2 converts the assignment to its /api/v2 form
+func (a Assignment) V2() AssignmentV2 {
+	v2 := AssignmentV2{
+		AreaID:             a.AreaID,
+		TruckID:            a.TruckID,
+		ResourcesDelivered: a.ResourcesDelivered,
+		DepartureTime:      a.DepartureTime,
+		EstimatedArrival:   a.EstimatedArrival,
+		Status:             a.Status,
+		Message:            a.Message,
+	}
+	if a.Priority != nil {
+		priority := PriorityScoreV2(*a.Priority)
+		v2.Priority = &priority
+	}
+	return v2
+}
+
+// AssignmentsV2 converts assignments to their /api/v2 form
+func AssignmentsV2(assignments []Assignment) []AssignmentV2 {
+	v2 := make([]AssignmentV2, len(assignments))
+	for i, a := range assignments {
+		v2[i] = a.V2()
+	}
+	return v2
+}
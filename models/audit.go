@@ -0,0 +1,33 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEvent is one recorded mutation or plan decision
+type AuditEvent struct {
+	ID         int64           `json:"id"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Actor      string          `json:"actor"`
+	ActorRole  string          `json:"actorRole,omitempty"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entityType"`
+	EntityID   string          `json:"entityId,omitempty"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	RequestID  string          `json:"requestId,omitempty"`
+}
+
+// AuditFilter for get audit events, zero values don't filter
+type AuditFilter struct {
+	Actor      string     `form:"actor"`
+	Action     string     `form:"action"`
+	EntityType string     `form:"entityType"`
+	EntityID   string     `form:"entityId"`
+	RequestID  string     `form:"requestId"`
+	Since      *time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	Until      *time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+	BeforeID   int64      `form:"beforeId" binding:"omitempty,min=1"`
+	Limit      int        `form:"limit" binding:"omitempty,min=1,max=10000"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// Roles, each role can do everything the roles before it can
+const (
+	RoleViewer     = "viewer"
+	RoleDispatcher = "dispatcher"
+	RoleAdmin      = "admin"
+)
+
+// RoleRank orders roles for comparison, unknown roles rank 0
+func RoleRank(role string) int {
+	switch role {
+	case RoleViewer:
+		return 1
+	case RoleDispatcher:
+		return 2
+	case RoleAdmin:
+		return 3
+	}
+	return 0
+}
+
+// APIKey for get api keys, Key is only returned when the key is issued
+type APIKey struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Role       string     `json:"role"`
+	CreatedBy  string     `json:"createdBy,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	Key        string     `json:"key,omitempty"`
+}
+
+// CreateAPIKeyRequest for issue api key
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Role      string     `json:"role" binding:"required,oneof=viewer dispatcher admin"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
@@ -0,0 +1,78 @@
+package models
+
+import "time"
+
+// CoverageOptions for the coverage report
+type CoverageOptions struct {
+	// Top is how many shortfalls are listed
+	Top int `form:"top" binding:"omitempty,min=1,max=100"`
+	// RiskMinutes is the slack below which an assigned area counts as at risk, nil uses the default
+	// and 0 only flags arrivals after the deadline
+	RiskMinutes *int `form:"riskMinutes" binding:"omitempty,min=0,max=1440"`
+}
+
+// Reasons an area is at risk of missing its time constraint
+const (
+	RiskUnassigned = "unassigned"
+	RiskTight      = "tight"
+	RiskOverdue    = "overdue"
+)
+
+// UrgencyCoverage is how many areas of one urgency level the plan serves
+type UrgencyCoverage struct {
+	UrgencyLevel int     `json:"urgencyLevel"`
+	Areas        int     `json:"areas"`
+	Served       int     `json:"served"`
+	Percent      float64 `json:"percent"`
+}
+
+// ResourceCoverage compares what areas need of a resource with what the plan delivers
+type ResourceCoverage struct {
+	Resource  string  `json:"resource"`
+	Required  int     `json:"required"`
+	Delivered int     `json:"delivered"`
+	Unmet     int     `json:"unmet"`
+	Percent   float64 `json:"percent"`
+}
+
+// Shortfall is an area the plan leaves without resources
+type Shortfall struct {
+	AreaID       string         `json:"areaId"`
+	UrgencyLevel int            `json:"urgencyLevel"`
+	Missing      map[string]int `json:"missing"`
+	Units        int            `json:"units"`
+	Reason       string         `json:"reason,omitempty"`
+}
+
+// IdleSurplus is the stock on available trucks the plan doesn't use
+type IdleSurplus struct {
+	Trucks    []string       `json:"trucks"`
+	Resources map[string]int `json:"resources"`
+}
+
+// AtRiskArea is an area that may miss its latest arrival
+type AtRiskArea struct {
+	AreaID           string     `json:"areaId"`
+	UrgencyLevel     int        `json:"urgencyLevel"`
+	Reason           string     `json:"reason"`
+	TruckID          string     `json:"truckId,omitempty"`
+	EstimatedArrival *time.Time `json:"estimatedArrival,omitempty"`
+	LatestArrival    time.Time  `json:"latestArrival"`
+	// SlackMinutes is the time between the estimated arrival, or now when unassigned, and the latest arrival
+	SlackMinutes float64 `json:"slackMinutes"`
+}
+
+// CoverageReport summarises how well the latest plan meets demand
+type CoverageReport struct {
+	PlanID      int64              `json:"planId"`
+	PlanStart   time.Time          `json:"planStart"`
+	GeneratedAt time.Time          `json:"generatedAt"`
+	Areas       int                `json:"areas"`
+	Served      int                `json:"served"`
+	Percent     float64            `json:"percent"`
+	ByUrgency   []UrgencyCoverage  `json:"byUrgency"`
+	Resources   []ResourceCoverage `json:"resources"`
+	Shortfalls  []Shortfall        `json:"shortfalls"`
+	IdleSurplus IdleSurplus        `json:"idleSurplus"`
+	AtRisk      []AtRiskArea       `json:"atRisk"`
+}
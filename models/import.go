@@ -0,0 +1,48 @@
+package models
+
+import "workship-disaster-api/apperr"
+
+// Import file formats
+const (
+	ImportFormatCSV     = "csv"
+	ImportFormatJSON    = "json"
+	ImportFormatGeoJSON = "geojson"
+)
+
+// Import conflict modes, fail rejects rows whose ID already exists, upsert replaces them
+const (
+	ImportModeFail   = "fail"
+	ImportModeUpsert = "upsert"
+)
+
+// ImportOptions for bulk imports, the format is inferred from the upload when empty
+type ImportOptions struct {
+	Format string `form:"format" binding:"omitempty,oneof=csv json geojson"`
+	Mode   string `form:"mode" binding:"omitempty,oneof=fail upsert"`
+	DryRun bool   `form:"dryRun"`
+}
+
+// ImportRowError reports why a row was rejected.
+// Row is the spreadsheet row for CSV (the header is row 1) and the 1-based position for JSON and GeoJSON.
+type ImportRowError struct {
+	Row    int                 `json:"row"`
+	ID     string              `json:"id,omitempty"`
+	Code   apperr.Code         `json:"code"`
+	Error  string              `json:"error"`
+	Fields []apperr.FieldError `json:"fields,omitempty"`
+}
+
+// ImportResult summarises a bulk import, nothing is written unless Committed is true
+type ImportResult struct {
+	Entity    string           `json:"entity"`
+	Format    string           `json:"format"`
+	Mode      string           `json:"mode"`
+	DryRun    bool             `json:"dryRun"`
+	Committed bool             `json:"committed"`
+	Total     int              `json:"total"`
+	Created   []string         `json:"created"`
+	Updated   []string         `json:"updated"`
+	Errors    []ImportRowError `json:"errors"`
+	// Warnings are accepted rows with problems worth a look, e.g. travel times to unknown areas
+	Warnings []ImportRowError `json:"warnings"`
+}
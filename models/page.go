@@ -0,0 +1,9 @@
+package models
+
+// Page is one page of a list endpoint. Total counts every match of the filters,
+// NextCursor fetches the following page and is empty on the last one.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// Kinds of assignment change between two plans
+const (
+	ChangeAssigned   = "assigned"
+	ChangeUnassigned = "unassigned"
+	ChangeReassigned = "reassigned"
+	ChangeRemoved    = "removed"
+)
+
+// Plan is one planner run
+type Plan struct {
+	ID          int64              `json:"id"`
+	Trigger     string             `json:"trigger"`
+	PlanStart   time.Time          `json:"plan_start"`
+	CreatedAt   time.Time          `json:"created_at"`
+	Assignments []Assignment       `json:"assignments"`
+	Diff        []AssignmentChange `json:"diff"`
+}
+
+// AssignmentChange describes how an area's assignment differs from the previous plan
+type AssignmentChange struct {
+	AreaID          string `json:"area_id"`
+	Change          string `json:"change"`
+	PreviousTruckID string `json:"previous_truck_id,omitempty"`
+	TruckID         string `json:"truck_id,omitempty"`
+	Description     string `json:"description"`
+}
+
+// PlanV2 is Plan as served by /api/v2
+type PlanV2 struct {
+	ID          int64                `json:"id"`
+	Trigger     string               `json:"trigger"`
+	PlanStart   time.Time            `json:"planStart"`
+	CreatedAt   time.Time            `json:"createdAt"`
+	Assignments []AssignmentV2       `json:"assignments"`
+	Diff        []AssignmentChangeV2 `json:"diff"`
+}
+
+// AssignmentChangeV2 is AssignmentChange as served by /api/v2
+type AssignmentChangeV2 struct {
+	AreaID          string `json:"areaId"`
+	Change          string `json:"change"`
+	PreviousTruckID string `json:"previousTruckId,omitempty"`
+	TruckID         string `json:"truckId,omitempty"`
+	Description     string `json:"description"`
+}
+
+// V2 converts the plan to its /api/v2 form
+func (p Plan) V2() PlanV2 {
+	v2 := PlanV2{
+		ID:          p.ID,
+		Trigger:     p.Trigger,
+		PlanStart:   p.PlanStart,
+		CreatedAt:   p.CreatedAt,
+		Assignments: AssignmentsV2(p.Assignments),
+		Diff:        make([]AssignmentChangeV2, len(p.Diff)),
+	}
+	for i, change := range p.Diff {
+		v2.Diff[i] = AssignmentChangeV2(change)
+	}
+	return v2
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// OrphanTravel is a travel time to an area that isn't registered
+type OrphanTravel struct {
+	TruckID string `json:"truckId"`
+	AreaID  string `json:"areaId"`
+	Minutes int    `json:"minutes"`
+}
+
+// UnreachableArea is an area no truck has a travel time to, the planner can never serve it
+type UnreachableArea struct {
+	AreaID       string `json:"areaId"`
+	UrgencyLevel int    `json:"urgencyLevel"`
+}
+
+// UncarriedResource is a resource areas need that no truck carries
+type UncarriedResource struct {
+	Resource string   `json:"resource"`
+	Required int      `json:"required"`
+	AreaIDs  []string `json:"areaIds"`
+}
+
+// DataQualityReport lists reference problems that keep the planner from matching trucks to areas
+type DataQualityReport struct {
+	CheckedAt          time.Time           `json:"checkedAt"`
+	OrphanTravel       []OrphanTravel      `json:"orphanTravel"`
+	UnreachableAreas   []UnreachableArea   `json:"unreachableAreas"`
+	UncarriedResources []UncarriedResource `json:"uncarriedResources"`
+}
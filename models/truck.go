@@ -1,15 +1,63 @@
 package models
 
+import "time"
+
+// Truck statuses
+const (
+	TruckStatusAvailable   = "available"
+	TruckStatusBusy        = "busy"
+	TruckStatusMaintenance = "maintenance"
+	TruckStatusOffline     = "offline"
+)
+
 // Truck rfor get trucks
 type Truck struct {
 	TruckID            string         `json:"truckId"`
 	AvailableResources map[string]int `json:"availableResources"`
 	TravelTimeToArea   map[string]int `json:"travelTimeToArea"`
+	Status             string         `json:"status"`
+	ShiftStart         string         `json:"shiftStart,omitempty"`
+	ShiftEnd           string         `json:"shiftEnd,omitempty"`
+	AvailableFrom      *time.Time     `json:"availableFrom,omitempty"`
+	Latitude           *float64       `json:"latitude,omitempty"`
+	Longitude          *float64       `json:"longitude,omitempty"`
+	CreatedAt          time.Time      `json:"createdAt"`
+}
+
+// TruckFilter for list trucks, zero values don't filter.
+// Sort is one of truckId, status or createdAt, "-" sorts descending.
+type TruckFilter struct {
+	Status       string     `form:"status" binding:"omitempty,oneof=available busy maintenance offline"`
+	HasResource  []string   `form:"hasResource"`
+	RouteToArea  string     `form:"routeToArea"`
+	CreatedSince *time.Time `form:"createdSince" time_format:"2006-01-02T15:04:05Z07:00"`
+	Sort         string     `form:"sort"`
+	Cursor       string     `form:"cursor"`
+	Limit        int        `form:"limit" binding:"omitempty,min=1,max=1000"`
 }
 
 // CreateTruckRequest for create truck
+// ShiftStart and ShiftEnd are daily "HH:MM" times, a shift may cross midnight.
 type CreateTruckRequest struct {
 	TruckID            string         `json:"truckId" binding:"required"`
-	AvailableResources map[string]int `json:"availableResources" binding:"required,dive,min=0"`
+	AvailableResources map[string]int `json:"availableResources" binding:"required,dive,keys,resource,endkeys,min=0"`
 	TravelTimeToArea   map[string]int `json:"travelTimeToArea" binding:"required,dive,min=0"`
+	Status             string         `json:"status" binding:"omitempty,oneof=available busy maintenance offline"`
+	ShiftStart         string         `json:"shiftStart" binding:"required_with=ShiftEnd,omitempty,datetime=15:04"`
+	ShiftEnd           string         `json:"shiftEnd" binding:"required_with=ShiftStart,omitempty,datetime=15:04"`
+	AvailableFrom      *time.Time     `json:"availableFrom"`
+	Latitude           *float64       `json:"latitude" binding:"required_with=Longitude,omitempty,min=-90,max=90"`
+	Longitude          *float64       `json:"longitude" binding:"required_with=Latitude,omitempty,min=-180,max=180"`
+}
+
+// UpdateTruckStatusRequest for change truck status
+type UpdateTruckStatusRequest struct {
+	Status        string     `json:"status" binding:"required,oneof=available busy maintenance offline"`
+	AvailableFrom *time.Time `json:"availableFrom"`
+}
+
+// UpdateTruckShiftRequest for change driver shift, empty values clear the shift
+type UpdateTruckShiftRequest struct {
+	ShiftStart string `json:"shiftStart" binding:"required_with=ShiftEnd,omitempty,datetime=15:04"`
+	ShiftEnd   string `json:"shiftEnd" binding:"required_with=ShiftStart,omitempty,datetime=15:04"`
 }
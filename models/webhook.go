@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// Webhook delivery statuses
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusDelivered = "delivered"
+	DeliveryStatusDead      = "dead"
+)
+
+// WebhookSubscription for get webhooks, Secret is only returned when the subscription is created
+type WebhookSubscription struct {
+	ID          int64     `json:"id"`
+	URL         string    `json:"url"`
+	EventTypes  []string  `json:"eventTypes"`
+	Description string    `json:"description,omitempty"`
+	Active      bool      `json:"active"`
+	Secret      string    `json:"secret,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// CreateWebhookRequest for create webhook, a random secret is generated when none is given
+type CreateWebhookRequest struct {
+	URL         string   `json:"url" binding:"required,url"`
+	EventTypes  []string `json:"eventTypes" binding:"required,min=1"`
+	Description string   `json:"description"`
+	Secret      string   `json:"secret" binding:"omitempty,min=16"`
+}
+
+// UpdateWebhookRequest for update webhook
+type UpdateWebhookRequest struct {
+	URL         string   `json:"url" binding:"required,url"`
+	EventTypes  []string `json:"eventTypes" binding:"required,min=1"`
+	Description string   `json:"description"`
+	Active      *bool    `json:"active" binding:"required"`
+}
+
+// WebhookDelivery is one event queued for one subscription
+type WebhookDelivery struct {
+	ID             int64      `json:"id"`
+	SubscriptionID int64      `json:"subscriptionId"`
+	EventID        string     `json:"eventId"`
+	EventType      string     `json:"eventType"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"nextAttemptAt"`
+	LastStatusCode *int       `json:"lastStatusCode,omitempty"`
+	LastError      *string    `json:"lastError,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	DeliveredAt    *time.Time `json:"deliveredAt,omitempty"`
+}
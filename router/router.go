@@ -1,62 +1,206 @@
 package router
 
 import (
-	"context"
 	"database/sql"
+	"log/slog"
 
 	"workship-disaster-api/controllers"
+	"workship-disaster-api/docs"
+	"workship-disaster-api/events"
+	"workship-disaster-api/metrics"
+	"workship-disaster-api/middleware"
+	"workship-disaster-api/models"
+	"workship-disaster-api/service"
+	"workship-disaster-api/tracing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
-var ctx = context.Background()
+// Dependencies are the shared connections and services the routes are built from
+type Dependencies struct {
+	DB          *sql.DB
+	Redis       *redis.Client
+	Areas       *service.AreaService
+	Trucks      *service.TruckService
+	Planner     *service.PlannerService
+	Publisher   *events.Publisher
+	Hub         *events.Hub
+	Webhooks    *service.WebhookService
+	APIKeys     *service.APIKeyService
+	Audit       *service.AuditService
+	Imports     *service.ImportService
+	Exports     *service.ExportService
+	DataQuality *service.DataQualityService
+	Coverage    *service.CoverageService
+	Auth        *middleware.Authenticator
+	Idempotency *middleware.Idempotency
+	RateLimiter *middleware.RateLimiter
+	Deprecation middleware.DeprecationConfig
+	Health      *service.HealthService
+	Logger      *slog.Logger
+}
 
 // SetupRouter all the routes
-func SetupRouter(db *sql.DB, rdb *redis.Client) *gin.Engine {
-	r := gin.Default()
+func SetupRouter(deps Dependencies) *gin.Engine {
+	db, rdb := deps.DB, deps.Redis
+	r := gin.New()
 
-	// Health check
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"message": "Server is running!"})
-	})
+	// Handlers pass the gin context on to services, falling back to the request context
+	// lets the request's span and cancellation reach SQL and Redis calls
+	r.ContextWithFallback = true
 
-	// Redis test
-	r.GET("/redis-test", func(c *gin.Context) {
-		rdb.Set(ctx, "status", "Redis is working!", 0)
-		status, _ := rdb.Get(ctx, "status").Result()
-		c.JSON(200, gin.H{"redis": status})
-	})
+	// Continue traces from incoming traceparent headers, spans are named after the route
+	r.Use(otelgin.Middleware(tracing.ServiceName))
+	// Every log line and error response of a request carries its X-Request-ID
+	r.Use(middleware.RequestID(), middleware.Logger(deps.Logger), middleware.Metrics(), middleware.Recovery())
 
-	// PostgreSQL test
-	r.GET("/postgres-test", func(c *gin.Context) {
-		var dbVersion string
-		db.QueryRow("SELECT version();").Scan(&dbVersion)
-		c.JSON(200, gin.H{"postgres": dbVersion})
+	// Liveness and readiness probes, /health is kept for existing checks
+	health := controllers.NewHealthController(deps.Health)
+	r.GET("/livez", health.Livez)
+	r.GET("/readyz", health.Readyz)
+	r.GET("/health", health.Livez)
+
+	// API documentation, docs/openapi.json must list every route registered here
+	r.GET("/openapi.json", func(c *gin.Context) {
+		c.Data(200, "application/json; charset=utf-8", docs.OpenAPI)
+	})
+	r.GET("/docs", func(c *gin.Context) {
+		c.Data(200, "text/html; charset=utf-8", docs.SwaggerUI)
 	})
 
-	// Initialize controllers
-	areaController := controllers.NewAreaController(db)
-	truckController := controllers.NewTruckController(db)
-	assignmentController := controllers.NewAssignmentController(db, rdb)
+	// Prometheus scrapes with a viewer API key or token
+	r.GET("/metrics", deps.Auth.Require(models.RoleViewer), gin.WrapH(metrics.Handler()))
+
+	// Initialize controllers, every API version shares them
+	handlers := apiControllers{
+		area:       controllers.NewAreaController(db, deps.Areas, deps.Planner),
+		truck:      controllers.NewTruckController(db, rdb, deps.Trucks, deps.Planner, deps.Publisher),
+		assignment: controllers.NewAssignmentController(db, rdb, deps.Planner),
+		stream:     controllers.NewStreamController(deps.Hub, deps.Publisher),
+		webhook:    controllers.NewWebhookController(deps.Webhooks),
+		apiKey:     controllers.NewAPIKeyController(deps.APIKeys),
+		audit:      controllers.NewAuditController(deps.Audit),
+		imports:    controllers.NewImportController(deps.Imports, deps.Planner),
+		export:     controllers.NewExportController(deps.Planner, deps.Exports),
+		quality:    controllers.NewDataQualityController(deps.DataQuality),
+		report:     controllers.NewReportController(deps.Coverage),
+		idempotent: deps.Idempotency.Handler(),
+		throttle:   deps.RateLimiter.ByMethod(),
+		write:      deps.RateLimiter.Limit(middleware.RateClassWrite),
+		compute:    deps.RateLimiter.Limit(middleware.RateClassCompute),
+		bodyLimit:  deps.RateLimiter.BodyLimit(),
+	}
+
+	// v1 keeps the original payloads, v2 renders assignments and plans in camelCase
+	registerAPI(r.Group("/api/v1", middleware.APIVersion(middleware.APIVersion1)), deps, handlers)
+	registerAPI(r.Group("/api/v2", middleware.APIVersion(middleware.APIVersion2)), deps, handlers)
+
+	// The unversioned paths are deprecated aliases of v1
+	registerAPI(r.Group("/api", middleware.Deprecated(deps.Deprecation, "/api", "/api/v1")), deps, handlers)
+
+	return r
+}
+
+// apiControllers are the handlers behind every API version
+type apiControllers struct {
+	area       *controllers.AreaController
+	truck      *controllers.TruckController
+	assignment *controllers.AssignmentController
+	stream     *controllers.StreamController
+	webhook    *controllers.WebhookController
+	apiKey     *controllers.APIKeyController
+	audit      *controllers.AuditController
+	imports    *controllers.ImportController
+	export     *controllers.ExportController
+	quality    *controllers.DataQualityController
+	report     *controllers.ReportController
+	idempotent gin.HandlerFunc
+
+	// Rate limits, throttle picks the read or write budget by method
+	throttle  gin.HandlerFunc
+	write     gin.HandlerFunc
+	compute   gin.HandlerFunc
+	bodyLimit gin.HandlerFunc
+}
+
+// registerAPI mounts the API routes on api, grouped by the minimum role they need
+func registerAPI(api *gin.RouterGroup, deps Dependencies, h apiControllers) {
+	// Viewer: read-only access to plans
+	viewer := api.Group("", deps.Auth.Require(models.RoleViewer), h.throttle)
+	{
+		viewer.GET("/areas", h.area.ListAreas)
+		viewer.GET("/trucks", h.truck.ListTrucks)
+		viewer.GET("/assignments", h.assignment.GetAssignments)
+		viewer.GET("/assignments/plans/latest", h.assignment.GetLatestPlan)
+		viewer.GET("/assignments/plans/:planId", h.assignment.GetPlan)
+		viewer.GET("/assignments/plans/:planId/export", h.export.ExportPlan)
+		viewer.GET("/data-quality", h.quality.GetDataQuality)
+		viewer.GET("/reports/coverage", h.report.GetCoverage)
+	}
+
+	// Streams accept ?access_token= because EventSource and WebSocket can't send headers
+	streams := api.Group("", deps.Auth.RequireStream(models.RoleViewer), h.throttle)
+	{
+		streams.GET("/assignments/stream", h.stream.StreamSSE)
+		streams.GET("/assignments/ws", h.stream.StreamWebSocket)
+	}
 
-	// API routes
-	api := r.Group("/api")
+	// Dispatcher: register areas and trucks, compute and confirm plans
+	// Retried creates and confirmations with the same Idempotency-Key replay the first response
+	dispatcher := api.Group("", deps.Auth.Require(models.RoleDispatcher))
 	{
-		// Areas
-		api.POST("/areas", areaController.CreateArea)
+		writes := dispatcher.Group("", h.write, h.bodyLimit)
+		{
+			// Areas
+			writes.POST("/areas", h.idempotent, h.area.CreateArea)
 
-		// Trucks
-		api.POST("/trucks", truckController.CreateTruck)
+			// Trucks
+			writes.POST("/trucks", h.idempotent, h.truck.CreateTruck)
+			writes.PATCH("/trucks/:truckId/status", h.truck.UpdateTruckStatus)
+			writes.PUT("/trucks/:truckId/shift", h.truck.UpdateTruckShift)
 
-		// Assignments
-		assignments := api.Group("/assignments")
+			// Assignments
+			writes.POST("/assignments/:areaId/confirm", h.idempotent, h.assignment.ConfirmAssignment)
+			writes.PATCH("/assignments/:areaId/status", h.assignment.UpdateAssignmentStatus)
+		}
+
+		// Import files are capped by the import controller instead of the JSON body limit
+		imports := dispatcher.Group("", h.write)
 		{
-			assignments.POST("", assignmentController.CreateAssignment)
-			assignments.GET("", assignmentController.GetAssignments)
-			assignments.DELETE("", assignmentController.DeleteAssignments)
+			imports.POST("/areas/import", h.imports.ImportAreas)
+			imports.POST("/trucks/import", h.imports.ImportTrucks)
 		}
+
+		// Computing a plan scans every area and truck, so it has the smallest budget
+		dispatcher.POST("/assignments", h.compute, h.bodyLimit, h.assignment.CreateAssignment)
 	}
 
-	return r
+	// Admin: cache control, audit trail, partner webhooks and API keys
+	admin := api.Group("", deps.Auth.Require(models.RoleAdmin), h.throttle, h.bodyLimit)
+	{
+		admin.DELETE("/assignments", h.assignment.DeleteAssignments)
+		admin.GET("/audit", h.audit.ListAuditEvents)
+
+		// Webhooks
+		webhooks := admin.Group("/webhooks")
+		{
+			webhooks.POST("", h.webhook.CreateWebhook)
+			webhooks.GET("", h.webhook.ListWebhooks)
+			webhooks.GET("/deliveries", h.webhook.ListDeliveries)
+			webhooks.POST("/deliveries/:deliveryId/retry", h.webhook.RetryDelivery)
+			webhooks.GET("/:webhookId", h.webhook.GetWebhook)
+			webhooks.PUT("/:webhookId", h.webhook.UpdateWebhook)
+			webhooks.DELETE("/:webhookId", h.webhook.DeleteWebhook)
+		}
+
+		// API keys
+		apiKeys := admin.Group("/admin/api-keys")
+		{
+			apiKeys.POST("", h.apiKey.IssueAPIKey)
+			apiKeys.GET("", h.apiKey.ListAPIKeys)
+			apiKeys.DELETE("/:keyId", h.apiKey.RevokeAPIKey)
+		}
+	}
 }
@@ -0,0 +1,82 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"workship-disaster-api/docs"
+	"workship-disaster-api/middleware"
+	"workship-disaster-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ginParam matches gin path parameters such as :planId
+var ginParam = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+
+// TestOpenAPICoversRoutes fails when a route registered in SetupRouter is missing from
+// docs/openapi.json, or when the document describes a route that doesn't exist
+func TestOpenAPICoversRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter(testDependencies())
+
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(docs.OpenAPI, &spec); err != nil {
+		t.Fatalf("docs/openapi.json is not valid JSON: %v", err)
+	}
+
+	documented := map[string]bool{}
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	registered := map[string]bool{}
+	for _, route := range r.Routes() {
+		operation := route.Method + " " + ginParam.ReplaceAllString(route.Path, "{$1}")
+		registered[operation] = true
+		if !documented[operation] {
+			t.Errorf("route %s is not documented in docs/openapi.json", operation)
+		}
+	}
+
+	var stale []string
+	for operation := range documented {
+		if !registered[operation] {
+			stale = append(stale, operation)
+		}
+	}
+	sort.Strings(stale)
+	for _, operation := range stale {
+		t.Errorf("docs/openapi.json documents %s, which SetupRouter doesn't register", operation)
+	}
+}
+
+// TestOpenAPIServed checks the document and the Swagger UI page are reachable without credentials
+func TestOpenAPIServed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter(testDependencies())
+
+	for _, path := range []string{"/openapi.json", "/docs"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("GET %s returned %d, want 200", path, w.Code)
+		}
+	}
+}
+
+// testDependencies is enough to register every route, no handler is run against it
+func testDependencies() Dependencies {
+	return Dependencies{
+		Planner:     service.NewPlannerService(nil, nil, nil, nil, service.PlannerConfig{}, nil),
+		RateLimiter: middleware.NewRateLimiter(nil, middleware.RateLimitConfig{}),
+	}
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"workship-disaster-api/models"
+)
+
+// apiKeyPrefix marks API keys so they are easy to spot in logs and secret scanners
+const apiKeyPrefix = "dk_"
+
+var (
+	// ErrAPIKeyNotFound is returned when a key doesn't exist or was already revoked
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	// ErrAPIKeyInvalid is returned when a presented key is unknown, revoked or expired
+	ErrAPIKeyInvalid = errors.New("invalid api key")
+)
+
+// APIKeyService issues and checks API keys, only SHA-256 hashes are stored
+type APIKeyService struct {
+	db *sql.DB
+}
+
+func NewAPIKeyService(db *sql.DB) *APIKeyService {
+	return &APIKeyService{db: db}
+}
+
+// HashAPIKey returns the stored form of a key
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// displayPrefix is the part of the key shown in listings
+func displayPrefix(key string) string {
+	if len(key) < len(apiKeyPrefix)+8 {
+		return key
+	}
+	return key[:len(apiKeyPrefix)+8]
+}
+
+// CreateAPIKey issues a new key with its audit entry, the plaintext is only returned here
+func (s *APIKeyService) CreateAPIKey(ctx context.Context, req models.CreateAPIKeyRequest, createdBy string) (*models.APIKey, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("failed to generate api key: %w", err)
+	}
+	key := apiKeyPrefix + hex.EncodeToString(buf)
+
+	apiKey := models.APIKey{
+		Name:      req.Name,
+		Prefix:    displayPrefix(key),
+		Role:      req.Role,
+		CreatedBy: createdBy,
+		ExpiresAt: req.ExpiresAt,
+		Key:       key,
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create api key: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO api_keys (name, prefix, key_hash, role, created_by, expires_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at",
+		apiKey.Name, apiKey.Prefix, HashAPIKey(key), apiKey.Role, createdBy, req.ExpiresAt,
+	).Scan(&apiKey.ID, &apiKey.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create api key: %w", err)
+	}
+
+	// Never write the plaintext key to the audit trail
+	audited := apiKey
+	audited.Key = ""
+	if err := RecordAudit(ctx, tx, AuditCreate, "api_key", strconv.FormatInt(apiKey.ID, 10), nil, audited); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to create api key: %w", err)
+	}
+
+	return &apiKey, nil
+}
+
+// EnsureAPIKey stores a key supplied from configuration, e.g. the bootstrap admin key, if it isn't known yet.
+// Keys configured earlier under the same name are revoked, so rotating the setting retires the old key.
+// A configured key that was revoked stays revoked, configure a new one instead.
+func (s *APIKeyService) EnsureAPIKey(name, key, role string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to store %s api key: %w", name, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		"INSERT INTO api_keys (name, prefix, key_hash, role, created_by) VALUES ($1, $2, $3, $4, 'config') ON CONFLICT (key_hash) DO NOTHING",
+		name, displayPrefix(key), HashAPIKey(key), role,
+	); err != nil {
+		return fmt.Errorf("failed to store %s api key: %w", name, err)
+	}
+	if _, err := tx.Exec(
+		"UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE name = $1 AND created_by = 'config' AND key_hash <> $2 AND revoked_at IS NULL",
+		name, HashAPIKey(key),
+	); err != nil {
+		return fmt.Errorf("failed to revoke previous %s api key: %w", name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to store %s api key: %w", name, err)
+	}
+	return nil
+}
+
+// RevokeConfiguredAPIKey revokes the keys stored by EnsureAPIKey under name once the setting is removed,
+// it returns how many were still active
+func (s *APIKeyService) RevokeConfiguredAPIKey(name string) (int64, error) {
+	result, err := s.db.Exec("UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE name = $1 AND created_by = 'config' AND revoked_at IS NULL", name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke %s api key: %w", name, err)
+	}
+	return result.RowsAffected()
+}
+
+// ListAPIKeys fetches every key without hashes
+func (s *APIKeyService) ListAPIKeys() ([]models.APIKey, error) {
+	rows, err := s.db.Query("SELECT id, name, prefix, role, created_by, created_at, expires_at, revoked_at, last_used_at FROM api_keys ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch api keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		var key models.APIKey
+		if err := rows.Scan(&key.ID, &key.Name, &key.Prefix, &key.Role, &key.CreatedBy, &key.CreatedAt, &key.ExpiresAt, &key.RevokedAt, &key.LastUsedAt); err != nil {
+			return nil, fmt.Errorf("failed to parse api key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey stops a key from authenticating and audits the revocation
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, id int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND revoked_at IS NULL", id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrAPIKeyNotFound
+	}
+	if err := RecordAudit(ctx, tx, AuditUpdate, "api_key", strconv.FormatInt(id, 10), nil, map[string]bool{"revoked": true}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	return nil
+}
+
+// Authenticate looks up an active key and records its use. last_used_at is only written when it is
+// more than a minute old, so a busy client doesn't turn every request into a row update.
+func (s *APIKeyService) Authenticate(key string) (*models.APIKey, error) {
+	var apiKey models.APIKey
+	err := s.db.QueryRow(`
+		WITH active AS (
+			SELECT id, name, prefix, role, last_used_at FROM api_keys
+			WHERE key_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
+		), touched AS (
+			UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP FROM active
+			WHERE api_keys.id = active.id AND (active.last_used_at IS NULL OR active.last_used_at < CURRENT_TIMESTAMP - interval '1 minute')
+		)
+		SELECT id, name, prefix, role FROM active`,
+		HashAPIKey(key),
+	).Scan(&apiKey.ID, &apiKey.Name, &apiKey.Prefix, &apiKey.Role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAPIKeyInvalid
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check api key: %w", err)
+	}
+
+	return &apiKey, nil
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// TestAuthenticateThrottlesLastUsed checks the key is checked and last_used_at touched in one statement,
+// and only when the stored value is older than a minute
+func TestAuthenticateThrottlesLastUsed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	service := NewAPIKeyService(db)
+
+	mock.ExpectQuery(`UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP FROM active\s+WHERE api_keys.id = active.id AND \(active.last_used_at IS NULL OR active.last_used_at < CURRENT_TIMESTAMP - interval '1 minute'\)`).
+		WithArgs(HashAPIKey("dk_test")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "prefix", "role"}).AddRow(1, "ci", "dk_test", "viewer"))
+	mock.ExpectQuery("WITH active AS").WithArgs(HashAPIKey("dk_gone")).WillReturnRows(sqlmock.NewRows([]string{"id", "name", "prefix", "role"}))
+
+	key, err := service.Authenticate("dk_test")
+	if err != nil || key.Name != "ci" || key.Role != "viewer" {
+		t.Fatalf("Authenticate = %+v, %v", key, err)
+	}
+	if _, err := service.Authenticate("dk_gone"); !errors.Is(err, ErrAPIKeyInvalid) {
+		t.Errorf("Authenticate(unknown) = %v, want ErrAPIKeyInvalid", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEnsureAPIKeyRevokesPreviousKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	service := NewAPIKeyService(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO api_keys .* ON CONFLICT \\(key_hash\\) DO NOTHING").
+		WithArgs("bootstrap-admin", "dk_rotated0", HashAPIKey("dk_rotated0000"), "admin").WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE name = \\$1 AND created_by = 'config' AND key_hash <> \\$2").
+		WithArgs("bootstrap-admin", HashAPIKey("dk_rotated0000")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := service.EnsureAPIKey("bootstrap-admin", "dk_rotated0000", "admin"); err != nil {
+		t.Fatalf("EnsureAPIKey: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRevokeConfiguredAPIKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	service := NewAPIKeyService(db)
+
+	mock.ExpectExec("UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE name = \\$1 AND created_by = 'config' AND revoked_at IS NULL").
+		WithArgs("bootstrap-admin").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	revoked, err := service.RevokeConfiguredAPIKey("bootstrap-admin")
+	if err != nil || revoked != 1 {
+		t.Fatalf("RevokeConfiguredAPIKey = %d, %v, want the bootstrap key revoked", revoked, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
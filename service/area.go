@@ -1,9 +1,19 @@
 package service
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"math"
+	"net/http"
+	"time"
+	"workship-disaster-api/apperr"
+	"workship-disaster-api/events"
+	"workship-disaster-api/models"
+	"workship-disaster-api/tracing"
+
+	"github.com/lib/pq"
 )
 
 type AreaData struct {
@@ -11,38 +21,238 @@ type AreaData struct {
 	RequiredResource map[string]int
 	Urgency          int
 	TimeConstraint   int
+	EarliestArrival  *time.Time
+	LatestArrival    *time.Time
+	Population       int
+	VulnerableGroups map[string]int
+	Latitude         *float64
+	Longitude        *float64
+	CreatedAt        time.Time
+}
+
+// ArrivalWindow returns the absolute window in which deliveries to the area are accepted.
+// Areas without an explicit latestArrival fall back to TimeConstraint minutes after the plan start.
+func (a AreaData) ArrivalWindow(planStart time.Time) (time.Time, time.Time) {
+	earliest := planStart
+	if a.EarliestArrival != nil {
+		earliest = *a.EarliestArrival
+	}
+
+	latest := planStart.Add(time.Duration(a.TimeConstraint) * time.Minute)
+	if a.LatestArrival != nil {
+		latest = *a.LatestArrival
+	}
+
+	return earliest, latest
+}
+
+// ResolveArrivalWindow fills in whichever of timeConstraint and latestArrival is missing,
+// timeConstraint is shorthand for a deadline relative to now
+func ResolveArrivalWindow(req *models.CreateAreaRequest, now time.Time) error {
+	if req.LatestArrival == nil {
+		deadline := now.Add(time.Duration(req.TimeConstraint) * time.Minute)
+		req.LatestArrival = &deadline
+	} else if req.TimeConstraint == 0 {
+		req.TimeConstraint = int(math.Max(0, math.Ceil(req.LatestArrival.Sub(now).Minutes())))
+	}
+	if req.EarliestArrival != nil && !req.LatestArrival.After(*req.EarliestArrival) {
+		return &apperr.Error{
+			Status: http.StatusBadRequest,
+			Code:   apperr.CodeValidationFailed,
+			Detail: "The request has invalid fields",
+			Fields: []apperr.FieldError{{Field: "latestArrival", Rule: "gtfield", Param: "earliestArrival", Message: "must be after earliestArrival"}},
+		}
+	}
+	return nil
 }
 
 type AreaService struct {
-	db *sql.DB
+	db        *sql.DB
+	demand    DemandConfig
+	publisher *events.Publisher
+}
+
+func NewAreaService(db *sql.DB, demand DemandConfig, publisher *events.Publisher) *AreaService {
+	return &AreaService{db: db, demand: demand, publisher: publisher}
 }
 
-func NewAreaService(db *sql.DB) *AreaService {
-	return &AreaService{db: db}
+// EstimateDemand derives the required resources of an area from its disaster type and population
+func (s *AreaService) EstimateDemand(req *models.CreateAreaRequest) error {
+	return s.demand.Apply(req)
 }
 
 // GetAllAreas fetches all areas from the database
-func (s *AreaService) GetAllAreas() ([]AreaData, error) {
-	areaRows, err := s.db.Query("SELECT area_id, required_resources, urgency_level, time_constraint FROM areas ORDER BY urgency_level DESC")
+func (s *AreaService) GetAllAreas(ctx context.Context) (areas []AreaData, err error) {
+	ctx, span := tracing.Start(ctx, "AreaService.GetAllAreas")
+	defer func() { tracing.End(span, err) }()
+
+	areaRows, err := s.db.QueryContext(ctx, "SELECT area_id, area_requirements_json(area_id), urgency_level, time_constraint, earliest_arrival, latest_arrival, population, vulnerable_groups, latitude, longitude, created_at FROM areas ORDER BY urgency_level DESC, created_at, area_id")
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch areas: %w", err)
 	}
 	defer areaRows.Close()
 
-	var areas []AreaData
+	var resourcesJSON, vulnerableJSON [][]byte
 	for areaRows.Next() {
 		var area AreaData
-		var resourcesJSON []byte
-		if err := areaRows.Scan(&area.ID, &resourcesJSON, &area.Urgency, &area.TimeConstraint); err != nil {
+		var resources, vulnerable []byte
+		if err := areaRows.Scan(&area.ID, &resources, &area.Urgency, &area.TimeConstraint, &area.EarliestArrival, &area.LatestArrival, &area.Population, &vulnerable, &area.Latitude, &area.Longitude, &area.CreatedAt); err != nil {
 			return nil, fmt.Errorf("failed to parse area data: %w", err)
 		}
+		areas = append(areas, area)
+		resourcesJSON = append(resourcesJSON, resources)
+		vulnerableJSON = append(vulnerableJSON, vulnerable)
+	}
+	if err := areaRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to fetch areas: %w", err)
+	}
 
-		if err := json.Unmarshal(resourcesJSON, &area.RequiredResource); err != nil {
+	// Decoding gets its own span so slow JSON shows apart from the query
+	_, decode := tracing.Start(ctx, "areas.decode")
+	defer decode.End()
+	for i := range areas {
+		if err := json.Unmarshal(resourcesJSON[i], &areas[i].RequiredResource); err != nil {
 			return nil, fmt.Errorf("failed to parse area resources: %w", err)
 		}
 
-		areas = append(areas, area)
+		if err := json.Unmarshal(vulnerableJSON[i], &areas[i].VulnerableGroups); err != nil {
+			return nil, fmt.Errorf("failed to parse area vulnerable groups: %w", err)
+		}
 	}
 
 	return areas, nil
 }
+
+// CreateArea stores a new area with its requirements, audit entry and area.created event in one transaction.
+// An existing area ID fails with a unique violation.
+func (s *AreaService) CreateArea(ctx context.Context, req models.CreateAreaRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "AreaService.CreateArea")
+	defer func() { tracing.End(span, err) }()
+
+	vulnerableJSON, err := json.Marshal(req.VulnerableGroups)
+	if err != nil {
+		return fmt.Errorf("failed to process vulnerable groups: %w", err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create area: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO areas (area_id, urgency_level, time_constraint, earliest_arrival, latest_arrival, population, vulnerable_groups, latitude, longitude, disaster_type, duration_days) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)",
+		req.AreaID, req.UrgencyLevel, req.TimeConstraint, req.EarliestArrival, req.LatestArrival, req.Population, vulnerableJSON, req.Latitude, req.Longitude, NullIfEmpty(req.DisasterType), nullIfZero(req.DurationDays),
+	); err != nil {
+		return fmt.Errorf("failed to create area: %w", err)
+	}
+	if err := saveAreaRequirements(ctx, tx, req.AreaID, req.RequiredResources, req.EstimatedResources); err != nil {
+		return err
+	}
+	if err := RecordAudit(ctx, tx, AuditCreate, "area", req.AreaID, nil, req); err != nil {
+		return err
+	}
+	event, err := s.publisher.Stage(ctx, tx, events.AreaCreated, req)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to create area: %w", err)
+	}
+	broadcast(ctx, s.publisher, event)
+	return nil
+}
+
+// areaSorts are the fields GET /api/areas may be sorted by
+var areaSorts = map[string]sortColumn{
+	"areaId":         {"area_id", "text"},
+	"urgencyLevel":   {"urgency_level", "integer"},
+	"population":     {"population", "integer"},
+	"timeConstraint": {"time_constraint", "integer"},
+	"createdAt":      {"created_at", "timestamptz"},
+}
+
+// ListAreas returns one page of areas matching filter, filtering, sorting and paging all run in SQL.
+// The most urgent areas come first unless filter.Sort says otherwise.
+func (s *AreaService) ListAreas(ctx context.Context, filter models.AreaFilter) (page models.Page[models.Area], err error) {
+	ctx, span := tracing.Start(ctx, "AreaService.ListAreas")
+	defer func() { tracing.End(span, err) }()
+
+	sort, err := parseSort(filter.Sort, areaSorts, "-urgencyLevel")
+	if err != nil {
+		return page, err
+	}
+	cursor, err := decodeCursor(filter.Cursor, sort)
+	if err != nil {
+		return page, err
+	}
+	limit := filter.Limit
+	if limit == 0 {
+		limit = defaultPageSize
+	}
+
+	var q listQuery
+	if filter.MinUrgency > 0 {
+		q.where("urgency_level >= $%d", filter.MinUrgency)
+	}
+	if filter.MaxUrgency > 0 {
+		q.where("urgency_level <= $%d", filter.MaxUrgency)
+	}
+	for _, resource := range filter.RequiresResource {
+		q.where("EXISTS (SELECT 1 FROM area_requirements r WHERE r.area_id = areas.area_id AND r.resource = $%d AND r.quantity > 0)", resource)
+	}
+	if filter.HasRoute != nil {
+		exists := "EXISTS (SELECT 1 FROM truck_area_travel t WHERE t.area_id = areas.area_id)"
+		if !*filter.HasRoute {
+			exists = "NOT " + exists
+		}
+		q.where(exists)
+	}
+	if filter.CreatedSince != nil {
+		q.where("created_at >= $%d", *filter.CreatedSince)
+	}
+
+	if err := s.db.QueryRowContext(ctx, "SELECT count(*) FROM areas"+q.clause(), q.args...).Scan(&page.Total); err != nil {
+		return page, fmt.Errorf("failed to count areas: %w", err)
+	}
+
+	if cursor != nil {
+		sort.after(&q, "area_id", *cursor)
+	}
+	q.args = append(q.args, limit+1)
+	query := "SELECT area_id, urgency_level, area_requirements_json(area_id), time_constraint, earliest_arrival, latest_arrival, population, vulnerable_groups, latitude, longitude, " +
+		"COALESCE(disaster_type, ''), COALESCE(duration_days, 0), ARRAY(SELECT resource FROM area_requirements r WHERE r.area_id = areas.area_id AND r.estimated ORDER BY resource), created_at, " + sort.value() +
+		" FROM areas" + q.clause() + sort.orderBy("area_id") + fmt.Sprintf(" LIMIT $%d", len(q.args))
+
+	rows, err := s.db.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		return page, fmt.Errorf("failed to fetch areas: %w", err)
+	}
+	defer rows.Close()
+
+	page.Items = []models.Area{}
+	var lastValue string
+	for rows.Next() {
+		var area models.Area
+		var resources, vulnerable []byte
+		var sortValue string
+		if err := rows.Scan(&area.AreaID, &area.UrgencyLevel, &resources, &area.TimeConstraint, &area.EarliestArrival, &area.LatestArrival, &area.Population, &vulnerable, &area.Latitude, &area.Longitude, &area.DisasterType, &area.DurationDays, pq.Array(&area.EstimatedResources), &area.CreatedAt, &sortValue); err != nil {
+			return page, fmt.Errorf("failed to parse area data: %w", err)
+		}
+		if len(page.Items) == limit {
+			// The extra row only tells there is another page
+			page.NextCursor = pageCursor{Sort: sort.String(), Value: lastValue, Key: page.Items[limit-1].AreaID}.encode()
+			break
+		}
+		if err := json.Unmarshal(resources, &area.RequiredResources); err != nil {
+			return page, fmt.Errorf("failed to parse area resources: %w", err)
+		}
+		if err := json.Unmarshal(vulnerable, &area.VulnerableGroups); err != nil {
+			return page, fmt.Errorf("failed to parse area vulnerable groups: %w", err)
+		}
+		page.Items = append(page.Items, area)
+		lastValue = sortValue
+	}
+
+	return page, rows.Err()
+}
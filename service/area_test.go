@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"workship-disaster-api/events"
+	"workship-disaster-api/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// textArray matches a pq.Array argument by its encoded value, a nil slice is sent as NULL
+type textArray string
+
+// testPublisher broadcasts to an in-memory Redis and has no sinks, so staging writes nothing
+func testPublisher(t *testing.T) *events.Publisher {
+	t.Helper()
+	server := miniredis.RunT(t)
+	return events.NewPublisher(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+}
+
+func (a textArray) Match(v driver.Value) bool {
+	switch v := v.(type) {
+	case string:
+		return v == string(a)
+	case []byte:
+		return string(v) == string(a)
+	}
+	return false
+}
+
+// TestCreateAreaWithoutDisasterType checks an area with manual quantities marks none as estimated,
+// the column is NOT NULL so the estimated list must never be sent as NULL
+func TestCreateAreaWithoutDisasterType(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	service := NewAreaService(db, DefaultDemandConfig(), testPublisher(t))
+	req := models.CreateAreaRequest{
+		AreaID:            "A1",
+		UrgencyLevel:      3,
+		RequiredResources: map[string]int{"water": 100, "food": 20},
+		TimeConstraint:    60,
+	}
+	if err := service.EstimateDemand(&req); err != nil {
+		t.Fatalf("EstimateDemand: %v", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO areas").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM area_requirements").WithArgs("A1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO area_requirements .* COALESCE\(r.resource = ANY\(\$4::text\[\]\), false\)`).
+		WithArgs("A1", textArray(`{"food","water"}`), textArray("{20,100}"), textArray("{}")).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO audit_events").WithArgs(systemActor, "", AuditCreate, "area", "A1", nil, sqlmock.AnyArg(), "").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := service.CreateArea(context.Background(), req); err != nil {
+		t.Fatalf("CreateArea: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestCreateAreaEstimatesDemand checks estimated quantities are flagged and manual ones override them
+func TestCreateAreaEstimatesDemand(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	service := NewAreaService(db, DefaultDemandConfig(), testPublisher(t))
+	req := models.CreateAreaRequest{
+		AreaID:            "A2",
+		UrgencyLevel:      4,
+		RequiredResources: map[string]int{"water": 500},
+		TimeConstraint:    60,
+		Population:        100,
+		DisasterType:      "flood",
+		DurationDays:      2,
+	}
+	if err := service.EstimateDemand(&req); err != nil {
+		t.Fatalf("EstimateDemand: %v", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO areas").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM area_requirements").WithArgs("A2").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO area_requirements").
+		WithArgs("A2", textArray(`{"food","medicine","water"}`), textArray("{200,2,500}"), textArray(`{"food","medicine"}`)).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("INSERT INTO audit_events").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := service.CreateArea(context.Background(), req); err != nil {
+		t.Fatalf("CreateArea: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
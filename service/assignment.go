@@ -1,43 +1,308 @@
 package service
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
+	"workship-disaster-api/apperr"
+	"workship-disaster-api/events"
+	"workship-disaster-api/metrics"
 	"workship-disaster-api/models"
+	"workship-disaster-api/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	// ErrAssignmentNotFound is returned when an area has no locked assignment
+	ErrAssignmentNotFound = errors.New("assignment not found")
+	// ErrAssignmentLocked is returned when an area already has a locked assignment
+	ErrAssignmentLocked = errors.New("area already has a locked assignment")
+	// ErrTruckCommitted is returned when a truck is already on a confirmed or in-flight assignment
+	ErrTruckCommitted = errors.New("truck is already committed to another assignment")
+	// ErrInvalidStatusTransition is returned when a locked assignment can't move to the requested status
+	ErrInvalidStatusTransition = errors.New("invalid assignment status transition")
 )
 
 type AssignmentService struct {
+	db           *sql.DB
 	areaService  *AreaService
 	truckService *TruckService
+	priority     PriorityConfig
+	publisher    *events.Publisher
+}
+
+func NewAssignmentService(db *sql.DB, areaService *AreaService, truckService *TruckService, priority PriorityConfig, publisher *events.Publisher) *AssignmentService {
+	return &AssignmentService{db, areaService, truckService, priority, publisher}
+}
+
+// CreateAssignments use for api assignments, ETAs are computed from planStart.
+// Locked assignments are carried over as-is and their areas and trucks are left out of matching,
+// see partitionLocked.
+func (s *AssignmentService) CreateAssignments(ctx context.Context, planStart time.Time) ([]models.Assignment, error) {
+	return s.assignments(ctx, planStart, true)
 }
 
-func NewAssignmentService(areaService *AreaService, truckService *TruckService) *AssignmentService {
-	return &AssignmentService{areaService, truckService}
+// PreviewAssignments computes assignments for a what-if plan start like CreateAssignments,
+// without updating the plan outcome metrics
+func (s *AssignmentService) PreviewAssignments(ctx context.Context, planStart time.Time) ([]models.Assignment, error) {
+	return s.assignments(ctx, planStart, false)
 }
 
-// CreateAssignments use for api assignments
-func (s *AssignmentService) CreateAssignments() ([]models.Assignment, error) {
-	areas, err := s.areaService.GetAllAreas()
+func (s *AssignmentService) assignments(ctx context.Context, planStart time.Time, record bool) ([]models.Assignment, error) {
+	areas, err := s.areaService.GetAllAreas(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get areas: %w", err)
 	}
 
-	trucks, err := s.truckService.GetAllTrucks()
+	trucks, err := s.truckService.GetAllTrucks(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get trucks: %w", err)
 	}
 
+	locked, err := s.GetLockedAssignments(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get locked assignments: %w", err)
+	}
+
+	locked, openAreas, freeTrucks := partitionLocked(areas, trucks, locked)
+
+	_, span := tracing.Start(ctx, "planner.build_assignments",
+		attribute.Int("areas", len(openAreas)),
+		attribute.Int("trucks", len(freeTrucks)),
+	)
+	planned := BuildAssignments(openAreas, freeTrucks, planStart, s.priority)
+	span.End()
+	for i := range planned {
+		if planned[i].TruckID != "" {
+			planned[i].Status = models.AssignmentStatusPlanned
+		}
+	}
+	if record {
+		recordPlanOutcome(openAreas, locked, planned)
+	}
+
+	return append(locked, planned...), nil
+}
+
+// partitionLocked returns the locked assignments to carry over with the areas and trucks left to match.
+// Confirmed and in-flight assignments hold their area and truck. A delivered assignment only holds
+// its area while the area needs nothing more, once it needs more again the area is planned anew.
+func partitionLocked(areas []AreaData, trucks []TruckData, locked []models.Assignment) (carried []models.Assignment, openAreas []AreaData, freeTrucks []TruckData) {
+	outstanding := make(map[string]bool, len(areas))
+	for _, area := range areas {
+		for _, units := range area.RequiredResource {
+			outstanding[area.ID] = outstanding[area.ID] || units > 0
+		}
+	}
+
+	lockedAreas := make(map[string]bool)
+	committedTrucks := make(map[string]bool)
+	carried = []models.Assignment{}
+	for _, assignment := range locked {
+		if assignment.Status == models.AssignmentStatusDelivered {
+			if outstanding[assignment.AreaID] {
+				continue
+			}
+		} else {
+			committedTrucks[assignment.TruckID] = true
+		}
+		lockedAreas[assignment.AreaID] = true
+		carried = append(carried, assignment)
+	}
+
+	openAreas = make([]AreaData, 0, len(areas))
+	for _, area := range areas {
+		if !lockedAreas[area.ID] {
+			openAreas = append(openAreas, area)
+		}
+	}
+
+	freeTrucks = make([]TruckData, 0, len(trucks))
+	for _, truck := range trucks {
+		if !committedTrucks[truck.ID] {
+			freeTrucks = append(freeTrucks, truck)
+		}
+	}
+	return carried, openAreas, freeTrucks
+}
+
+// GetLockedAssignments fetches confirmed, in-flight and delivered assignments
+func (s *AssignmentService) GetLockedAssignments(ctx context.Context) ([]models.Assignment, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT area_id, truck_id, status, resources_delivered, departure_time, estimated_arrival FROM locked_assignments ORDER BY created_at, area_id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch locked assignments: %w", err)
+	}
+	defer rows.Close()
+
+	locked := []models.Assignment{}
+	for rows.Next() {
+		var assignment models.Assignment
+		var resourcesJSON []byte
+		if err := rows.Scan(&assignment.AreaID, &assignment.TruckID, &assignment.Status, &resourcesJSON, &assignment.DepartureTime, &assignment.EstimatedArrival); err != nil {
+			return nil, fmt.Errorf("failed to parse locked assignment: %w", err)
+		}
+
+		if err := json.Unmarshal(resourcesJSON, &assignment.ResourcesDelivered); err != nil {
+			return nil, fmt.Errorf("failed to parse locked assignment resources: %w", err)
+		}
+
+		locked = append(locked, assignment)
+	}
+
+	return locked, rows.Err()
+}
+
+// LockAssignment confirms a planned assignment so later replans keep it
+func (s *AssignmentService) LockAssignment(ctx context.Context, planID int64, assignment models.Assignment) error {
+	resourcesJSON, err := json.Marshal(assignment.ResourcesDelivered)
+	if err != nil {
+		return fmt.Errorf("failed to encode resources: %w", err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// A delivered assignment is replaced when its area needed more and was planned again
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO locked_assignments (area_id, truck_id, plan_id, status, resources_delivered, departure_time, estimated_arrival)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (area_id) DO UPDATE SET
+			truck_id = EXCLUDED.truck_id, plan_id = EXCLUDED.plan_id, status = EXCLUDED.status,
+			resources_delivered = EXCLUDED.resources_delivered, departure_time = EXCLUDED.departure_time,
+			estimated_arrival = EXCLUDED.estimated_arrival, created_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE locked_assignments.status = 'delivered'`,
+		assignment.AreaID, assignment.TruckID, planID, models.AssignmentStatusConfirmed, resourcesJSON, assignment.DepartureTime, assignment.EstimatedArrival,
+	)
+	if err != nil {
+		if apperr.IsUniqueViolation(err) && apperr.Constraint(err) == "locked_assignments_active_truck" {
+			return ErrTruckCommitted
+		}
+		return fmt.Errorf("failed to lock assignment: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrAssignmentLocked
+	}
+
+	assignment.Status = models.AssignmentStatusConfirmed
+	if err := RecordAudit(ctx, tx, AuditCreate, "assignment", assignment.AreaID, nil, assignment); err != nil {
+		return err
+	}
+	event, err := s.publisher.Stage(ctx, tx, events.AssignmentConfirmed, assignment)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit assignment: %w", err)
+	}
+	broadcast(ctx, s.publisher, event)
+
+	return nil
+}
+
+// UpdateLockedStatus moves a locked assignment forward, cancelling releases the lock and delivering
+// takes the delivered resources off the truck and the area. It returns the status the assignment had before.
+func (s *AssignmentService) UpdateLockedStatus(ctx context.Context, areaID, status string) (string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var previous, truckID string
+	var resourcesJSON []byte
+	err = tx.QueryRowContext(ctx, "SELECT status, truck_id, resources_delivered FROM locked_assignments WHERE area_id = $1 FOR UPDATE", areaID).Scan(&previous, &truckID, &resourcesJSON)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrAssignmentNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch assignment: %w", err)
+	}
+
+	// confirmed -> in_transit -> delivered, anything but delivered can be cancelled
+	allowed := map[string][]string{
+		models.AssignmentStatusInTransit: {models.AssignmentStatusConfirmed},
+		models.AssignmentStatusDelivered: {models.AssignmentStatusConfirmed, models.AssignmentStatusInTransit},
+		models.AssignmentStatusCancelled: {models.AssignmentStatusConfirmed, models.AssignmentStatusInTransit},
+	}
+	valid := false
+	for _, from := range allowed[status] {
+		valid = valid || from == previous
+	}
+	if !valid {
+		return previous, ErrInvalidStatusTransition
+	}
+
+	if status == models.AssignmentStatusCancelled {
+		_, err = tx.ExecContext(ctx, "DELETE FROM locked_assignments WHERE area_id = $1", areaID)
+	} else {
+		_, err = tx.ExecContext(ctx, "UPDATE locked_assignments SET status = $2, updated_at = CURRENT_TIMESTAMP WHERE area_id = $1", areaID, status)
+	}
+	if err != nil {
+		return previous, fmt.Errorf("failed to update assignment status: %w", err)
+	}
+
+	// What was delivered leaves the truck and no longer counts as needed by the area
+	if status == models.AssignmentStatusDelivered {
+		var delivered map[string]int
+		if err := json.Unmarshal(resourcesJSON, &delivered); err != nil {
+			return previous, fmt.Errorf("failed to parse delivered resources: %w", err)
+		}
+		if err := recordDelivery(ctx, tx, areaID, truckID, delivered); err != nil {
+			return previous, err
+		}
+	}
+
+	if err := RecordAudit(ctx, tx, AuditUpdate, "assignment", areaID,
+		map[string]string{"area_id": areaID, "status": previous},
+		map[string]string{"area_id": areaID, "status": status},
+	); err != nil {
+		return previous, err
+	}
+
+	// Event types mirror the status names, e.g. assignment.delivered
+	event, err := s.publisher.Stage(ctx, tx, "assignment."+status, map[string]string{
+		"area_id": areaID,
+		"status":  status,
+	})
+	if err != nil {
+		return previous, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return previous, fmt.Errorf("failed to commit assignment status: %w", err)
+	}
+	broadcast(ctx, s.publisher, event)
+
+	return previous, nil
+}
+
+// BuildAssignments ranks areas by priority and matches trucks to them without touching the database
+func BuildAssignments(areas []AreaData, trucks []TruckData, planStart time.Time, priority PriorityConfig) []models.Assignment {
+	areas, scores := priority.RankAreas(areas, planStart)
+
 	// Keep track of used trucks
 	usedTrucks := make(map[string]bool)
 	assignments := []models.Assignment{}
 
 	for _, area := range areas {
 		var bestTruck *TruckData
-		var bestTravelTime int
+		var bestDeparture, bestArrival time.Time
 		var deliveredResources map[string]int
 
+		earliest, latest := area.ArrivalWindow(planStart)
+		score := scores[area.ID]
+
 		// Variables to handle edge cases
 		hasTruckWithTravelTimeEntry := false
 		hasTruckWithSufficientResources := false
+		hasAvailableTruckWithSufficientResources := false
 		hasTruckWithSufficientResourcesAndTime := false
 
 		for _, truck := range trucks {
@@ -54,13 +319,32 @@ func (s *AssignmentService) CreateAssignments() ([]models.Assignment, error) {
 			if canFulfill(truck.AvailableResources, area.RequiredResource) {
 				hasTruckWithSufficientResources = true
 
-				if ok && travelTime <= area.TimeConstraint {
+				if !ok {
+					continue
+				}
+
+				// Busy or off-shift trucks may leave later than the plan start, and no truck
+				// leaves so early that it arrives before the area's window opens
+				travel := time.Duration(travelTime) * time.Minute
+				notBefore := planStart
+				if opens := earliest.Add(-travel); opens.After(notBefore) {
+					notBefore = opens
+				}
+				departure, available := truck.Departure(notBefore, travel)
+				if !available {
+					continue
+				}
+				hasAvailableTruckWithSufficientResources = true
+
+				arrival := departure.Add(travel)
+				if withinWindow(arrival, earliest, latest) {
 					hasTruckWithSufficientResourcesAndTime = true
 
-					// If current truck travel time is best
-					if bestTruck == nil || travelTime < bestTravelTime {
+					// If current truck arrival is best
+					if bestTruck == nil || arrival.Before(bestArrival) {
 						bestTruck = &truck
-						bestTravelTime = travelTime
+						bestDeparture = departure
+						bestArrival = arrival
 					}
 				}
 			}
@@ -76,6 +360,9 @@ func (s *AssignmentService) CreateAssignments() ([]models.Assignment, error) {
 				AreaID:             area.ID,
 				TruckID:            assignedTruckID,
 				ResourcesDelivered: deliveredResources,
+				DepartureTime:      &bestDeparture,
+				EstimatedArrival:   &bestArrival,
+				Priority:           &score,
 			})
 		} else {
 			// Create detailed fallback message
@@ -84,20 +371,46 @@ func (s *AssignmentService) CreateAssignments() ([]models.Assignment, error) {
 				msg = "No trucks have a valid route to this area."
 			} else if !hasTruckWithSufficientResources {
 				msg = "No truck has sufficient resources to fulfill this area's needs."
+			} else if !hasAvailableTruckWithSufficientResources {
+				msg = "All trucks with sufficient resources are busy, in maintenance, offline or off shift."
 			} else if !hasTruckWithSufficientResourcesAndTime {
-				msg = "All trucks with sufficient resources exceed the time constraint."
+				msg = "All trucks with sufficient resources arrive outside the area's arrival window."
 			} else {
 				msg = "No trucks available for assignment."
 			}
 
 			assignments = append(assignments, models.Assignment{
-				AreaID:  area.ID,
-				Message: msg,
+				AreaID:   area.ID,
+				Priority: &score,
+				Message:  msg,
 			})
 		}
 	}
 
-	return assignments, nil
+	return assignments
+}
+
+// recordPlanOutcome updates the planner gauges, locked assignments count as served
+// and an unserved area's whole requirement counts as unmet
+func recordPlanOutcome(openAreas []AreaData, locked, planned []models.Assignment) {
+	required := make(map[string]map[string]int, len(openAreas))
+	for _, area := range openAreas {
+		required[area.ID] = area.RequiredResource
+	}
+
+	served, unserved := len(locked), 0
+	unmet := map[string]int{}
+	for _, assignment := range planned {
+		if assignment.TruckID != "" {
+			served++
+			continue
+		}
+		unserved++
+		for resource, units := range required[assignment.AreaID] {
+			unmet[resource] += units
+		}
+	}
+	metrics.PlanOutcome(served, unserved, unmet)
 }
 
 func canFulfill(available, required map[string]int) bool {
@@ -108,3 +421,8 @@ func canFulfill(available, required map[string]int) bool {
 	}
 	return true
 }
+
+// withinWindow reports whether eta falls inside [earliest, latest]
+func withinWindow(eta, earliest, latest time.Time) bool {
+	return !eta.Before(earliest) && !eta.After(latest)
+}
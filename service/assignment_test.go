@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"workship-disaster-api/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestPartitionLocked(t *testing.T) {
+	areas := []AreaData{
+		{ID: "confirmed", RequiredResource: map[string]int{"water": 10}},
+		{ID: "delivered", RequiredResource: map[string]int{"water": 0}},
+		{ID: "needs-more", RequiredResource: map[string]int{"water": 0, "food": 5}},
+		{ID: "open", RequiredResource: map[string]int{"food": 5}},
+	}
+	trucks := []TruckData{{ID: "T1"}, {ID: "T2"}, {ID: "T3"}, {ID: "T4"}}
+	locked := []models.Assignment{
+		{AreaID: "confirmed", TruckID: "T1", Status: models.AssignmentStatusConfirmed},
+		{AreaID: "delivered", TruckID: "T2", Status: models.AssignmentStatusDelivered},
+		{AreaID: "needs-more", TruckID: "T3", Status: models.AssignmentStatusDelivered},
+	}
+
+	carried, openAreas, freeTrucks := partitionLocked(areas, trucks, locked)
+
+	if got := assignmentAreas(carried); !equalStrings(got, []string{"confirmed", "delivered"}) {
+		t.Errorf("carried %v, a delivered area that needs more must be released", got)
+	}
+	if got := ids(openAreas); !equalStrings(got, []string{"needs-more", "open"}) {
+		t.Errorf("open areas %v", got)
+	}
+	var free []string
+	for _, truck := range freeTrucks {
+		free = append(free, truck.ID)
+	}
+	if !equalStrings(free, []string{"T2", "T3", "T4"}) {
+		t.Errorf("free trucks %v, only confirmed and in-flight trucks are committed", free)
+	}
+}
+
+// TestDeliveredUpdatesInventoryAndNeed checks delivering takes the units off the truck and the area
+// in the same transaction as the status change
+func TestDeliveredUpdatesInventoryAndNeed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	service := NewAssignmentService(db, nil, nil, DefaultPriorityConfig(), testPublisher(t))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, truck_id, resources_delivered FROM locked_assignments").WithArgs("A1").
+		WillReturnRows(sqlmock.NewRows([]string{"status", "truck_id", "resources_delivered"}).AddRow("in_transit", "T1", []byte(`{"water":100,"food":20}`)))
+	mock.ExpectExec("UPDATE locked_assignments SET status").WithArgs("A1", "delivered").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE truck_inventory t SET quantity = GREATEST\(t.quantity - d.quantity, 0\)`).
+		WithArgs("T1", textArray(`{"food","water"}`), textArray("{20,100}")).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`UPDATE area_requirements t SET quantity = GREATEST\(t.quantity - d.quantity, 0\)`).
+		WithArgs("A1", textArray(`{"food","water"}`), textArray("{20,100}")).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO audit_events").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	previous, err := service.UpdateLockedStatus(context.Background(), "A1", models.AssignmentStatusDelivered)
+	if err != nil || previous != models.AssignmentStatusInTransit {
+		t.Fatalf("UpdateLockedStatus = %q, %v", previous, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInTransitLeavesInventory(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	service := NewAssignmentService(db, nil, nil, DefaultPriorityConfig(), testPublisher(t))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, truck_id, resources_delivered FROM locked_assignments").WithArgs("A1").
+		WillReturnRows(sqlmock.NewRows([]string{"status", "truck_id", "resources_delivered"}).AddRow("confirmed", "T1", []byte(`{"water":100}`)))
+	mock.ExpectExec("UPDATE locked_assignments SET status").WithArgs("A1", "in_transit").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO audit_events").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if _, err := service.UpdateLockedStatus(context.Background(), "A1", models.AssignmentStatusInTransit); err != nil {
+		t.Fatalf("UpdateLockedStatus: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestLockAssignmentKeepsActiveLock checks the upsert only replaces delivered assignments
+func TestLockAssignmentKeepsActiveLock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	service := NewAssignmentService(db, nil, nil, DefaultPriorityConfig(), testPublisher(t))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO locked_assignments .* ON CONFLICT \(area_id\) DO UPDATE .* WHERE locked_assignments.status = 'delivered'`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err = service.LockAssignment(context.Background(), 1, models.Assignment{AreaID: "A1", TruckID: "T1"})
+	if !errors.Is(err, ErrAssignmentLocked) {
+		t.Fatalf("LockAssignment = %v, want ErrAssignmentLocked", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestBuildAssignmentsWaitsForWindow checks a window opening after the plan start delays the departure
+// instead of rejecting the truck, while the shift and the latest arrival still apply
+func TestBuildAssignmentsWaitsForWindow(t *testing.T) {
+	areas := []AreaData{
+		{ID: "later", Urgency: 5, RequiredResource: map[string]int{"water": 10}, EarliestArrival: at(2 * time.Hour), LatestArrival: at(3 * time.Hour)},
+		{ID: "after-shift", Urgency: 1, RequiredResource: map[string]int{"food": 10}, EarliestArrival: at(2 * time.Hour), LatestArrival: at(3 * time.Hour)},
+	}
+	shiftEnds, err := ParseShiftWindow("08:00", "13:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trucks := []TruckData{
+		{ID: "T1", Status: models.TruckStatusAvailable, AvailableResources: map[string]int{"water": 10}, TravelTimeToArea: map[string]int{"later": 30}},
+		{ID: "T2", Status: models.TruckStatusAvailable, AvailableResources: map[string]int{"food": 10}, TravelTimeToArea: map[string]int{"after-shift": 30}, Shift: shiftEnds},
+	}
+
+	assignments := BuildAssignments(areas, trucks, planStart, DefaultPriorityConfig())
+
+	if len(assignments) != 2 {
+		t.Fatalf("got %d assignments", len(assignments))
+	}
+	later := assignments[0]
+	if later.TruckID != "T1" || !later.DepartureTime.Equal(*at(90 * time.Minute)) || !later.EstimatedArrival.Equal(*at(2 * time.Hour)) {
+		t.Errorf("later = %+v, want T1 leaving 90 minutes in to arrive when the window opens", later)
+	}
+	// Leaving at 13:30 is after T2's shift, the next shift arrives after the window closed
+	if afterShift := assignments[1]; afterShift.TruckID != "" || !strings.Contains(afterShift.Message, "arrival window") {
+		t.Errorf("after-shift = %+v, want it unassigned because of the window", afterShift)
+	}
+}
+
+func assignmentAreas(assignments []models.Assignment) []string {
+	out := make([]string, len(assignments))
+	for i, assignment := range assignments {
+		out[i] = assignment.AreaID
+	}
+	return out
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
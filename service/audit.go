@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"workship-disaster-api/events"
+	"workship-disaster-api/models"
+)
+
+// Audit actions
+const (
+	AuditCreate       = "create"
+	AuditUpdate       = "update"
+	AuditDelete       = "delete"
+	AuditPlanComputed = "plan.computed"
+)
+
+// systemActor is recorded for changes made by background workers
+const systemActor = "system:planner"
+
+// AuditActor identifies who caused a change and the request it came from
+type AuditActor struct {
+	Actor     string
+	Role      string
+	RequestID string
+}
+
+type auditActorKey struct{}
+
+// WithAuditActor attaches the caller to ctx so services can record it
+func WithAuditActor(ctx context.Context, actor AuditActor) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, actor)
+}
+
+func auditActorFrom(ctx context.Context) AuditActor {
+	if actor, ok := ctx.Value(auditActorKey{}).(AuditActor); ok && actor.Actor != "" {
+		return actor
+	}
+	return AuditActor{Actor: systemActor}
+}
+
+// AuditService queries the append-only audit trail, changes write to it with RecordAudit
+type AuditService struct {
+	db *sql.DB
+}
+
+func NewAuditService(db *sql.DB) *AuditService {
+	return &AuditService{db: db}
+}
+
+// RecordAudit appends an audit event for the actor attached to ctx in tx, the transaction of the
+// change, so a committed change always has its entry. before and after may be nil.
+func RecordAudit(ctx context.Context, tx events.Execer, action, entityType, entityID string, before, after interface{}) error {
+	beforeJSON, err := auditJSON(before)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit before state: %w", err)
+	}
+	afterJSON, err := auditJSON(after)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit after state: %w", err)
+	}
+
+	actor := auditActorFrom(ctx)
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO audit_events (actor, actor_role, action, entity_type, entity_id, before, after, request_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+		actor.Actor, actor.Role, action, entityType, entityID, beforeJSON, afterJSON, actor.RequestID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record audit event: %w", err)
+	}
+
+	return nil
+}
+
+func auditJSON(value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return encoded, nil
+}
+
+// ListEvents returns matching audit events, newest first
+func (s *AuditService) ListEvents(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error) {
+	var conditions []string
+	var args []interface{}
+	where := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Actor != "" {
+		where("actor = $%d", filter.Actor)
+	}
+	if filter.Action != "" {
+		where("action = $%d", filter.Action)
+	}
+	if filter.EntityType != "" {
+		where("entity_type = $%d", filter.EntityType)
+	}
+	if filter.EntityID != "" {
+		where("entity_id = $%d", filter.EntityID)
+	}
+	if filter.RequestID != "" {
+		where("request_id = $%d", filter.RequestID)
+	}
+	if filter.Since != nil {
+		where("occurred_at >= $%d", *filter.Since)
+	}
+	if filter.Until != nil {
+		where("occurred_at < $%d", *filter.Until)
+	}
+	if filter.BeforeID > 0 {
+		where("id < $%d", filter.BeforeID)
+	}
+
+	query := "SELECT id, occurred_at, actor, actor_role, action, entity_type, entity_id, before, after, request_id FROM audit_events"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch audit events: %w", err)
+	}
+	defer rows.Close()
+
+	auditEvents := []models.AuditEvent{}
+	for rows.Next() {
+		var event models.AuditEvent
+		var before, after []byte
+		if err := rows.Scan(&event.ID, &event.OccurredAt, &event.Actor, &event.ActorRole, &event.Action, &event.EntityType, &event.EntityID, &before, &after, &event.RequestID); err != nil {
+			return nil, fmt.Errorf("failed to parse audit event: %w", err)
+		}
+		event.Before = before
+		event.After = after
+		auditEvents = append(auditEvents, event)
+	}
+
+	return auditEvents, rows.Err()
+}
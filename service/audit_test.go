@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"workship-disaster-api/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// TestFailedAuditRollsBackChange checks a change whose audit entry can't be written is never committed
+func TestFailedAuditRollsBackChange(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	service := NewAreaService(db, DefaultDemandConfig(), testPublisher(t))
+	req := models.CreateAreaRequest{
+		AreaID:            "A1",
+		UrgencyLevel:      3,
+		RequiredResources: map[string]int{"water": 100},
+		TimeConstraint:    60,
+	}
+	if err := service.EstimateDemand(&req); err != nil {
+		t.Fatalf("EstimateDemand: %v", err)
+	}
+
+	auditErr := errors.New("audit_events is unavailable")
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO areas").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM area_requirements").WithArgs("A1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO area_requirements").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO audit_events").WillReturnError(auditErr)
+	mock.ExpectRollback()
+
+	err = service.CreateArea(WithAuditActor(context.Background(), AuditActor{Actor: "alice", Role: models.RoleAdmin}), req)
+	if !errors.Is(err, auditErr) {
+		t.Fatalf("CreateArea = %v, want the audit error", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestRecordAuditUsesActor checks the entry carries the attached actor and falls back to the planner
+func TestRecordAuditUsesActor(t *testing.T) {
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		actor string
+		role  string
+	}{
+		{"attached", WithAuditActor(context.Background(), AuditActor{Actor: "alice", Role: models.RoleDispatcher, RequestID: "r1"}), "alice", models.RoleDispatcher},
+		{"background", context.Background(), systemActor, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			mock.ExpectExec("INSERT INTO audit_events").
+				WithArgs(tt.actor, tt.role, AuditUpdate, "truck", "T1", []byte(`{"status":"available"}`), []byte(`{"status":"maintenance"}`), sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+
+			err = RecordAudit(tt.ctx, db, AuditUpdate, "truck", "T1",
+				map[string]string{"status": "available"}, map[string]string{"status": "maintenance"})
+			if err != nil {
+				t.Fatalf("RecordAudit: %v", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+	"workship-disaster-api/models"
+	"workship-disaster-api/tracing"
+)
+
+// Coverage report defaults
+const (
+	defaultCoverageTop = 10
+	defaultRiskMinutes = 30
+)
+
+// CoverageService reports how well the latest plan meets the demand of the current areas
+type CoverageService struct {
+	planner      *PlannerService
+	areaService  *AreaService
+	truckService *TruckService
+}
+
+func NewCoverageService(planner *PlannerService, areaService *AreaService, truckService *TruckService) *CoverageService {
+	return &CoverageService{planner: planner, areaService: areaService, truckService: truckService}
+}
+
+// Report builds the coverage report of the latest plan, ErrPlanNotFound when nothing was planned yet
+func (s *CoverageService) Report(ctx context.Context, opts models.CoverageOptions) (report models.CoverageReport, err error) {
+	ctx, span := tracing.Start(ctx, "CoverageService.Report")
+	defer func() { tracing.End(span, err) }()
+
+	plan, err := s.planner.LatestPlan(ctx)
+	if err != nil {
+		return report, err
+	}
+	areas, err := s.areaService.GetAllAreas(ctx)
+	if err != nil {
+		return report, err
+	}
+	trucks, err := s.truckService.GetAllTrucks(ctx)
+	if err != nil {
+		return report, err
+	}
+	return BuildCoverage(plan, areas, trucks, time.Now(), opts), nil
+}
+
+// BuildCoverage compares the plan with the current areas and trucks. Areas added since the plan
+// count as unserved, delivered assignments count as served.
+func BuildCoverage(plan *models.Plan, areas []AreaData, trucks []TruckData, now time.Time, opts models.CoverageOptions) models.CoverageReport {
+	if opts.Top == 0 {
+		opts.Top = defaultCoverageTop
+	}
+	riskMinutes := defaultRiskMinutes
+	if opts.RiskMinutes != nil {
+		riskMinutes = *opts.RiskMinutes
+	}
+
+	report := models.CoverageReport{
+		PlanID:      plan.ID,
+		PlanStart:   plan.PlanStart,
+		GeneratedAt: now.UTC(),
+		Areas:       len(areas),
+		Resources:   []models.ResourceCoverage{},
+		Shortfalls:  []models.Shortfall{},
+		IdleSurplus: models.IdleSurplus{Trucks: []string{}, Resources: map[string]int{}},
+		AtRisk:      []models.AtRiskArea{},
+	}
+
+	assignments := make(map[string]models.Assignment, len(plan.Assignments))
+	committedTrucks := map[string]bool{}
+	for _, assignment := range plan.Assignments {
+		assignments[assignment.AreaID] = assignment
+		if assignment.TruckID != "" && assignment.Status != models.AssignmentStatusDelivered {
+			committedTrucks[assignment.TruckID] = true
+		}
+	}
+
+	// Most urgent first
+	report.ByUrgency = make([]models.UrgencyCoverage, 5)
+	byUrgency := map[int]*models.UrgencyCoverage{}
+	for i := range report.ByUrgency {
+		report.ByUrgency[i].UrgencyLevel = 5 - i
+		byUrgency[5-i] = &report.ByUrgency[i]
+	}
+	required, delivered := map[string]int{}, map[string]int{}
+	riskMargin := time.Duration(riskMinutes) * time.Minute
+
+	for _, area := range areas {
+		assignment, planned := assignments[area.ID]
+		served := planned && assignment.TruckID != ""
+		for resource, units := range area.RequiredResource {
+			required[resource] += units
+		}
+
+		if level, ok := byUrgency[area.Urgency]; ok {
+			level.Areas++
+			if served {
+				level.Served++
+			}
+		}
+		_, latest := area.ArrivalWindow(plan.PlanStart)
+
+		if served {
+			report.Served++
+			for resource, units := range assignment.ResourcesDelivered {
+				delivered[resource] += units
+			}
+			// Delivering takes the units off the area's requirements, they were still part of its need
+			if assignment.Status == models.AssignmentStatusDelivered {
+				for resource, units := range assignment.ResourcesDelivered {
+					required[resource] += units
+				}
+				continue
+			}
+			if assignment.EstimatedArrival == nil {
+				continue
+			}
+			// Confirmed and planned trips still on the road may arrive too close to the deadline
+			eta := *assignment.EstimatedArrival
+			slack := latest.Sub(eta)
+			reason := models.RiskTight
+			if now.After(latest) {
+				reason = models.RiskOverdue
+			}
+			if slack < riskMargin || reason == models.RiskOverdue {
+				report.AtRisk = append(report.AtRisk, models.AtRiskArea{
+					AreaID: area.ID, UrgencyLevel: area.Urgency, Reason: reason, TruckID: assignment.TruckID,
+					EstimatedArrival: &eta, LatestArrival: latest, SlackMinutes: roundMinutes(slack),
+				})
+			}
+			continue
+		}
+
+		shortfall := models.Shortfall{AreaID: area.ID, UrgencyLevel: area.Urgency, Missing: map[string]int{}, Reason: assignment.Message}
+		if !planned {
+			shortfall.Reason = "The area was added after the latest plan."
+		}
+		for resource, units := range area.RequiredResource {
+			if units > 0 {
+				shortfall.Missing[resource] = units
+				shortfall.Units += units
+			}
+		}
+		if shortfall.Units > 0 {
+			report.Shortfalls = append(report.Shortfalls, shortfall)
+		}
+
+		reason := models.RiskUnassigned
+		if now.After(latest) {
+			reason = models.RiskOverdue
+		}
+		report.AtRisk = append(report.AtRisk, models.AtRiskArea{
+			AreaID: area.ID, UrgencyLevel: area.Urgency, Reason: reason, LatestArrival: latest, SlackMinutes: roundMinutes(latest.Sub(now)),
+		})
+	}
+	report.Percent = percent(report.Served, report.Areas)
+	for i := range report.ByUrgency {
+		report.ByUrgency[i].Percent = percent(report.ByUrgency[i].Served, report.ByUrgency[i].Areas)
+	}
+
+	for resource, units := range required {
+		unmet := int(math.Max(0, float64(units-delivered[resource])))
+		report.Resources = append(report.Resources, models.ResourceCoverage{
+			Resource: resource, Required: units, Delivered: delivered[resource], Unmet: unmet, Percent: percent(units-unmet, units),
+		})
+	}
+	sort.Slice(report.Resources, func(i, j int) bool {
+		a, b := report.Resources[i], report.Resources[j]
+		if a.Unmet != b.Unmet {
+			return a.Unmet > b.Unmet
+		}
+		return a.Resource < b.Resource
+	})
+
+	// The most urgent areas missing the most units come first
+	sort.Slice(report.Shortfalls, func(i, j int) bool {
+		a, b := report.Shortfalls[i], report.Shortfalls[j]
+		if a.UrgencyLevel != b.UrgencyLevel {
+			return a.UrgencyLevel > b.UrgencyLevel
+		}
+		if a.Units != b.Units {
+			return a.Units > b.Units
+		}
+		return a.AreaID < b.AreaID
+	})
+	if len(report.Shortfalls) > opts.Top {
+		report.Shortfalls = report.Shortfalls[:opts.Top]
+	}
+
+	sort.Slice(report.AtRisk, func(i, j int) bool {
+		a, b := report.AtRisk[i], report.AtRisk[j]
+		if a.SlackMinutes != b.SlackMinutes {
+			return a.SlackMinutes < b.SlackMinutes
+		}
+		return a.AreaID < b.AreaID
+	})
+
+	// Stock on available trucks the plan leaves at the depot
+	for _, truck := range trucks {
+		if truck.Status != models.TruckStatusAvailable || committedTrucks[truck.ID] {
+			continue
+		}
+		report.IdleSurplus.Trucks = append(report.IdleSurplus.Trucks, truck.ID)
+		for resource, units := range truck.AvailableResources {
+			if units > 0 {
+				report.IdleSurplus.Resources[resource] += units
+			}
+		}
+	}
+	sort.Strings(report.IdleSurplus.Trucks)
+
+	return report
+}
+
+// percent is part of whole in percent with one decimal, 0 when whole is 0
+func percent(part, whole int) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return math.Round(float64(part)/float64(whole)*1000) / 10
+}
+
+func roundMinutes(d time.Duration) float64 {
+	return math.Round(d.Minutes()*10) / 10
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+	"workship-disaster-api/models"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+func coverageFixture() (*models.Plan, []AreaData, []TruckData) {
+	plan := &models.Plan{ID: 3, PlanStart: planStart, Assignments: []models.Assignment{
+		{AreaID: "planned", TruckID: "T1", ResourcesDelivered: map[string]int{"water": 100}, EstimatedArrival: at(105 * time.Minute), Status: models.AssignmentStatusConfirmed},
+		{AreaID: "delivered", TruckID: "T2", ResourcesDelivered: map[string]int{"water": 50}, EstimatedArrival: at(30 * time.Minute), Status: models.AssignmentStatusDelivered},
+		{AreaID: "unassigned", Message: "no truck carries food"},
+	}}
+	areas := []AreaData{
+		{ID: "planned", Urgency: 5, RequiredResource: map[string]int{"water": 100}, LatestArrival: at(2 * time.Hour)},
+		// Delivery already took its units off the requirements
+		{ID: "delivered", Urgency: 4, RequiredResource: map[string]int{"water": 0}, LatestArrival: at(time.Hour)},
+		{ID: "unassigned", Urgency: 3, RequiredResource: map[string]int{"food": 20}, LatestArrival: at(3 * time.Hour)},
+		{ID: "new", Urgency: 5, RequiredResource: map[string]int{"water": 10}, LatestArrival: at(0)},
+	}
+	trucks := []TruckData{
+		{ID: "T1", Status: models.TruckStatusAvailable, AvailableResources: map[string]int{"water": 100}},
+		{ID: "T2", Status: models.TruckStatusAvailable, AvailableResources: map[string]int{"water": 30}},
+		{ID: "T3", Status: "busy", AvailableResources: map[string]int{"water": 500}},
+		{ID: "T4", Status: models.TruckStatusAvailable, AvailableResources: map[string]int{"food": 0}},
+	}
+	return plan, areas, trucks
+}
+
+func TestBuildCoverage(t *testing.T) {
+	plan, areas, trucks := coverageFixture()
+	now := planStart.Add(10 * time.Minute)
+
+	report := BuildCoverage(plan, areas, trucks, now, models.CoverageOptions{})
+
+	if report.Areas != 4 || report.Served != 2 || report.Percent != 50 {
+		t.Errorf("served %d of %d (%v%%), want the planned and delivered areas", report.Served, report.Areas, report.Percent)
+	}
+	wantUrgency := []models.UrgencyCoverage{
+		{UrgencyLevel: 5, Areas: 2, Served: 1, Percent: 50},
+		{UrgencyLevel: 4, Areas: 1, Served: 1, Percent: 100},
+		{UrgencyLevel: 3, Areas: 1, Served: 0, Percent: 0},
+		{UrgencyLevel: 2},
+		{UrgencyLevel: 1},
+	}
+	if !reflect.DeepEqual(report.ByUrgency, wantUrgency) {
+		t.Errorf("by urgency %+v", report.ByUrgency)
+	}
+
+	// Delivered units count on both sides, the area's remaining need is only what is left
+	wantResources := []models.ResourceCoverage{
+		{Resource: "food", Required: 20, Delivered: 0, Unmet: 20, Percent: 0},
+		{Resource: "water", Required: 160, Delivered: 150, Unmet: 10, Percent: 93.8},
+	}
+	if !reflect.DeepEqual(report.Resources, wantResources) {
+		t.Errorf("resources %+v", report.Resources)
+	}
+
+	if len(report.Shortfalls) != 2 || report.Shortfalls[0].AreaID != "new" || report.Shortfalls[1].AreaID != "unassigned" {
+		t.Fatalf("shortfalls %+v, want the urgent new area first", report.Shortfalls)
+	}
+	if got := report.Shortfalls[0].Reason; got != "The area was added after the latest plan." {
+		t.Errorf("new area reason %q", got)
+	}
+	if got := report.Shortfalls[1]; got.Reason != "no truck carries food" || got.Units != 20 {
+		t.Errorf("unassigned shortfall %+v, want the planner's message", got)
+	}
+
+	wantRisk := []struct {
+		area, reason string
+		slack        float64
+	}{
+		{"new", models.RiskOverdue, -10},
+		{"planned", models.RiskTight, 15},
+		{"unassigned", models.RiskUnassigned, 170},
+	}
+	if len(report.AtRisk) != len(wantRisk) {
+		t.Fatalf("at risk %+v", report.AtRisk)
+	}
+	for i, want := range wantRisk {
+		if got := report.AtRisk[i]; got.AreaID != want.area || got.Reason != want.reason || got.SlackMinutes != want.slack {
+			t.Errorf("at risk %d = %+v, want %+v", i, got, want)
+		}
+	}
+
+	// T1 is on the road, T2 finished its delivery, T3 isn't available
+	want := models.IdleSurplus{Trucks: []string{"T2", "T4"}, Resources: map[string]int{"water": 30}}
+	if !reflect.DeepEqual(report.IdleSurplus, want) {
+		t.Errorf("idle surplus %+v, want %+v", report.IdleSurplus, want)
+	}
+}
+
+func TestBuildCoverageRiskMinutes(t *testing.T) {
+	plan, areas, trucks := coverageFixture()
+	now := planStart.Add(10 * time.Minute)
+
+	var opts models.CoverageOptions
+	if err := binding.Query.Bind(httptest.NewRequest("GET", "/?riskMinutes=0&top=1", nil), &opts); err != nil {
+		t.Fatalf("bind: %v", err)
+	}
+	if opts.RiskMinutes == nil || *opts.RiskMinutes != 0 {
+		t.Fatalf("riskMinutes=0 bound as %v, it must not fall back to the default", opts.RiskMinutes)
+	}
+
+	report := BuildCoverage(plan, areas, trucks, now, opts)
+	for _, area := range report.AtRisk {
+		if area.AreaID == "planned" {
+			t.Errorf("planned area arrives before its deadline, with riskMinutes=0 it isn't at risk")
+		}
+	}
+	if len(report.Shortfalls) != 1 {
+		t.Errorf("got %d shortfalls, want top=1 applied", len(report.Shortfalls))
+	}
+}
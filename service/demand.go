@@ -0,0 +1,145 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"workship-disaster-api/apperr"
+	"workship-disaster-api/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DemandRate is how much of a resource one person needs, per day when Daily is set
+// and once for the whole emergency otherwise, e.g. one medical kit per 50 people
+type DemandRate struct {
+	PerPerson float64 `yaml:"perPerson" json:"perPerson"`
+	Daily     bool    `yaml:"daily" json:"daily"`
+}
+
+// DemandConfig holds the per-capita rules each disaster type estimates required resources with
+type DemandConfig struct {
+	// DefaultDays is used when an area gives a disaster type without durationDays
+	DefaultDays int `yaml:"defaultDays" json:"defaultDays"`
+	// Types maps a disaster type to resource type to rate
+	Types map[string]map[string]DemandRate `yaml:"types" json:"types"`
+}
+
+// DefaultDemandConfig follows the Sphere minimums, 15 litres of water and one food pack per person per day
+func DefaultDemandConfig() DemandConfig {
+	base := func(medicine float64) map[string]DemandRate {
+		return map[string]DemandRate{
+			"water":    {PerPerson: 15, Daily: true},
+			"food":     {PerPerson: 1, Daily: true},
+			"medicine": {PerPerson: medicine},
+		}
+	}
+	return DemandConfig{
+		DefaultDays: 3,
+		Types: map[string]map[string]DemandRate{
+			"flood":      base(0.02),
+			"earthquake": base(0.05),
+			"storm":      base(0.03),
+			"wildfire":   base(0.03),
+			"drought":    {"water": {PerPerson: 20, Daily: true}, "food": {PerPerson: 1, Daily: true}},
+		},
+	}
+}
+
+// LoadDemandConfig reads the rules from the YAML or JSON file at DEMAND_RULES_FILE, the defaults
+// apply when it isn't set. Rules for resources outside resourceTypes are rejected when it isn't empty.
+func LoadDemandConfig(resourceTypes []string) (DemandConfig, error) {
+	cfg := DefaultDemandConfig()
+
+	if path := os.Getenv("DEMAND_RULES_FILE"); path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to read DEMAND_RULES_FILE: %w", err)
+		}
+		// The YAML decoder also accepts a JSON rules file
+		cfg = DemandConfig{DefaultDays: cfg.DefaultDays}
+		if err := yaml.Unmarshal(raw, &cfg); err != nil {
+			return cfg, fmt.Errorf("invalid DEMAND_RULES_FILE %s: %w", path, err)
+		}
+	}
+
+	known := make(map[string]bool, len(resourceTypes))
+	for _, name := range resourceTypes {
+		known[name] = true
+	}
+	if cfg.DefaultDays <= 0 {
+		return cfg, fmt.Errorf("invalid demand rules: defaultDays must be positive")
+	}
+	for disaster, rates := range cfg.Types {
+		for resource, rate := range rates {
+			if rate.PerPerson < 0 {
+				return cfg, fmt.Errorf("invalid demand rule %s.%s: perPerson must not be negative", disaster, resource)
+			}
+			if len(known) > 0 && !known[resource] {
+				return cfg, fmt.Errorf("invalid demand rule %s.%s: not one of RESOURCE_TYPES", disaster, resource)
+			}
+		}
+	}
+
+	return cfg, nil
+}
+
+// Estimate returns the units of each resource population people need over days
+func (c DemandConfig) Estimate(disasterType string, population, days int) (map[string]int, bool) {
+	rates, ok := c.Types[disasterType]
+	if !ok {
+		return nil, false
+	}
+	demand := make(map[string]int, len(rates))
+	for resource, rate := range rates {
+		units := float64(population) * rate.PerPerson
+		if rate.Daily {
+			units *= float64(days)
+		}
+		demand[resource] = int(math.Ceil(units))
+	}
+	return demand, true
+}
+
+// Apply fills in the required resources of an area that gives a disaster type. Quantities the
+// request sets itself are kept as manual overrides, the rest are recorded in EstimatedResources.
+func (c DemandConfig) Apply(req *models.CreateAreaRequest) error {
+	req.EstimatedResources = nil
+	if req.DisasterType == "" {
+		return nil
+	}
+	if req.DurationDays == 0 {
+		req.DurationDays = c.DefaultDays
+	}
+
+	demand, ok := c.Estimate(req.DisasterType, req.Population, req.DurationDays)
+	if !ok {
+		types := make([]string, 0, len(c.Types))
+		for name := range c.Types {
+			types = append(types, name)
+		}
+		sort.Strings(types)
+		return &apperr.Error{
+			Status: http.StatusBadRequest,
+			Code:   apperr.CodeValidationFailed,
+			Detail: "The request has invalid fields",
+			Fields: []apperr.FieldError{{Field: "disasterType", Rule: "oneof", Param: strings.Join(types, " "), Message: "must be one of " + strings.Join(types, ", ")}},
+		}
+	}
+
+	if req.RequiredResources == nil {
+		req.RequiredResources = map[string]int{}
+	}
+	for resource, units := range demand {
+		if _, manual := req.RequiredResources[resource]; manual {
+			continue
+		}
+		req.RequiredResources[resource] = units
+		req.EstimatedResources = append(req.EstimatedResources, resource)
+	}
+	sort.Strings(req.EstimatedResources)
+	return nil
+}
@@ -0,0 +1,19 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"workship-disaster-api/events"
+	"workship-disaster-api/logging"
+)
+
+// broadcast fans out events staged in a committed transaction. Webhooks are already queued
+// by then, so a failure only costs live subscribers the events and is logged.
+func broadcast(ctx context.Context, publisher *events.Publisher, staged ...events.Event) {
+	if len(staged) == 0 {
+		return
+	}
+	if err := publisher.Broadcast(ctx, staged...); err != nil {
+		logging.FromContext(ctx).Error("failed to broadcast events", slog.Any("error", err))
+	}
+}
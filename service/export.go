@@ -0,0 +1,474 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"workship-disaster-api/models"
+
+	// Printed sheets use local time even where the host has no zoneinfo
+	_ "time/tzdata"
+)
+
+// Plan export formats, excel is CSV with a byte order mark and CRLF line endings
+// so Excel and LibreOffice open Thai text correctly
+const (
+	ExportFormatCSV     = "csv"
+	ExportFormatExcel   = "excel"
+	ExportFormatGeoJSON = "geojson"
+	ExportFormatHTML    = "html"
+)
+
+// DefaultExportTimezone is used for printed and spreadsheet times
+const DefaultExportTimezone = "Asia/Bangkok"
+
+// PlanExportRow is an assignment joined with its area and truck, either may be nil
+// when the area or truck no longer exists or the area is unassigned. Plans only store
+// the assignments, so Area and Truck hold current values, not those the plan was made with.
+type PlanExportRow struct {
+	Assignment models.Assignment
+	Area       *AreaData
+	Truck      *TruckData
+}
+
+// ExportService renders stored plans for printing and GIS tools
+type ExportService struct {
+	areaService  *AreaService
+	truckService *TruckService
+}
+
+func NewExportService(areaService *AreaService, truckService *TruckService) *ExportService {
+	return &ExportService{areaService: areaService, truckService: truckService}
+}
+
+// Rows joins the plan's assignments with the area and truck details as they are now
+func (s *ExportService) Rows(ctx context.Context, plan *models.Plan) ([]PlanExportRow, error) {
+	areas, err := s.areaService.GetAllAreas(ctx)
+	if err != nil {
+		return nil, err
+	}
+	trucks, err := s.truckService.GetAllTrucks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	areaByID := make(map[string]*AreaData, len(areas))
+	for i := range areas {
+		areaByID[areas[i].ID] = &areas[i]
+	}
+	truckByID := make(map[string]*TruckData, len(trucks))
+	for i := range trucks {
+		truckByID[trucks[i].ID] = &trucks[i]
+	}
+
+	rows := make([]PlanExportRow, len(plan.Assignments))
+	for i, assignment := range plan.Assignments {
+		rows[i] = PlanExportRow{Assignment: assignment, Area: areaByID[assignment.AreaID]}
+		if assignment.TruckID != "" {
+			rows[i].Truck = truckByID[assignment.TruckID]
+		}
+	}
+	return rows, nil
+}
+
+// formatResources renders a resource map as "food=20; water=100" in a stable order
+func formatResources(resources map[string]int) string {
+	names := make([]string, 0, len(resources))
+	for name := range resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + strconv.Itoa(resources[name])
+	}
+	return strings.Join(parts, "; ")
+}
+
+func formatCoordinate(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
+
+// planColumn is one CSV column of a plan export
+type planColumn struct {
+	Name  string
+	Value func(row PlanExportRow) string
+}
+
+// WritePlanCSV writes one row per assignment. The excel variant uses local times that
+// spreadsheets recognise, plain CSV uses RFC 3339. Columns read from the area or truck
+// are prefixed current_ since they may have changed after the plan was made. Excel cells that
+// would start a formula are escaped, IDs and messages come from API clients.
+func WritePlanCSV(w io.Writer, plan *models.Plan, rows []PlanExportRow, loc *time.Location, excel bool) error {
+	formatTime := func(t *time.Time) string {
+		switch {
+		case t == nil:
+			return ""
+		case excel:
+			return t.In(loc).Format("2006-01-02 15:04")
+		}
+		return t.Format(time.RFC3339)
+	}
+	area := func(value func(*AreaData) string) func(PlanExportRow) string {
+		return func(row PlanExportRow) string {
+			if row.Area == nil {
+				return ""
+			}
+			return value(row.Area)
+		}
+	}
+	truck := func(value func(*TruckData) string) func(PlanExportRow) string {
+		return func(row PlanExportRow) string {
+			if row.Truck == nil {
+				return ""
+			}
+			return value(row.Truck)
+		}
+	}
+	planID := strconv.FormatInt(plan.ID, 10)
+
+	columns := []planColumn{
+		{"plan_id", func(PlanExportRow) string { return planID }},
+		{"area_id", func(row PlanExportRow) string { return row.Assignment.AreaID }},
+		{"truck_id", func(row PlanExportRow) string { return row.Assignment.TruckID }},
+		{"status", func(row PlanExportRow) string { return row.Assignment.Status }},
+		{"departure_time", func(row PlanExportRow) string { return formatTime(row.Assignment.DepartureTime) }},
+		{"estimated_arrival", func(row PlanExportRow) string { return formatTime(row.Assignment.EstimatedArrival) }},
+		{"resources_delivered", func(row PlanExportRow) string { return formatResources(row.Assignment.ResourcesDelivered) }},
+		{"current_urgency_level", area(func(a *AreaData) string { return strconv.Itoa(a.Urgency) })},
+		{"current_required_resources", area(func(a *AreaData) string { return formatResources(a.RequiredResource) })},
+		{"current_earliest_arrival", area(func(a *AreaData) string {
+			earliest, _ := a.ArrivalWindow(plan.PlanStart)
+			return formatTime(&earliest)
+		})},
+		{"current_latest_arrival", area(func(a *AreaData) string {
+			_, latest := a.ArrivalWindow(plan.PlanStart)
+			return formatTime(&latest)
+		})},
+		{"current_area_latitude", area(func(a *AreaData) string { return formatCoordinate(a.Latitude) })},
+		{"current_area_longitude", area(func(a *AreaData) string { return formatCoordinate(a.Longitude) })},
+		{"current_truck_status", truck(func(t *TruckData) string { return t.Status })},
+		{"current_truck_shift", truck(func(t *TruckData) string {
+			if t.Shift == nil {
+				return ""
+			}
+			return t.Shift.String()
+		})},
+		{"current_truck_latitude", truck(func(t *TruckData) string { return formatCoordinate(t.Latitude) })},
+		{"current_truck_longitude", truck(func(t *TruckData) string { return formatCoordinate(t.Longitude) })},
+		{"priority", func(row PlanExportRow) string {
+			if row.Assignment.Priority == nil {
+				return ""
+			}
+			return strconv.FormatFloat(row.Assignment.Priority.Total, 'f', 2, 64)
+		}},
+		{"message", func(row PlanExportRow) string { return row.Assignment.Message }},
+	}
+
+	if excel {
+		if _, err := io.WriteString(w, "\ufeff"); err != nil {
+			return err
+		}
+	}
+
+	writer := csv.NewWriter(w)
+	writer.UseCRLF = excel
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.Name
+	}
+	writer.Write(header)
+
+	for _, row := range rows {
+		record := make([]string, len(columns))
+		for i, column := range columns {
+			record[i] = column.Value(row)
+			if excel {
+				record[i] = escapeFormula(record[i])
+			}
+		}
+		writer.Write(record)
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// escapeFormula prefixes a cell starting with =, +, - or @ with ' so spreadsheets show it as text
+// instead of evaluating it, numbers such as negative coordinates are left as they are
+func escapeFormula(value string) string {
+	if value == "" || !strings.ContainsRune("=+-@", rune(value[0])) {
+		return value
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return value
+	}
+	return "'" + value
+}
+
+type geoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   *geoJSONGeometry       `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// WritePlanGeoJSON writes a FeatureCollection with a LineString from each truck to its area.
+// Without a truck position the area is drawn as a Point, and assignments without any coordinates
+// keep a null geometry so GIS attribute tables stay complete. Positions and the current_ properties
+// are today's values like in the CSV.
+func WritePlanGeoJSON(w io.Writer, plan *models.Plan, rows []PlanExportRow) error {
+	features := make([]geoJSONFeature, 0, len(rows))
+	for _, row := range rows {
+		a := row.Assignment
+		properties := map[string]interface{}{
+			"plan_id":             plan.ID,
+			"area_id":             a.AreaID,
+			"truck_id":            a.TruckID,
+			"status":              a.Status,
+			"departure_time":      a.DepartureTime,
+			"estimated_arrival":   a.EstimatedArrival,
+			"resources_delivered": a.ResourcesDelivered,
+			"message":             a.Message,
+		}
+		if a.Priority != nil {
+			properties["priority"] = a.Priority.Total
+		}
+		if row.Area != nil {
+			properties["current_urgency_level"] = row.Area.Urgency
+			properties["current_required_resources"] = row.Area.RequiredResource
+		}
+
+		feature := geoJSONFeature{Type: "Feature", Properties: properties}
+		var areaPoint, truckPoint []float64
+		if row.Area != nil {
+			areaPoint = geoJSONPoint(row.Area.Latitude, row.Area.Longitude)
+		}
+		if row.Truck != nil {
+			truckPoint = geoJSONPoint(row.Truck.Latitude, row.Truck.Longitude)
+		}
+		switch {
+		case areaPoint != nil && truckPoint != nil:
+			feature.Geometry = &geoJSONGeometry{Type: "LineString", Coordinates: [][]float64{truckPoint, areaPoint}}
+		case areaPoint != nil:
+			// Unassigned areas, or trucks without a known position, still show where help is needed
+			feature.Geometry = &geoJSONGeometry{Type: "Point", Coordinates: areaPoint}
+		}
+		features = append(features, feature)
+	}
+
+	return json.NewEncoder(w).Encode(map[string]interface{}{
+		"type":     "FeatureCollection",
+		"features": features,
+	})
+}
+
+// geoJSONPoint returns [longitude, latitude] as GeoJSON orders them, nil if either is unknown
+func geoJSONPoint(latitude, longitude *float64) []float64 {
+	if latitude == nil || longitude == nil {
+		return nil
+	}
+	return []float64{*longitude, *latitude}
+}
+
+// dispatchStop is one delivery on a printed dispatch sheet
+type dispatchStop struct {
+	Assignment models.Assignment
+	Area       *AreaData
+	Resources  string
+	Departure  string
+	Arrival    string
+	Window     string
+}
+
+// dispatchSheet lists one truck's deliveries in departure order
+type dispatchSheet struct {
+	TruckID string
+	Truck   *TruckData
+	Shift   string
+	Stops   []dispatchStop
+}
+
+// WritePlanHTML writes a printable dispatch sheet per truck, each on its own page, followed by unassigned areas
+func WritePlanHTML(w io.Writer, plan *models.Plan, rows []PlanExportRow, loc *time.Location) error {
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return "-"
+		}
+		return t.In(loc).Format("02/01/2006 15:04")
+	}
+
+	sheets := map[string]*dispatchSheet{}
+	var truckIDs []string
+	var unassigned []dispatchStop
+	for _, row := range rows {
+		a := row.Assignment
+		stop := dispatchStop{
+			Assignment: a,
+			Area:       row.Area,
+			Resources:  formatResources(a.ResourcesDelivered),
+			Departure:  formatTime(a.DepartureTime),
+			Arrival:    formatTime(a.EstimatedArrival),
+		}
+		if row.Area != nil {
+			earliest, latest := row.Area.ArrivalWindow(plan.PlanStart)
+			stop.Window = formatTime(&earliest) + " - " + formatTime(&latest)
+		}
+
+		if a.TruckID == "" {
+			if row.Area != nil {
+				stop.Resources = formatResources(row.Area.RequiredResource)
+			}
+			unassigned = append(unassigned, stop)
+			continue
+		}
+
+		sheet, ok := sheets[a.TruckID]
+		if !ok {
+			sheet = &dispatchSheet{TruckID: a.TruckID, Truck: row.Truck}
+			if row.Truck != nil && row.Truck.Shift != nil {
+				sheet.Shift = row.Truck.Shift.String()
+			}
+			sheets[a.TruckID] = sheet
+			truckIDs = append(truckIDs, a.TruckID)
+		}
+		sheet.Stops = append(sheet.Stops, stop)
+	}
+
+	sort.Strings(truckIDs)
+	ordered := make([]*dispatchSheet, len(truckIDs))
+	for i, truckID := range truckIDs {
+		sheet := sheets[truckID]
+		sort.SliceStable(sheet.Stops, func(i, j int) bool {
+			a, b := sheet.Stops[i].Assignment.DepartureTime, sheet.Stops[j].Assignment.DepartureTime
+			return a != nil && (b == nil || a.Before(*b))
+		})
+		ordered[i] = sheet
+	}
+
+	return dispatchTemplate.Execute(w, map[string]interface{}{
+		"Plan":       plan,
+		"PlanStart":  formatTime(&plan.PlanStart),
+		"CreatedAt":  formatTime(&plan.CreatedAt),
+		"Timezone":   loc.String(),
+		"Sheets":     ordered,
+		"Unassigned": unassigned,
+	})
+}
+
+var dispatchTemplate = template.Must(template.New("dispatch").Funcs(template.FuncMap{
+	"inc":   func(i int) int { return i + 1 },
+	"coord": formatCoordinate,
+}).Parse(dispatchTemplateSource))
+
+const dispatchTemplateSource = `<!DOCTYPE html>
+<html lang="th">
+<head>
+<meta charset="utf-8">
+<title>ใบสั่งงานแผน {{.Plan.ID}} / Dispatch plan {{.Plan.ID}}</title>
+<style>
+body { font-family: "Sarabun", "Noto Sans Thai", Arial, sans-serif; font-size: 12pt; margin: 1.5cm; }
+h1 { font-size: 16pt; margin: 0 0 4pt; }
+.meta { color: #444; margin-bottom: 10pt; }
+table { width: 100%; border-collapse: collapse; margin-top: 6pt; }
+th, td { border: 1px solid #999; padding: 4pt 6pt; text-align: left; vertical-align: top; }
+th { background: #eee; }
+.sheet { page-break-after: always; }
+.sheet:last-child { page-break-after: auto; }
+.signature { margin-top: 24pt; }
+@media print { body { margin: 0; } }
+</style>
+</head>
+<body>
+{{- range .Sheets}}
+<section class="sheet">
+<h1>ใบสั่งงานรถ / Truck dispatch sheet: {{.TruckID}}</h1>
+<div class="meta">
+แผน / Plan #{{$.Plan.ID}} &middot; เริ่มแผน / Plan start: {{$.PlanStart}} &middot; สร้างเมื่อ / Created: {{$.CreatedAt}} ({{$.Timezone}})<br>
+{{- if .Truck}}
+สถานะรถปัจจุบัน / Current truck status: {{.Truck.Status}}{{if .Shift}} &middot; กะ / Shift: {{.Shift}}{{end}}
+{{- end}}
+</div>
+<table>
+<thead>
+<tr>
+<th>#</th>
+<th>พื้นที่ / Area</th>
+<th>ออกเดินทาง / Departure</th>
+<th>ถึงโดยประมาณ / ETA</th>
+<th>ช่วงเวลารับของ* / Delivery window*</th>
+<th>สิ่งของ / Resources</th>
+<th>ความเร่งด่วน* / Urgency*</th>
+<th>สถานะ / Status</th>
+<th>ลงชื่อผู้รับ / Received by</th>
+</tr>
+</thead>
+<tbody>
+{{- range $i, $stop := .Stops}}
+<tr>
+<td>{{$i | inc}}</td>
+<td>{{$stop.Assignment.AreaID}}{{if $stop.Area}}{{if $stop.Area.Latitude}}<br><small>{{$stop.Area.Latitude | coord}}, {{$stop.Area.Longitude | coord}}</small>{{end}}{{end}}</td>
+<td>{{$stop.Departure}}</td>
+<td>{{$stop.Arrival}}</td>
+<td>{{$stop.Window}}</td>
+<td>{{$stop.Resources}}</td>
+<td>{{if $stop.Area}}{{$stop.Area.Urgency}}{{end}}</td>
+<td>{{$stop.Assignment.Status}}</td>
+<td></td>
+</tr>
+{{- end}}
+</tbody>
+</table>
+<p class="meta"><small>* ค่าปัจจุบันของพื้นที่ อาจต่างจากตอนสร้างแผน / Current area values, they may have changed since the plan was made</small></p>
+<p class="signature">ลงชื่อคนขับ / Driver signature: ______________________ &nbsp; วันที่ / Date: ____________</p>
+</section>
+{{- end}}
+{{- if .Unassigned}}
+<section class="sheet">
+<h1>พื้นที่ที่ยังไม่ได้รับการจัดสรร / Unassigned areas</h1>
+<div class="meta">แผน / Plan #{{.Plan.ID}} &middot; เริ่มแผน / Plan start: {{.PlanStart}} ({{.Timezone}})</div>
+<table>
+<thead>
+<tr>
+<th>พื้นที่ / Area</th>
+<th>ความเร่งด่วน* / Urgency*</th>
+<th>สิ่งของที่ต้องการ* / Required resources*</th>
+<th>ช่วงเวลารับของ* / Delivery window*</th>
+<th>เหตุผล / Reason</th>
+</tr>
+</thead>
+<tbody>
+{{- range .Unassigned}}
+<tr>
+<td>{{.Assignment.AreaID}}</td>
+<td>{{if .Area}}{{.Area.Urgency}}{{end}}</td>
+<td>{{.Resources}}</td>
+<td>{{.Window}}</td>
+<td>{{.Assignment.Message}}</td>
+</tr>
+{{- end}}
+</tbody>
+</table>
+<p class="meta"><small>* ค่าปัจจุบันของพื้นที่ อาจต่างจากตอนสร้างแผน / Current area values, they may have changed since the plan was made</small></p>
+</section>
+{{- end}}
+{{- if and (not .Sheets) (not .Unassigned)}}
+<p>แผนนี้ไม่มีงาน / This plan has no assignments.</p>
+{{- end}}
+</body>
+</html>
+`
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+	"workship-disaster-api/models"
+)
+
+func exportFixture(t *testing.T) (*models.Plan, []PlanExportRow) {
+	t.Helper()
+	lat, lon := 13.75, 100.5
+	truckLat, truckLon := 13.7, 100.4
+	shift, err := ParseShiftWindow("08:00", "20:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	departure := planStart.Add(30 * time.Minute)
+	arrival := planStart.Add(90 * time.Minute)
+
+	plan := &models.Plan{ID: 7, PlanStart: planStart, CreatedAt: planStart}
+	rows := []PlanExportRow{
+		{
+			Assignment: models.Assignment{AreaID: "A1", TruckID: "T1", ResourcesDelivered: map[string]int{"water": 100, "food": 20}, DepartureTime: &departure, EstimatedArrival: &arrival, Status: "confirmed", Priority: &models.PriorityScore{Total: 0.755}},
+			Area:       &AreaData{ID: "A1", Urgency: 5, RequiredResource: map[string]int{"water": 100}, TimeConstraint: 120, Latitude: &lat, Longitude: &lon},
+			Truck:      &TruckData{ID: "T1", Status: "busy", Shift: shift, Latitude: &truckLat, Longitude: &truckLon},
+		},
+		// The area was deleted after the plan was made
+		{Assignment: models.Assignment{AreaID: "A2", Message: "no truck can reach the area in time"}},
+	}
+	return plan, rows
+}
+
+func readPlanCSV(t *testing.T, body []byte) []map[string]string {
+	t.Helper()
+	records, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	var out []map[string]string
+	for _, record := range records[1:] {
+		if len(record) != len(records[0]) {
+			t.Fatalf("row has %d cells, header has %d", len(record), len(records[0]))
+		}
+		row := map[string]string{}
+		for i, name := range records[0] {
+			row[name] = record[i]
+		}
+		out = append(out, row)
+	}
+	return out
+}
+
+func TestWritePlanCSV(t *testing.T) {
+	plan, rows := exportFixture(t)
+	var body bytes.Buffer
+	if err := WritePlanCSV(&body, plan, rows, time.UTC, false); err != nil {
+		t.Fatalf("WritePlanCSV: %v", err)
+	}
+
+	records := readPlanCSV(t, body.Bytes())
+	if len(records) != 2 {
+		t.Fatalf("got %d rows, want one per assignment", len(records))
+	}
+	want := map[string]string{
+		"plan_id":                    "7",
+		"truck_id":                   "T1",
+		"departure_time":             "2025-01-01T12:30:00Z",
+		"resources_delivered":        "food=20; water=100",
+		"current_urgency_level":      "5",
+		"current_required_resources": "water=100",
+		"current_latest_arrival":     "2025-01-01T14:00:00Z",
+		"current_area_latitude":      "13.75",
+		"current_truck_status":       "busy",
+		"current_truck_shift":        rows[0].Truck.Shift.String(),
+		"current_truck_longitude":    "100.4",
+		"priority":                   "0.76",
+	}
+	for column, value := range want {
+		if got := records[0][column]; got != value {
+			t.Errorf("%s = %q, want %q", column, got, value)
+		}
+	}
+	if got := records[1]; got["area_id"] != "A2" || got["current_urgency_level"] != "" || got["current_truck_status"] != "" || got["message"] == "" {
+		t.Errorf("row without area or truck = %v", got)
+	}
+}
+
+func TestWritePlanCSVExcel(t *testing.T) {
+	plan, rows := exportFixture(t)
+	bangkok, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body bytes.Buffer
+	if err := WritePlanCSV(&body, plan, rows, bangkok, true); err != nil {
+		t.Fatalf("WritePlanCSV: %v", err)
+	}
+
+	raw := body.String()
+	if !strings.HasPrefix(raw, "\ufeff") || !strings.Contains(raw, "\r\n") {
+		t.Fatal("excel export needs a byte order mark and CRLF line endings")
+	}
+	records := readPlanCSV(t, []byte(strings.TrimPrefix(raw, "\ufeff")))
+	if got := records[0]["departure_time"]; got != "2025-01-01 19:30" {
+		t.Errorf("departure_time = %q, want local time", got)
+	}
+}
+
+// TestWritePlanCSVEscapesFormulas checks client supplied text can't run as a spreadsheet formula
+// in the excel export while negative numbers stay numbers
+func TestWritePlanCSVEscapesFormulas(t *testing.T) {
+	plan, rows := exportFixture(t)
+	lat := -13.5
+	rows[0].Assignment.AreaID = "=HYPERLINK(\"http://example.com\")"
+	rows[0].Area.Latitude = &lat
+	rows[1].Assignment.Message = "@SUM(1+1)"
+
+	for _, excel := range []bool{false, true} {
+		var body bytes.Buffer
+		if err := WritePlanCSV(&body, plan, rows, time.UTC, excel); err != nil {
+			t.Fatalf("WritePlanCSV: %v", err)
+		}
+		records := readPlanCSV(t, []byte(strings.TrimPrefix(body.String(), "\ufeff")))
+
+		prefix := ""
+		if excel {
+			prefix = "'"
+		}
+		if got, want := records[0]["area_id"], prefix+rows[0].Assignment.AreaID; got != want {
+			t.Errorf("excel=%v area_id = %q, want %q", excel, got, want)
+		}
+		if got, want := records[1]["message"], prefix+"@SUM(1+1)"; got != want {
+			t.Errorf("excel=%v message = %q, want %q", excel, got, want)
+		}
+		if got := records[0]["current_area_latitude"]; got != "-13.5" {
+			t.Errorf("excel=%v current_area_latitude = %q, want -13.5", excel, got)
+		}
+	}
+}
+
+func TestWritePlanGeoJSON(t *testing.T) {
+	plan, rows := exportFixture(t)
+	withoutTruckPosition := rows[0]
+	withoutTruckPosition.Truck = &TruckData{ID: "T1"}
+	rows = append(rows, withoutTruckPosition)
+
+	var body bytes.Buffer
+	if err := WritePlanGeoJSON(&body, plan, rows); err != nil {
+		t.Fatalf("WritePlanGeoJSON: %v", err)
+	}
+	var collection struct {
+		Type     string
+		Features []struct {
+			Geometry *struct {
+				Type        string
+				Coordinates json.RawMessage
+			}
+			Properties map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal(body.Bytes(), &collection); err != nil {
+		t.Fatal(err)
+	}
+	if collection.Type != "FeatureCollection" || len(collection.Features) != 3 {
+		t.Fatalf("got %s with %d features", collection.Type, len(collection.Features))
+	}
+
+	line := collection.Features[0]
+	if line.Geometry == nil || line.Geometry.Type != "LineString" || string(line.Geometry.Coordinates) != "[[100.4,13.7],[100.5,13.75]]" {
+		t.Errorf("truck to area geometry = %+v, want a [lon, lat] line from the truck", line.Geometry)
+	}
+	if line.Properties["current_urgency_level"] != float64(5) {
+		t.Errorf("properties = %v", line.Properties)
+	}
+	if collection.Features[1].Geometry != nil {
+		t.Error("an assignment without coordinates must keep a null geometry")
+	}
+	if point := collection.Features[2].Geometry; point == nil || point.Type != "Point" {
+		t.Errorf("area without a truck position = %+v, want a Point", point)
+	}
+}
+
+func TestWritePlanHTML(t *testing.T) {
+	plan, rows := exportFixture(t)
+	rows = append(rows, PlanExportRow{Assignment: models.Assignment{AreaID: "A3", TruckID: "T2", Status: "<script>"}})
+
+	var body bytes.Buffer
+	if err := WritePlanHTML(&body, plan, rows, time.UTC); err != nil {
+		t.Fatalf("WritePlanHTML: %v", err)
+	}
+	html := body.String()
+	for _, want := range []string{
+		"Truck dispatch sheet: T1", "Truck dispatch sheet: T2", "Unassigned areas",
+		"food=20; water=100", "01/01/2025 12:30", "Current area values",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("sheet is missing %q", want)
+		}
+	}
+	if strings.Contains(html, "<script>") {
+		t.Error("assignment fields must be escaped")
+	}
+	if strings.Index(html, "sheet: T1") > strings.Index(html, "sheet: T2") {
+		t.Error("sheets must be ordered by truck ID")
+	}
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+	"workship-disaster-api/db"
+	"workship-disaster-api/logging"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Health statuses, a degraded service still takes traffic
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded"
+	HealthDown     = "down"
+)
+
+// HealthConfig controls the readiness checks
+type HealthConfig struct {
+	// Timeout bounds each dependency check
+	Timeout time.Duration
+	// PlannerStaleAfter is how old the planner heartbeat may get before the worker counts as stuck
+	PlannerStaleAfter time.Duration
+}
+
+// LoadHealthConfig reads READINESS_TIMEOUT and PLANNER_STALE_AFTER
+func LoadHealthConfig() (HealthConfig, error) {
+	cfg := HealthConfig{
+		Timeout:           2 * time.Second,
+		PlannerStaleAfter: 2 * time.Minute,
+	}
+
+	if raw := os.Getenv("READINESS_TIMEOUT"); raw != "" {
+		value, err := time.ParseDuration(raw)
+		if err != nil || value <= 0 {
+			return cfg, fmt.Errorf("invalid READINESS_TIMEOUT: %q", raw)
+		}
+		cfg.Timeout = value
+	}
+
+	if raw := os.Getenv("PLANNER_STALE_AFTER"); raw != "" {
+		value, err := time.ParseDuration(raw)
+		if err != nil || value <= PlannerHeartbeatInterval {
+			return cfg, fmt.Errorf("invalid PLANNER_STALE_AFTER: %q, must be longer than %s", raw, PlannerHeartbeatInterval)
+		}
+		cfg.PlannerStaleAfter = value
+	}
+
+	return cfg, nil
+}
+
+// ComponentHealth is the result of one readiness check
+type ComponentHealth struct {
+	Status string `json:"status"`
+	// Critical components take the service out of rotation when down
+	Critical  bool     `json:"critical"`
+	LatencyMs float64  `json:"latencyMs"`
+	Error     string   `json:"error,omitempty"`
+	Pending   []string `json:"pending,omitempty"`
+	// LastHeartbeat and HeartbeatAgeSeconds describe the planner worker
+	LastHeartbeat       *time.Time `json:"lastHeartbeat,omitempty"`
+	HeartbeatAgeSeconds *float64   `json:"heartbeatAgeSeconds,omitempty"`
+
+	// cause is logged, probes are unauthenticated so it isn't sent
+	cause error
+}
+
+// HealthReport is the readiness of the service and each of its dependencies
+type HealthReport struct {
+	Status     string                     `json:"status"`
+	CheckedAt  time.Time                  `json:"checkedAt"`
+	Components map[string]ComponentHealth `json:"components"`
+}
+
+// HealthService checks the dependencies the API needs to serve requests
+type HealthService struct {
+	db      *sql.DB
+	rdb     *redis.Client
+	planner *PlannerService
+	config  HealthConfig
+}
+
+func NewHealthService(db *sql.DB, rdb *redis.Client, planner *PlannerService, config HealthConfig) *HealthService {
+	return &HealthService{db: db, rdb: rdb, planner: planner, config: config}
+}
+
+// Check runs every readiness check concurrently, each bounded by the configured timeout.
+// Postgres, Redis and migrations are critical, a stuck planner worker only degrades the service.
+func (s *HealthService) Check(ctx context.Context) HealthReport {
+	checks := map[string]struct {
+		critical bool
+		run      func(context.Context) ComponentHealth
+	}{
+		"postgres":   {true, s.checkPostgres},
+		"redis":      {true, s.checkRedis},
+		"migrations": {true, s.checkMigrations},
+		"planner":    {false, s.checkPlanner},
+	}
+
+	report := HealthReport{Status: HealthOK, CheckedAt: time.Now().UTC(), Components: map[string]ComponentHealth{}}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, critical bool, run func(context.Context) ComponentHealth) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, s.config.Timeout)
+			defer cancel()
+
+			start := time.Now()
+			component := run(checkCtx)
+			component.Critical = critical
+			component.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Components[name] = component
+		}(name, check.critical, check.run)
+	}
+	wg.Wait()
+
+	for name, component := range report.Components {
+		if component.Status == HealthOK {
+			continue
+		}
+		cause := component.cause
+		if cause == nil {
+			cause = errors.New(component.Error)
+		}
+		logging.FromContext(ctx).Warn("readiness check failed", slog.String("component", name), slog.Any("error", cause))
+		if component.Critical {
+			report.Status = HealthDown
+		} else if report.Status == HealthOK {
+			report.Status = HealthDegraded
+		}
+	}
+	return report
+}
+
+func (s *HealthService) checkPostgres(ctx context.Context) ComponentHealth {
+	if err := s.db.PingContext(ctx); err != nil {
+		return downComponent(ctx, err)
+	}
+	return ComponentHealth{Status: HealthOK}
+}
+
+func (s *HealthService) checkRedis(ctx context.Context) ComponentHealth {
+	if err := s.rdb.Ping(ctx).Err(); err != nil {
+		return downComponent(ctx, err)
+	}
+	return ComponentHealth{Status: HealthOK}
+}
+
+// checkMigrations fails while migration files are waiting to be applied, e.g. during a rollout
+func (s *HealthService) checkMigrations(ctx context.Context) ComponentHealth {
+	pending, err := db.PendingMigrations(ctx, s.db)
+	if err != nil {
+		return downComponent(ctx, err)
+	}
+	if len(pending) > 0 {
+		return ComponentHealth{Status: HealthDown, Error: fmt.Sprintf("%d migrations not applied", len(pending)), Pending: pending}
+	}
+	return ComponentHealth{Status: HealthOK}
+}
+
+// checkPlanner fails when the worker never started or stopped coming round its loop
+func (s *HealthService) checkPlanner(ctx context.Context) ComponentHealth {
+	beat := s.planner.Heartbeat()
+	if beat.IsZero() {
+		return ComponentHealth{Status: HealthDown, Error: "planner worker is not running"}
+	}
+
+	age := time.Since(beat)
+	ageSeconds := age.Seconds()
+	component := ComponentHealth{Status: HealthOK, LastHeartbeat: &beat, HeartbeatAgeSeconds: &ageSeconds}
+	if age > s.config.PlannerStaleAfter {
+		component.Status = HealthDown
+		component.Error = fmt.Sprintf("no heartbeat for %s", age.Round(time.Second))
+	}
+	return component
+}
+
+// downComponent reports a failed check without leaking connection details
+func downComponent(ctx context.Context, err error) ComponentHealth {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return ComponentHealth{Status: HealthDown, Error: "timed out", cause: err}
+	}
+	return ComponentHealth{Status: HealthDown, Error: "unavailable", cause: err}
+}
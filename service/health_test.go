@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// TestHealthCheck checks which failures take the service down and which only degrade it
+func TestHealthCheck(t *testing.T) {
+	// The migrations check reads db/migrations relative to the working directory, like the server
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(".."); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	entries, err := os.ReadDir("db/migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var migrations []string
+	for _, entry := range entries {
+		migrations = append(migrations, entry.Name())
+	}
+
+	tests := []struct {
+		name      string
+		applied   []string
+		redisDown bool
+		heartbeat time.Duration
+		want      string
+		failed    string
+	}{
+		{"healthy", migrations, false, time.Second, HealthOK, ""},
+		{"pending migration", migrations[:len(migrations)-1], false, time.Second, HealthDown, "migrations"},
+		{"redis down", migrations, true, time.Second, HealthDown, "redis"},
+		{"stale planner", migrations, false, time.Hour, HealthDegraded, "planner"},
+		{"planner not running", migrations, false, 0, HealthDegraded, "planner"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			// The checks run concurrently
+			mock.MatchExpectationsInOrder(false)
+			mock.ExpectPing()
+			rows := sqlmock.NewRows([]string{"name"})
+			for _, name := range tt.applied {
+				rows.AddRow(name)
+			}
+			mock.ExpectQuery("SELECT name FROM migrations").WillReturnRows(rows)
+
+			server := miniredis.RunT(t)
+			rdb := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+			defer rdb.Close()
+			if tt.redisDown {
+				server.Close()
+			}
+
+			planner := &PlannerService{}
+			if tt.heartbeat > 0 {
+				planner.heartbeat.Store(time.Now().Add(-tt.heartbeat).UnixNano())
+			}
+
+			service := NewHealthService(db, rdb, planner, HealthConfig{Timeout: time.Second, PlannerStaleAfter: time.Minute})
+			report := service.Check(context.Background())
+			if report.Status != tt.want {
+				t.Errorf("status = %s, want %s: %+v", report.Status, tt.want, report.Components)
+			}
+			for name, component := range report.Components {
+				if failed := component.Status != HealthOK; failed != (name == tt.failed) {
+					t.Errorf("%s = %+v", name, component)
+				}
+			}
+			if tt.failed == "redis" && report.Components["redis"].Error != "unavailable" {
+				t.Errorf("redis error = %q, connection details must not be sent", report.Components["redis"].Error)
+			}
+			if tt.failed == "migrations" && len(report.Components["migrations"].Pending) != 1 {
+				t.Errorf("pending = %v, want the last migration", report.Components["migrations"].Pending)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
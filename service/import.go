@@ -0,0 +1,699 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"workship-disaster-api/apperr"
+	"workship-disaster-api/events"
+	"workship-disaster-api/models"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+// ErrInvalidImport is returned when an import file can't be read at all,
+// problems with single rows are reported in the import result instead
+var ErrInvalidImport = errors.New("invalid import file")
+
+// DetectImportFormat infers the import format from a content type or file name, "" if unknown
+func DetectImportFormat(contentType, filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return models.ImportFormatCSV
+	case ".geojson":
+		return models.ImportFormatGeoJSON
+	case ".json":
+		return models.ImportFormatJSON
+	}
+
+	mediaType, _, _ := strings.Cut(strings.ToLower(contentType), ";")
+	switch strings.TrimSpace(mediaType) {
+	case "text/csv", "application/csv":
+		return models.ImportFormatCSV
+	case "application/geo+json":
+		return models.ImportFormatGeoJSON
+	case "application/json":
+		return models.ImportFormatJSON
+	}
+	return ""
+}
+
+// ImportService bulk loads areas and trucks. Every row is validated and written in one transaction,
+// so an import either applies completely or not at all.
+type ImportService struct {
+	db           *sql.DB
+	publisher    *events.Publisher
+	unknownAreas string
+	demand       DemandConfig
+}
+
+// NewImportService handles truck travel times to unknown areas according to the unknownAreas policy,
+// areas with a disaster type get their required resources from demand
+func NewImportService(db *sql.DB, publisher *events.Publisher, unknownAreas string, demand DemandConfig) *ImportService {
+	return &ImportService{db: db, publisher: publisher, unknownAreas: unknownAreas, demand: demand}
+}
+
+// importRow is one parsed record, Row is where it came from in the file
+type importRow[T any] struct {
+	Row   int
+	Value T
+	Err   error
+}
+
+// importRecord is a validated row ready to be written
+type importRecord struct {
+	Row   int
+	ID    string
+	Value interface{}
+	Err   error
+}
+
+// ImportAreas validates and writes areas, the actor attached to ctx is recorded in the audit trail
+func (s *ImportService) ImportAreas(ctx context.Context, r io.Reader, opts models.ImportOptions) (*models.ImportResult, error) {
+	rows, err := parseImport(opts.Format, r, newAreaImportRow, areaColumn, func(req *models.CreateAreaRequest, lat, lon float64) {
+		req.Latitude, req.Longitude = &lat, &lon
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	seen := map[string]int{}
+	records := make([]importRecord, len(rows))
+	for i := range rows {
+		row := &rows[i]
+		if row.Err == nil {
+			row.Err = binding.Validator.ValidateStruct(&row.Value)
+		}
+		if row.Err == nil {
+			row.Err = s.demand.Apply(&row.Value)
+		}
+		if row.Err == nil {
+			row.Err = ResolveArrivalWindow(&row.Value, now)
+		}
+		if row.Err == nil {
+			row.Err = checkDuplicateRow(seen, row.Value.AreaID, row.Row)
+		}
+		if row.Value.VulnerableGroups == nil {
+			row.Value.VulnerableGroups = map[string]int{}
+		}
+		records[i] = importRecord{Row: row.Row, ID: row.Value.AreaID, Value: row.Value, Err: row.Err}
+	}
+
+	write := func(tx *sql.Tx, i int) (json.RawMessage, bool, error) {
+		return writeArea(ctx, tx, rows[i].Value, opts.Mode)
+	}
+	return s.apply(ctx, "area", opts, records, write, events.AreaCreated, events.AreaUpdated)
+}
+
+// ImportTrucks validates and writes trucks. An upsert without a status keeps the truck's current status.
+// Travel times to areas that don't exist yet are reported as warnings unless the policy rejects them.
+func (s *ImportService) ImportTrucks(ctx context.Context, r io.Reader, opts models.ImportOptions) (*models.ImportResult, error) {
+	rows, err := parseImport(opts.Format, r, newTruckImportRow, truckColumn, func(req *models.CreateTruckRequest, lat, lon float64) {
+		req.Latitude, req.Longitude = &lat, &lon
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]int{}
+	records := make([]importRecord, len(rows))
+	for i := range rows {
+		row := &rows[i]
+		if row.Err == nil {
+			row.Err = binding.Validator.ValidateStruct(&row.Value)
+		}
+		if row.Err == nil {
+			row.Err = checkDuplicateRow(seen, row.Value.TruckID, row.Row)
+		}
+		records[i] = importRecord{Row: row.Row, ID: row.Value.TruckID, Value: row.Value, Err: row.Err}
+	}
+
+	warnings := make([][]apperr.FieldError, len(rows))
+	write := func(tx *sql.Tx, i int) (before json.RawMessage, created bool, err error) {
+		before, created, warnings[i], err = writeTruck(ctx, tx, rows[i].Value, opts.Mode, s.unknownAreas)
+		if err != nil {
+			// The row is rolled back and reported as an error instead
+			warnings[i] = nil
+		}
+		return before, created, err
+	}
+	result, err := s.apply(ctx, "truck", opts, records, write, events.TruckCreated, events.TruckUpdated)
+	if err != nil {
+		return nil, err
+	}
+	for i, fields := range warnings {
+		if len(fields) > 0 {
+			result.Warnings = append(result.Warnings, models.ImportRowError{Row: records[i].Row, ID: records[i].ID, Code: apperr.CodeUnknownArea, Error: apperr.Summary(fields), Fields: fields})
+		}
+	}
+	return result, nil
+}
+
+func checkDuplicateRow(seen map[string]int, id string, row int) error {
+	if first, ok := seen[id]; ok {
+		return apperr.Conflict(apperr.CodeConflict, fmt.Sprintf("Duplicate ID, already used in row %d", first))
+	}
+	seen[id] = row
+	return nil
+}
+
+// apply writes every record in one transaction. Rows are isolated by savepoints so every
+// failing row is reported, but a single failure rolls back the whole import.
+func (s *ImportService) apply(
+	ctx context.Context,
+	entityType string,
+	opts models.ImportOptions,
+	records []importRecord,
+	write func(tx *sql.Tx, i int) (before json.RawMessage, created bool, err error),
+	createdEvent, updatedEvent string,
+) (*models.ImportResult, error) {
+	result := &models.ImportResult{
+		Entity:   entityType,
+		Format:   opts.Format,
+		Mode:     opts.Mode,
+		DryRun:   opts.DryRun,
+		Total:    len(records),
+		Created:  []string{},
+		Updated:  []string{},
+		Errors:   []models.ImportRowError{},
+		Warnings: []models.ImportRowError{},
+	}
+
+	for _, record := range records {
+		if record.Err != nil {
+			result.Errors = append(result.Errors, rowError(record, apperr.Binding(record.Err)))
+		}
+	}
+	if len(result.Errors) > 0 || len(records) == 0 {
+		return result, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start import: %w", err)
+	}
+	defer tx.Rollback()
+
+	befores := make([]json.RawMessage, len(records))
+	created := make([]bool, len(records))
+	for i, record := range records {
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT import_row"); err != nil {
+			return nil, fmt.Errorf("failed to import row %d: %w", record.Row, err)
+		}
+
+		befores[i], created[i], err = write(tx, i)
+		if err != nil {
+			// Only conflicts, constraint and data errors belong to the row, anything else aborts the import
+			appErr := apperr.Database(err, "Failed to import row")
+			if appErr.Status >= http.StatusInternalServerError {
+				return nil, fmt.Errorf("failed to import row %d: %w", record.Row, err)
+			}
+			result.Errors = append(result.Errors, rowError(record, appErr))
+			if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT import_row"); err != nil {
+				return nil, fmt.Errorf("failed to import row %d: %w", record.Row, err)
+			}
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT import_row"); err != nil {
+			return nil, fmt.Errorf("failed to import row %d: %w", record.Row, err)
+		}
+		if created[i] {
+			result.Created = append(result.Created, record.ID)
+		} else {
+			result.Updated = append(result.Updated, record.ID)
+		}
+	}
+
+	if len(result.Errors) > 0 {
+		result.Created, result.Updated = []string{}, []string{}
+		return result, nil
+	}
+	// A dry run goes through the database so conflicts are reported, then rolls back
+	if opts.DryRun {
+		return result, nil
+	}
+
+	// Audit entries and events commit with the rows
+	staged := make([]events.Event, len(records))
+	for i, record := range records {
+		action, eventType := AuditCreate, createdEvent
+		if !created[i] {
+			action, eventType = AuditUpdate, updatedEvent
+		}
+		var before interface{}
+		if befores[i] != nil {
+			before = befores[i]
+		}
+		if err := RecordAudit(ctx, tx, action, entityType, record.ID, before, record.Value); err != nil {
+			return nil, err
+		}
+		if staged[i], err = s.publisher.Stage(ctx, tx, eventType, record.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit import: %w", err)
+	}
+	result.Committed = true
+	broadcast(ctx, s.publisher, staged...)
+
+	return result, nil
+}
+
+// rowError reports a rejected row with the same codes and field details as the API
+func rowError(record importRecord, appErr *apperr.Error) models.ImportRowError {
+	message := appErr.Detail
+	if len(appErr.Fields) > 0 {
+		message = apperr.Summary(appErr.Fields)
+	}
+	return models.ImportRowError{Row: record.Row, ID: record.ID, Code: appErr.Code, Error: message, Fields: appErr.Fields}
+}
+
+// lockExisting returns the stored row as JSON, or nil if it doesn't exist yet
+func lockExisting(ctx context.Context, tx *sql.Tx, query, id string) (json.RawMessage, error) {
+	var before []byte
+	err := tx.QueryRowContext(ctx, query, id).Scan(&before)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return before, err
+}
+
+func writeArea(ctx context.Context, tx *sql.Tx, req models.CreateAreaRequest, mode string) (json.RawMessage, bool, error) {
+	vulnerableJSON, err := json.Marshal(req.VulnerableGroups)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to process vulnerable groups: %w", err)
+	}
+
+	insert := "INSERT INTO areas (area_id, urgency_level, time_constraint, earliest_arrival, latest_arrival, population, vulnerable_groups, latitude, longitude, disaster_type, duration_days) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)"
+	args := []interface{}{req.AreaID, req.UrgencyLevel, req.TimeConstraint, req.EarliestArrival, req.LatestArrival, req.Population, vulnerableJSON, req.Latitude, req.Longitude, NullIfEmpty(req.DisasterType), nullIfZero(req.DurationDays)}
+
+	if mode != models.ImportModeUpsert {
+		res, err := tx.ExecContext(ctx, insert+" ON CONFLICT (area_id) DO NOTHING", args...)
+		if err != nil {
+			return nil, false, err
+		}
+		if inserted, _ := res.RowsAffected(); inserted == 0 {
+			return nil, false, apperr.Conflict(apperr.CodeAreaExists, "Area ID already exists, use mode=upsert to replace it")
+		}
+		return nil, true, saveAreaRequirements(ctx, tx, req.AreaID, req.RequiredResources, req.EstimatedResources)
+	}
+
+	before, err := lockExisting(ctx, tx, `SELECT (to_jsonb(a) - 'created_at' - 'updated_at') || jsonb_build_object('required_resources', area_requirements_json(a.area_id))
+		FROM areas a WHERE area_id = $1 FOR UPDATE`, req.AreaID)
+	if err != nil {
+		return nil, false, err
+	}
+	_, err = tx.ExecContext(ctx, insert+` ON CONFLICT (area_id) DO UPDATE SET
+		urgency_level = EXCLUDED.urgency_level,
+		time_constraint = EXCLUDED.time_constraint, earliest_arrival = EXCLUDED.earliest_arrival, latest_arrival = EXCLUDED.latest_arrival,
+		population = EXCLUDED.population, vulnerable_groups = EXCLUDED.vulnerable_groups,
+		latitude = EXCLUDED.latitude, longitude = EXCLUDED.longitude,
+		disaster_type = EXCLUDED.disaster_type, duration_days = EXCLUDED.duration_days, updated_at = CURRENT_TIMESTAMP`, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	return before, before == nil, saveAreaRequirements(ctx, tx, req.AreaID, req.RequiredResources, req.EstimatedResources)
+}
+
+func writeTruck(ctx context.Context, tx *sql.Tx, req models.CreateTruckRequest, mode, unknownAreas string) (json.RawMessage, bool, []apperr.FieldError, error) {
+	warnings, err := checkTravelAreas(ctx, tx, unknownAreas, req.TravelTimeToArea)
+	if err != nil {
+		return nil, false, nil, err
+	}
+
+	insert := "INSERT INTO trucks (truck_id, status, shift_start, shift_end, available_from, latitude, longitude) VALUES ($1, COALESCE($2::varchar, 'available'), $3, $4, $5, $6, $7)"
+	args := []interface{}{req.TruckID, NullIfEmpty(req.Status), NullIfEmpty(req.ShiftStart), NullIfEmpty(req.ShiftEnd), req.AvailableFrom, req.Latitude, req.Longitude}
+
+	if mode != models.ImportModeUpsert {
+		res, err := tx.ExecContext(ctx, insert+" ON CONFLICT (truck_id) DO NOTHING", args...)
+		if err != nil {
+			return nil, false, nil, err
+		}
+		if inserted, _ := res.RowsAffected(); inserted == 0 {
+			return nil, false, nil, apperr.Conflict(apperr.CodeTruckExists, "Truck ID already exists, use mode=upsert to replace it")
+		}
+		return nil, true, warnings, saveTruckRelations(ctx, tx, req)
+	}
+
+	before, err := lockExisting(ctx, tx, `SELECT (to_jsonb(t) - 'created_at' - 'updated_at') || jsonb_build_object(
+			'available_resources', truck_inventory_json(t.truck_id), 'travel_time_to_area', truck_travel_json(t.truck_id))
+		FROM trucks t WHERE truck_id = $1 FOR UPDATE`, req.TruckID)
+	if err != nil {
+		return nil, false, nil, err
+	}
+	_, err = tx.ExecContext(ctx, insert+` ON CONFLICT (truck_id) DO UPDATE SET
+		status = COALESCE($2::varchar, trucks.status), shift_start = EXCLUDED.shift_start, shift_end = EXCLUDED.shift_end,
+		available_from = EXCLUDED.available_from, latitude = EXCLUDED.latitude, longitude = EXCLUDED.longitude,
+		updated_at = CURRENT_TIMESTAMP`, args...)
+	if err != nil {
+		return nil, false, nil, err
+	}
+	return before, before == nil, warnings, saveTruckRelations(ctx, tx, req)
+}
+
+// saveTruckRelations writes the inventory and travel times of a truck
+func saveTruckRelations(ctx context.Context, tx execer, req models.CreateTruckRequest) error {
+	if err := saveTruckInventory(ctx, tx, req.TruckID, req.AvailableResources); err != nil {
+		return err
+	}
+	return saveTruckTravel(ctx, tx, req.TruckID, req.TravelTimeToArea)
+}
+
+// parseImport reads rows in the given format. CSV columns are resolved by column,
+// GeoJSON Point geometries are passed to setCoordinates.
+func parseImport[T any](
+	format string,
+	r io.Reader,
+	newRow func() T,
+	column func(header string) (func(*T, string) error, error),
+	setCoordinates func(*T, float64, float64),
+) ([]importRow[T], error) {
+	switch format {
+	case models.ImportFormatCSV:
+		return parseCSVRows(r, newRow, column)
+	case models.ImportFormatJSON:
+		return parseJSONRows(r, newRow)
+	case models.ImportFormatGeoJSON:
+		return parseGeoJSONRows(r, newRow, setCoordinates)
+	}
+	return nil, fmt.Errorf("%w: unknown format %q, expected csv, json or geojson", ErrInvalidImport, format)
+}
+
+// decodeStrict rejects unknown fields so misspelled columns don't silently drop data
+func decodeStrict(raw []byte, value interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(value)
+}
+
+func parseJSONRows[T any](r io.Reader, newRow func() T) ([]importRow[T], error) {
+	var raw []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("%w: expected a JSON array of objects: %v", ErrInvalidImport, err)
+	}
+
+	rows := make([]importRow[T], len(raw))
+	for i, item := range raw {
+		rows[i] = importRow[T]{Row: i + 1, Value: newRow()}
+		rows[i].Err = decodeStrict(item, &rows[i].Value)
+	}
+	return rows, nil
+}
+
+type geoJSONFeatureCollection struct {
+	Type     string `json:"type"`
+	Features []struct {
+		Type     string `json:"type"`
+		Geometry *struct {
+			Type        string          `json:"type"`
+			Coordinates json.RawMessage `json:"coordinates"`
+		} `json:"geometry"`
+		Properties json.RawMessage `json:"properties"`
+	} `json:"features"`
+}
+
+// parseGeoJSONRows reads a FeatureCollection, properties hold the fields and a Point geometry sets the coordinates
+func parseGeoJSONRows[T any](r io.Reader, newRow func() T, setCoordinates func(*T, float64, float64)) ([]importRow[T], error) {
+	var collection geoJSONFeatureCollection
+	if err := json.NewDecoder(r).Decode(&collection); err != nil {
+		return nil, fmt.Errorf("%w: expected a GeoJSON FeatureCollection: %v", ErrInvalidImport, err)
+	}
+	if collection.Type != "FeatureCollection" {
+		return nil, fmt.Errorf("%w: expected a GeoJSON FeatureCollection, got type %q", ErrInvalidImport, collection.Type)
+	}
+
+	rows := make([]importRow[T], len(collection.Features))
+	for i, feature := range collection.Features {
+		row := importRow[T]{Row: i + 1, Value: newRow()}
+		switch {
+		case feature.Type != "Feature":
+			row.Err = fmt.Errorf("expected a Feature, got type %q", feature.Type)
+		case len(feature.Properties) == 0 || string(feature.Properties) == "null":
+			row.Err = errors.New("feature has no properties")
+		default:
+			row.Err = decodeStrict(feature.Properties, &row.Value)
+		}
+
+		if row.Err == nil && feature.Geometry != nil {
+			var position []float64
+			if feature.Geometry.Type != "Point" {
+				row.Err = fmt.Errorf("geometry must be a Point, got %s", feature.Geometry.Type)
+			} else if err := json.Unmarshal(feature.Geometry.Coordinates, &position); err != nil || len(position) < 2 {
+				row.Err = errors.New("point geometry needs [longitude, latitude] coordinates")
+			} else {
+				setCoordinates(&row.Value, position[1], position[0])
+			}
+		}
+		rows[i] = row
+	}
+	return rows, nil
+}
+
+// parseCSVRows reads a CSV with a header row, blank cells are left unset
+func parseCSVRows[T any](r io.Reader, newRow func() T, column func(header string) (func(*T, string) error, error)) ([]importRow[T], error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read CSV header: %v", ErrInvalidImport, err)
+	}
+
+	setters := make([]func(*T, string) error, len(header))
+	for i, name := range header {
+		// Spreadsheet exports often start with a byte order mark
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		header[i] = name
+		if setters[i], err = column(name); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+	}
+
+	var rows []importRow[T]
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: row %d: %v", ErrInvalidImport, line, err)
+		}
+		if isBlankRecord(record) {
+			continue
+		}
+
+		row := importRow[T]{Row: line, Value: newRow()}
+		if len(record) != len(header) {
+			row.Err = fmt.Errorf("expected %d columns, got %d", len(header), len(record))
+		}
+		for i := 0; row.Err == nil && i < len(record); i++ {
+			value := strings.TrimSpace(record[i])
+			if value == "" {
+				continue
+			}
+			if err := setters[i](&row.Value, value); err != nil {
+				row.Err = &apperr.Error{
+					Status: http.StatusBadRequest,
+					Code:   apperr.CodeValidationFailed,
+					Detail: "The row has invalid cells",
+					Fields: []apperr.FieldError{{Field: header[i], Rule: "type", Message: err.Error()}},
+				}
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func isBlankRecord(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeColumn lets "areaId", "area_id" and "Area ID" name the same column
+func normalizeColumn(name string) string {
+	return strings.NewReplacer("_", "", " ", "", "-", "").Replace(strings.ToLower(name))
+}
+
+// mapColumn parses "<prefix>.<key>" or "<prefix>:<key>" columns such as "resource.water"
+func mapColumn(header, prefix string) (string, bool) {
+	for _, separator := range []string{".", ":"} {
+		if len(header) > len(prefix)+1 && strings.EqualFold(header[:len(prefix)+1], prefix+separator) {
+			return strings.TrimSpace(header[len(prefix)+1:]), true
+		}
+	}
+	return "", false
+}
+
+func parseIntCell(value string, target *int) error {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%q is not a whole number", value)
+	}
+	*target = n
+	return nil
+}
+
+func parseMapCell(value string, target map[string]int, key string) error {
+	var n int
+	if err := parseIntCell(value, &n); err != nil {
+		return err
+	}
+	target[key] = n
+	return nil
+}
+
+func parseTimeCell(value string, target **time.Time) error {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return fmt.Errorf("%q is not an RFC 3339 time", value)
+	}
+	*target = &t
+	return nil
+}
+
+func parseFloatCell(value string, target **float64) error {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("%q is not a number", value)
+	}
+	*target = &f
+	return nil
+}
+
+func newAreaImportRow() models.CreateAreaRequest {
+	return models.CreateAreaRequest{RequiredResources: map[string]int{}, VulnerableGroups: map[string]int{}}
+}
+
+// areaColumn maps area CSV columns, resources go in "resource.<type>" and vulnerable groups in "vulnerable.<group>"
+func areaColumn(header string) (func(*models.CreateAreaRequest, string) error, error) {
+	if key, ok := mapColumn(header, "resource"); ok {
+		return func(req *models.CreateAreaRequest, value string) error {
+			return parseMapCell(value, req.RequiredResources, key)
+		}, nil
+	}
+	if key, ok := mapColumn(header, "vulnerable"); ok {
+		return func(req *models.CreateAreaRequest, value string) error {
+			return parseMapCell(value, req.VulnerableGroups, key)
+		}, nil
+	}
+
+	switch normalizeColumn(header) {
+	case "areaid":
+		return func(req *models.CreateAreaRequest, value string) error {
+			req.AreaID = value
+			return nil
+		}, nil
+	case "urgencylevel":
+		return func(req *models.CreateAreaRequest, value string) error {
+			return parseIntCell(value, &req.UrgencyLevel)
+		}, nil
+	case "timeconstraint":
+		return func(req *models.CreateAreaRequest, value string) error {
+			return parseIntCell(value, &req.TimeConstraint)
+		}, nil
+	case "earliestarrival":
+		return func(req *models.CreateAreaRequest, value string) error {
+			return parseTimeCell(value, &req.EarliestArrival)
+		}, nil
+	case "latestarrival":
+		return func(req *models.CreateAreaRequest, value string) error {
+			return parseTimeCell(value, &req.LatestArrival)
+		}, nil
+	case "population":
+		return func(req *models.CreateAreaRequest, value string) error {
+			return parseIntCell(value, &req.Population)
+		}, nil
+	case "disastertype":
+		return func(req *models.CreateAreaRequest, value string) error {
+			req.DisasterType = value
+			return nil
+		}, nil
+	case "durationdays":
+		return func(req *models.CreateAreaRequest, value string) error {
+			return parseIntCell(value, &req.DurationDays)
+		}, nil
+	case "latitude":
+		return func(req *models.CreateAreaRequest, value string) error {
+			return parseFloatCell(value, &req.Latitude)
+		}, nil
+	case "longitude":
+		return func(req *models.CreateAreaRequest, value string) error {
+			return parseFloatCell(value, &req.Longitude)
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown area column %q", header)
+}
+
+func newTruckImportRow() models.CreateTruckRequest {
+	return models.CreateTruckRequest{AvailableResources: map[string]int{}, TravelTimeToArea: map[string]int{}}
+}
+
+// truckColumn maps truck CSV columns, resources go in "resource.<type>" and travel minutes in "travel.<areaId>"
+func truckColumn(header string) (func(*models.CreateTruckRequest, string) error, error) {
+	if key, ok := mapColumn(header, "resource"); ok {
+		return func(req *models.CreateTruckRequest, value string) error {
+			return parseMapCell(value, req.AvailableResources, key)
+		}, nil
+	}
+	if key, ok := mapColumn(header, "travel"); ok {
+		return func(req *models.CreateTruckRequest, value string) error {
+			return parseMapCell(value, req.TravelTimeToArea, key)
+		}, nil
+	}
+
+	switch normalizeColumn(header) {
+	case "truckid":
+		return func(req *models.CreateTruckRequest, value string) error {
+			req.TruckID = value
+			return nil
+		}, nil
+	case "status":
+		return func(req *models.CreateTruckRequest, value string) error {
+			req.Status = strings.ToLower(value)
+			return nil
+		}, nil
+	case "shiftstart":
+		return func(req *models.CreateTruckRequest, value string) error {
+			req.ShiftStart = value
+			return nil
+		}, nil
+	case "shiftend":
+		return func(req *models.CreateTruckRequest, value string) error {
+			req.ShiftEnd = value
+			return nil
+		}, nil
+	case "availablefrom":
+		return func(req *models.CreateTruckRequest, value string) error {
+			return parseTimeCell(value, &req.AvailableFrom)
+		}, nil
+	case "latitude":
+		return func(req *models.CreateTruckRequest, value string) error {
+			return parseFloatCell(value, &req.Latitude)
+		}, nil
+	case "longitude":
+		return func(req *models.CreateTruckRequest, value string) error {
+			return parseFloatCell(value, &req.Longitude)
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown truck column %q", header)
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"workship-disaster-api/apperr"
+	"workship-disaster-api/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func parseAreas(t *testing.T, format, input string) ([]importRow[models.CreateAreaRequest], error) {
+	t.Helper()
+	return parseImport(format, strings.NewReader(input), newAreaImportRow, areaColumn, func(req *models.CreateAreaRequest, lat, lon float64) {
+		req.Latitude, req.Longitude = &lat, &lon
+	})
+}
+
+func TestParseCSVRows(t *testing.T) {
+	input := "\ufeffArea ID,urgency_level,timeConstraint,resource.water,vulnerable:elderly\n" +
+		"A1,3,60,100,5\n" +
+		",,,,\n" +
+		"A2,high,60,,\n" +
+		"A3,2\n"
+
+	rows, err := parseAreas(t, models.ImportFormatCSV, input)
+	if err != nil {
+		t.Fatalf("parseImport: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want 3 with the blank line skipped", len(rows))
+	}
+
+	first := rows[0]
+	if first.Row != 2 || first.Err != nil {
+		t.Fatalf("row %d err %v, want row 2 without error", first.Row, first.Err)
+	}
+	if first.Value.AreaID != "A1" || first.Value.UrgencyLevel != 3 || first.Value.TimeConstraint != 60 ||
+		first.Value.RequiredResources["water"] != 100 || first.Value.VulnerableGroups["elderly"] != 5 {
+		t.Errorf("row 2 = %+v", first.Value)
+	}
+	if rows[1].Row != 4 || rows[1].Err == nil || !strings.Contains(rows[1].Err.Error(), "invalid cells") {
+		t.Errorf("row %d err %v, want a cell error on row 4", rows[1].Row, rows[1].Err)
+	}
+	if rows[2].Row != 5 || rows[2].Err == nil || !strings.Contains(rows[2].Err.Error(), "expected 5 columns") {
+		t.Errorf("row %d err %v, want a column count error on row 5", rows[2].Row, rows[2].Err)
+	}
+}
+
+func TestParseCSVUnknownColumn(t *testing.T) {
+	_, err := parseAreas(t, models.ImportFormatCSV, "areaId,urgncy\nA1,3\n")
+	if !errors.Is(err, ErrInvalidImport) {
+		t.Errorf("err = %v, want ErrInvalidImport", err)
+	}
+}
+
+func TestParseJSONRows(t *testing.T) {
+	rows, err := parseAreas(t, models.ImportFormatJSON, `[{"areaId":"A1","urgencyLevel":2,"requiredResources":{"food":5}},{"areaId":"A2","urgncy":1}]`)
+	if err != nil {
+		t.Fatalf("parseImport: %v", err)
+	}
+	if len(rows) != 2 || rows[0].Err != nil || rows[0].Value.RequiredResources["food"] != 5 {
+		t.Fatalf("rows = %+v", rows)
+	}
+	if rows[1].Row != 2 || rows[1].Err == nil {
+		t.Errorf("row 2 err %v, want the misspelled field rejected", rows[1].Err)
+	}
+
+	if _, err := parseAreas(t, models.ImportFormatJSON, `{"areaId":"A1"}`); !errors.Is(err, ErrInvalidImport) {
+		t.Errorf("err = %v, want ErrInvalidImport for an object", err)
+	}
+}
+
+func TestParseGeoJSONRows(t *testing.T) {
+	input := `{"type":"FeatureCollection","features":[
+		{"type":"Feature","geometry":{"type":"Point","coordinates":[100.5,13.75]},"properties":{"areaId":"A1","urgencyLevel":4}},
+		{"type":"Feature","geometry":{"type":"LineString","coordinates":[[100,13],[101,14]]},"properties":{"areaId":"A2"}},
+		{"type":"Feature","geometry":null,"properties":null},
+		{"type":"Feature","geometry":null,"properties":{"areaId":"A4","urgencyLevel":1}}
+	]}`
+
+	rows, err := parseAreas(t, models.ImportFormatGeoJSON, input)
+	if err != nil {
+		t.Fatalf("parseImport: %v", err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("got %d rows, want 4", len(rows))
+	}
+	first := rows[0].Value
+	if rows[0].Err != nil || first.AreaID != "A1" || first.Latitude == nil || *first.Latitude != 13.75 || *first.Longitude != 100.5 {
+		t.Errorf("row 1 = %+v, err %v, want GeoJSON [lon, lat] order applied", first, rows[0].Err)
+	}
+	if rows[1].Err == nil || rows[2].Err == nil {
+		t.Errorf("rows 2 and 3 = %v, %v, want a non-Point geometry and missing properties rejected", rows[1].Err, rows[2].Err)
+	}
+	if rows[3].Err != nil || rows[3].Value.Latitude != nil {
+		t.Errorf("row 4 = %+v, err %v, want a feature without geometry accepted without coordinates", rows[3].Value, rows[3].Err)
+	}
+
+	if _, err := parseAreas(t, models.ImportFormatGeoJSON, `{"type":"Feature"}`); !errors.Is(err, ErrInvalidImport) {
+		t.Errorf("err = %v, want ErrInvalidImport for a single feature", err)
+	}
+}
+
+func TestParseImportUnknownFormat(t *testing.T) {
+	if _, err := parseAreas(t, "xml", "<areas/>"); !errors.Is(err, ErrInvalidImport) {
+		t.Errorf("err = %v, want ErrInvalidImport", err)
+	}
+}
+
+const importCSV = "areaId,urgencyLevel,timeConstraint,resource.water\nA1,3,60,100\nA2,2,120,50\n"
+
+// expectAreaRow expects one area row written in fail mode, inserted reports whether the ID was new
+func expectAreaRow(mock sqlmock.Sqlmock, areaID string, inserted bool) {
+	mock.ExpectExec("SAVEPOINT import_row").WillReturnResult(sqlmock.NewResult(0, 0))
+	if !inserted {
+		mock.ExpectExec("INSERT INTO areas .* ON CONFLICT \\(area_id\\) DO NOTHING").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("ROLLBACK TO SAVEPOINT import_row").WillReturnResult(sqlmock.NewResult(0, 0))
+		return
+	}
+	mock.ExpectExec("INSERT INTO areas .* ON CONFLICT \\(area_id\\) DO NOTHING").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM area_requirements").WithArgs(areaID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO area_requirements").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("RELEASE SAVEPOINT import_row").WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+// TestImportDryRunRollsBack checks a dry run writes every row to find conflicts, then rolls back
+func TestImportDryRunRollsBack(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	service := NewImportService(db, testPublisher(t), UnknownAreaWarn, DefaultDemandConfig())
+
+	mock.ExpectBegin()
+	expectAreaRow(mock, "A1", true)
+	expectAreaRow(mock, "A2", true)
+	mock.ExpectRollback()
+
+	result, err := service.ImportAreas(context.Background(), strings.NewReader(importCSV), models.ImportOptions{Format: models.ImportFormatCSV, DryRun: true})
+	if err != nil {
+		t.Fatalf("ImportAreas: %v", err)
+	}
+	if result.Committed || len(result.Created) != 2 || len(result.Errors) != 0 {
+		t.Errorf("result = %+v, want both rows reported as created but nothing committed", result)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestImportFailModeRejectsExisting checks an existing ID fails its row and rolls back the whole import
+func TestImportFailModeRejectsExisting(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	service := NewImportService(db, testPublisher(t), UnknownAreaWarn, DefaultDemandConfig())
+
+	mock.ExpectBegin()
+	expectAreaRow(mock, "A1", true)
+	expectAreaRow(mock, "A2", false)
+	mock.ExpectRollback()
+
+	result, err := service.ImportAreas(context.Background(), strings.NewReader(importCSV), models.ImportOptions{Format: models.ImportFormatCSV, Mode: models.ImportModeFail})
+	if err != nil {
+		t.Fatalf("ImportAreas: %v", err)
+	}
+	if result.Committed || len(result.Created) != 0 || len(result.Errors) != 1 {
+		t.Fatalf("result = %+v, want one error and nothing created", result)
+	}
+	if rowErr := result.Errors[0]; rowErr.Row != 3 || rowErr.ID != "A2" || rowErr.Code != apperr.CodeAreaExists {
+		t.Errorf("error = %+v, want row 3 rejected as an existing area", rowErr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestImportInvalidRowsSkipDatabase checks rows that fail validation are reported without a transaction
+func TestImportInvalidRowsSkipDatabase(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	service := NewImportService(db, testPublisher(t), UnknownAreaWarn, DefaultDemandConfig())
+
+	input := "areaId,urgencyLevel,timeConstraint\nA1,9,60\nA1,3,60\nA1,3,60\n"
+	result, err := service.ImportAreas(context.Background(), strings.NewReader(input), models.ImportOptions{Format: models.ImportFormatCSV})
+	if err != nil {
+		t.Fatalf("ImportAreas: %v", err)
+	}
+	// Row 2 is out of range, row 4 repeats the ID row 3 claimed
+	if len(result.Errors) != 2 || result.Errors[0].Row != 2 || result.Errors[1].Row != 4 {
+		t.Errorf("errors = %+v, want rows 2 and 4", result.Errors)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
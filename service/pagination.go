@@ -0,0 +1,136 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrInvalidSort is returned when a list is sorted by a field that isn't whitelisted
+	ErrInvalidSort = errors.New("invalid sort field")
+	// ErrInvalidCursor is returned when a cursor is malformed or was issued for another sort order
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// defaultPageSize applies when a list request has no limit
+const defaultPageSize = 100
+
+// listQuery builds the WHERE clause of a list query with numbered placeholders
+type listQuery struct {
+	conditions []string
+	args       []interface{}
+}
+
+// where adds a condition, each %d in it becomes the placeholder of the next value
+func (q *listQuery) where(condition string, values ...interface{}) {
+	placeholders := make([]interface{}, len(values))
+	for i, value := range values {
+		q.args = append(q.args, value)
+		placeholders[i] = len(q.args)
+	}
+	q.conditions = append(q.conditions, fmt.Sprintf(condition, placeholders...))
+}
+
+func (q *listQuery) clause() string {
+	if len(q.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(q.conditions, " AND ")
+}
+
+// sortColumn is a column lists may be sorted by, Type casts cursor values back from text
+type sortColumn struct {
+	Column string
+	Type   string
+}
+
+// listSort is a whitelisted sort order, ties are broken by the primary key in the same direction
+type listSort struct {
+	sortColumn
+	Field string
+	Desc  bool
+}
+
+// parseSort resolves "field" or "-field" against the whitelist of field names to columns
+func parseSort(raw string, columns map[string]sortColumn, fallback string) (listSort, error) {
+	if raw == "" {
+		raw = fallback
+	}
+	field, desc := strings.TrimPrefix(raw, "-"), strings.HasPrefix(raw, "-")
+	column, ok := columns[field]
+	if !ok {
+		fields := make([]string, 0, len(columns))
+		for name := range columns {
+			fields = append(fields, name)
+		}
+		sort.Strings(fields)
+		return listSort{}, fmt.Errorf("%w %q, expected one of %s, prefixed with - to sort descending", ErrInvalidSort, field, strings.Join(fields, ", "))
+	}
+	return listSort{sortColumn: column, Field: field, Desc: desc}, nil
+}
+
+// String is the sort as given in the query, e.g. "-urgencyLevel"
+func (s listSort) String() string {
+	if s.Desc {
+		return "-" + s.Field
+	}
+	return s.Field
+}
+
+// value selects the sort column as text, the last row's value goes into the next cursor
+func (s listSort) value() string {
+	return s.Column + "::text"
+}
+
+// orderBy orders by the sort column and then the key column
+func (s listSort) orderBy(key string) string {
+	direction := "ASC"
+	if s.Desc {
+		direction = "DESC"
+	}
+	return fmt.Sprintf(" ORDER BY %s %s, %s %s", s.Column, direction, key, direction)
+}
+
+// after restricts q to rows following the cursor position in this sort order
+func (s listSort) after(q *listQuery, key string, cursor pageCursor) {
+	operator := ">"
+	if s.Desc {
+		operator = "<"
+	}
+	q.where(fmt.Sprintf("(%s, %s) %s ($%%d::%s, $%%d)", s.Column, key, operator, s.Type), cursor.Value, cursor.Key)
+}
+
+// pageCursor is the sort value and key of the last row of a page, bound to the sort order
+type pageCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	Key   string `json:"k"`
+}
+
+func (c pageCursor) encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeCursor reads a cursor issued for sort, an empty cursor is the first page
+func decodeCursor(raw string, sort listSort) (*pageCursor, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor pageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Key == "" {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.Sort != sort.String() {
+		return nil, fmt.Errorf("%w, it was issued for sort %q", ErrInvalidCursor, cursor.Sort)
+	}
+	return &cursor, nil
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"workship-disaster-api/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want listSort
+	}{
+		{"", listSort{sortColumn: areaSorts["urgencyLevel"], Field: "urgencyLevel", Desc: true}},
+		{"population", listSort{sortColumn: areaSorts["population"], Field: "population"}},
+		{"-createdAt", listSort{sortColumn: areaSorts["createdAt"], Field: "createdAt", Desc: true}},
+	}
+	for _, tt := range tests {
+		got, err := parseSort(tt.raw, areaSorts, "-urgencyLevel")
+		if err != nil || got != tt.want {
+			t.Errorf("parseSort(%q) = %+v, %v, want %+v", tt.raw, got, err, tt.want)
+		}
+	}
+
+	_, err := parseSort("-latitude", areaSorts, "-urgencyLevel")
+	if !errors.Is(err, ErrInvalidSort) || !strings.Contains(err.Error(), "areaId, createdAt, population, timeConstraint, urgencyLevel") {
+		t.Errorf("parseSort(-latitude) error = %v, want ErrInvalidSort listing the fields in order", err)
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	sort, _ := parseSort("-urgencyLevel", areaSorts, "")
+	want := pageCursor{Sort: sort.String(), Value: "5", Key: "A/1 ä"}
+
+	got, err := decodeCursor(want.encode(), sort)
+	if err != nil || got == nil || *got != want {
+		t.Fatalf("decodeCursor(encode()) = %+v, %v, want %+v", got, err, want)
+	}
+	if got, err := decodeCursor("", sort); got != nil || err != nil {
+		t.Errorf("empty cursor = %+v, %v, want the first page", got, err)
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	sort, _ := parseSort("-urgencyLevel", areaSorts, "")
+	tests := map[string]string{
+		"issued for another sort": pageCursor{Sort: "urgencyLevel", Value: "5", Key: "A1"}.encode(),
+		"missing key":             pageCursor{Sort: "-urgencyLevel", Value: "5"}.encode(),
+		"not base64":              "not a cursor!",
+		"not JSON":                "bm90IGpzb24",
+	}
+	for name, raw := range tests {
+		if _, err := decodeCursor(raw, sort); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: err = %v, want ErrInvalidCursor", name, err)
+		}
+	}
+}
+
+// TestSortAfter checks the cursor condition compares the sort value and then the key,
+// so rows sharing a sort value are neither skipped nor repeated across pages
+func TestSortAfter(t *testing.T) {
+	cursor := pageCursor{Value: "3", Key: "A7"}
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"urgencyLevel", "(urgency_level, area_id) > ($2::integer, $3)"},
+		{"-urgencyLevel", "(urgency_level, area_id) < ($2::integer, $3)"},
+		{"-createdAt", "(created_at, area_id) < ($2::timestamptz, $3)"},
+	}
+	for _, tt := range tests {
+		sort, _ := parseSort(tt.raw, areaSorts, "")
+		var q listQuery
+		q.where("population >= $%d", 100)
+		sort.after(&q, "area_id", cursor)
+
+		if got := q.conditions[1]; got != tt.want {
+			t.Errorf("%s: condition %q, want %q", tt.raw, got, tt.want)
+		}
+		if want := []interface{}{100, "3", "A7"}; !reflect.DeepEqual(q.args, want) {
+			t.Errorf("%s: args %v, want %v", tt.raw, q.args, want)
+		}
+	}
+}
+
+func areaRow(id string, urgency int) []driver.Value {
+	return []driver.Value{id, urgency, []byte(`{"water":10}`), 60, nil, nil, 100, []byte(`{}`), nil, nil, "", 0, "{}", time.Now(), "A-sort-" + id}
+}
+
+var areaColumns = []string{"area_id", "urgency_level", "required_resources", "time_constraint", "earliest_arrival", "latest_arrival", "population", "vulnerable_groups", "latitude", "longitude", "disaster_type", "duration_days", "estimated", "created_at", "sort_value"}
+
+// TestListAreasPages checks one row past the limit is fetched to tell whether another page follows
+func TestListAreasPages(t *testing.T) {
+	tests := []struct {
+		name       string
+		rows       int
+		wantItems  int
+		wantCursor bool
+	}{
+		{"more rows than the limit", 3, 2, true},
+		{"exactly the limit", 2, 2, false},
+		{"fewer rows than the limit", 1, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			service := NewAreaService(db, DefaultDemandConfig(), testPublisher(t))
+
+			rows := sqlmock.NewRows(areaColumns)
+			for i := 0; i < tt.rows; i++ {
+				rows.AddRow(areaRow([]string{"A1", "A2", "A3"}[i], 5-i)...)
+			}
+			mock.ExpectQuery("SELECT count").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(tt.rows))
+			mock.ExpectQuery(`ORDER BY urgency_level DESC, area_id DESC LIMIT \$1`).WithArgs(3).WillReturnRows(rows)
+
+			page, err := service.ListAreas(context.Background(), models.AreaFilter{Limit: 2})
+			if err != nil {
+				t.Fatalf("ListAreas: %v", err)
+			}
+			if len(page.Items) != tt.wantItems || (page.NextCursor != "") != tt.wantCursor {
+				t.Fatalf("got %d items, cursor %q, want %d items, cursor %v", len(page.Items), page.NextCursor, tt.wantItems, tt.wantCursor)
+			}
+			if tt.wantCursor {
+				sort, _ := parseSort("-urgencyLevel", areaSorts, "")
+				cursor, err := decodeCursor(page.NextCursor, sort)
+				if err != nil || cursor.Key != "A2" || cursor.Value != "A-sort-A2" {
+					t.Errorf("next cursor = %+v, %v, want the last returned row", cursor, err)
+				}
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestListTrucksAfterCursor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	service := NewTruckService(db, UnknownAreaWarn, time.UTC, testPublisher(t))
+
+	truckColumns := []string{"truck_id", "available_resources", "travel_time_to_area", "status", "shift_start", "shift_end", "available_from", "latitude", "longitude", "created_at", "sort_value"}
+	rows := sqlmock.NewRows(truckColumns)
+	for _, id := range []string{"T3", "T4"} {
+		rows.AddRow(id, []byte(`{"water":50}`), []byte(`{}`), "available", nil, nil, nil, nil, nil, time.Now(), id)
+	}
+	cursor := pageCursor{Sort: "truckId", Value: "T2", Key: "T2"}.encode()
+
+	mock.ExpectQuery("SELECT count.* WHERE status = \\$1").WithArgs("available").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(4))
+	mock.ExpectQuery(`WHERE status = \$1 AND \(truck_id, truck_id\) > \(\$2::text, \$3\) ORDER BY truck_id ASC, truck_id ASC LIMIT \$4`).
+		WithArgs("available", "T2", "T2", 2).WillReturnRows(rows)
+
+	page, err := service.ListTrucks(context.Background(), models.TruckFilter{Status: "available", Cursor: cursor, Limit: 1})
+	if err != nil {
+		t.Fatalf("ListTrucks: %v", err)
+	}
+	if len(page.Items) != 1 || page.Items[0].TruckID != "T3" || page.NextCursor == "" || page.Total != 4 {
+		t.Errorf("page = %+v, want T3 and a cursor to the next page", page)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
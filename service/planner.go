@@ -0,0 +1,515 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"workship-disaster-api/events"
+	"workship-disaster-api/logging"
+	"workship-disaster-api/metrics"
+	"workship-disaster-api/models"
+	"workship-disaster-api/tracing"
+
+	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// PlanCacheKey holds the assignments of the latest plan
+const PlanCacheKey = "assignments:latest"
+
+// planCacheTTL is how long a plan stays cached when the worker isn't refreshing it
+const planCacheTTL = 30 * time.Minute
+
+// ErrPlanNotFound is returned when a plan doesn't exist
+var ErrPlanNotFound = errors.New("plan not found")
+
+// ErrPlannerBusy is returned by a background replan while another replica is storing a plan
+var ErrPlannerBusy = errors.New("another planner is replanning")
+
+// PlannerHeartbeatInterval is how often an idle worker records its heartbeat
+const PlannerHeartbeatInterval = 10 * time.Second
+
+// plannerChannel carries data change notifications to the workers of every replica
+const plannerChannel = "planner:changes"
+
+// plannerLockKey is the advisory lock held while a plan is computed and stored,
+// so replicas never store plans concurrently
+const plannerLockKey int64 = 0x706c616e6e6572
+
+// PlannerConfig controls the background planner worker
+type PlannerConfig struct {
+	// Interval between scheduled replans, zero disables the schedule
+	Interval time.Duration
+	// Debounce is how long the worker waits for data changes to settle before replanning
+	Debounce time.Duration
+	// MaxDelay caps how long a steady stream of changes can hold off a replan
+	MaxDelay time.Duration
+}
+
+// LoadPlannerConfig reads PLANNER_INTERVAL, PLANNER_DEBOUNCE and PLANNER_MAX_DELAY
+func LoadPlannerConfig() (PlannerConfig, error) {
+	cfg := PlannerConfig{
+		Interval: 5 * time.Minute,
+		Debounce: 10 * time.Second,
+		MaxDelay: time.Minute,
+	}
+
+	if raw := os.Getenv("PLANNER_INTERVAL"); raw != "" {
+		value, err := time.ParseDuration(raw)
+		if err != nil || value < 0 {
+			return cfg, fmt.Errorf("invalid PLANNER_INTERVAL: %q", raw)
+		}
+		cfg.Interval = value
+	}
+
+	if raw := os.Getenv("PLANNER_DEBOUNCE"); raw != "" {
+		value, err := time.ParseDuration(raw)
+		if err != nil || value < 0 {
+			return cfg, fmt.Errorf("invalid PLANNER_DEBOUNCE: %q", raw)
+		}
+		cfg.Debounce = value
+	}
+
+	if raw := os.Getenv("PLANNER_MAX_DELAY"); raw != "" {
+		value, err := time.ParseDuration(raw)
+		if err != nil || value <= 0 {
+			return cfg, fmt.Errorf("invalid PLANNER_MAX_DELAY: %q", raw)
+		}
+		cfg.MaxDelay = value
+	}
+
+	return cfg, nil
+}
+
+// PlannerService computes, stores and publishes plans, either on demand or from its worker loop
+type PlannerService struct {
+	db                *sql.DB
+	rdb               *redis.Client
+	assignmentService *AssignmentService
+	publisher         *events.Publisher
+	config            PlannerConfig
+	logger            *slog.Logger
+
+	// mu serialises plan computations in this process, plannerLockKey across replicas,
+	// so diffs are always against the previous plan
+	mu      sync.Mutex
+	changes chan string
+
+	// heartbeat is when the worker loop last came round, in unix nanoseconds
+	heartbeat atomic.Int64
+}
+
+func NewPlannerService(db *sql.DB, rdb *redis.Client, assignmentService *AssignmentService, publisher *events.Publisher, config PlannerConfig, logger *slog.Logger) *PlannerService {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &PlannerService{
+		db:                db,
+		rdb:               rdb,
+		assignmentService: assignmentService,
+		publisher:         publisher,
+		config:            config,
+		logger:            logger.With(slog.String("component", "planner")),
+		changes:           make(chan string, 64),
+	}
+}
+
+// Assignments exposes the assignment service for lock management
+func (p *PlannerService) Assignments() *AssignmentService {
+	return p.assignmentService
+}
+
+// NotifyChange tells the workers of every replica that planning input changed, e.g. "area.created".
+// Redis delivers the change back to this replica too, without Redis only the local worker hears it.
+func (p *PlannerService) NotifyChange(reason string) {
+	if err := p.rdb.Publish(context.Background(), plannerChannel, reason).Err(); err != nil {
+		p.logger.Warn("failed to share change with other replicas", slog.String("reason", reason), slog.Any("error", err))
+		p.queueChange(reason)
+	}
+}
+
+// queueChange hands a change to the local worker. It never blocks, a full queue already
+// guarantees a pending replan.
+func (p *PlannerService) queueChange(reason string) {
+	select {
+	case p.changes <- reason:
+	default:
+	}
+}
+
+// Heartbeat returns when the background worker last came round its loop, zero if it never ran.
+// A worker stuck in a replan stops beating.
+func (p *PlannerService) Heartbeat() time.Time {
+	beat := p.heartbeat.Load()
+	if beat == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, beat)
+}
+
+// Run replans on the configured interval and after debounced data changes until ctx is done.
+// Every replica runs a worker, the one that takes the planner lock stores the plan.
+func (p *PlannerService) Run(ctx context.Context) {
+	heartbeat := time.NewTicker(PlannerHeartbeatInterval)
+	defer heartbeat.Stop()
+	p.heartbeat.Store(time.Now().UnixNano())
+
+	// go-redis resubscribes by itself after a dropped connection
+	pubsub := p.rdb.Subscribe(ctx, plannerChannel)
+	defer pubsub.Close()
+	remote := pubsub.Channel()
+
+	var tick <-chan time.Time
+	if p.config.Interval > 0 {
+		ticker := time.NewTicker(p.config.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	debounce := time.NewTimer(p.config.Debounce)
+	if !debounce.Stop() {
+		<-debounce.C
+	}
+	defer debounce.Stop()
+	pending := map[string]bool{}
+	var firstPending time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-heartbeat.C:
+			p.heartbeat.Store(time.Now().UnixNano())
+
+		case <-tick:
+			p.replanInBackground(ctx, "schedule")
+
+		case msg := <-remote:
+			p.queueChange(msg.Payload)
+
+		case reason := <-p.changes:
+			// Every change restarts the quiet period, up to MaxDelay after the first one
+			now := time.Now()
+			if len(pending) == 0 {
+				firstPending = now
+			}
+			pending[reason] = true
+			resetTimer(debounce, p.debounceWait(firstPending, now))
+
+		case <-debounce.C:
+			reasons := make([]string, 0, len(pending))
+			for reason := range pending {
+				reasons = append(reasons, reason)
+			}
+			sort.Strings(reasons)
+			pending = map[string]bool{}
+			p.replanInBackground(ctx, "change:"+strings.Join(reasons, ","))
+		}
+	}
+}
+
+// debounceWait is how long to wait for more changes, the quiet period never pushes
+// the replan past MaxDelay after the first pending change
+func (p *PlannerService) debounceWait(firstPending, now time.Time) time.Duration {
+	wait := p.config.Debounce
+	if p.config.MaxDelay > 0 {
+		if left := firstPending.Add(p.config.MaxDelay).Sub(now); left < wait {
+			wait = max(left, 0)
+		}
+	}
+	return wait
+}
+
+// resetTimer restarts t without a tick left over from before. A timer that fired while a change
+// was being handled still holds its tick, go 1.22 timers keep it across Reset.
+func resetTimer(t *time.Timer, d time.Duration) {
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+	t.Reset(d)
+}
+
+func (p *PlannerService) replanInBackground(ctx context.Context, trigger string) {
+	logger := p.logger.With(slog.String("trigger", trigger))
+	plan, err := p.replanLocked(logging.WithLogger(ctx, logger), trigger, time.Now(), false)
+	if errors.Is(err, ErrPlannerBusy) {
+		// The replica holding the lock heard about the same changes and replans after them
+		logger.Info("planner run skipped, another replica is replanning")
+		return
+	}
+	if err != nil {
+		logger.Error("planner run failed", slog.Any("error", err))
+		return
+	}
+	logger.Info("planner run created plan", slog.Int64("plan_id", plan.ID), slog.Int("changes", len(plan.Diff)))
+}
+
+// Replan computes a new plan, stores it with its diff against the previous plan,
+// audits it, refreshes the cache and publishes a plan.created event.
+// It waits for a replan running on another replica to finish.
+func (p *PlannerService) Replan(ctx context.Context, trigger string, planStart time.Time) (*models.Plan, error) {
+	return p.replanLocked(ctx, trigger, planStart, true)
+}
+
+// replanLocked runs a replan under the planner lock, without wait it returns ErrPlannerBusy
+// when another replica holds the lock
+func (p *PlannerService) replanLocked(ctx context.Context, trigger string, planStart time.Time, wait bool) (*models.Plan, error) {
+	// The span includes waiting for a concurrent run to finish
+	ctx, span := tracing.Start(ctx, "planner.replan", attribute.String("planner.trigger", trigger))
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	started := time.Now()
+	plan, err := p.replan(ctx, trigger, planStart, wait)
+	if errors.Is(err, ErrPlannerBusy) {
+		tracing.End(span, nil)
+		return nil, err
+	}
+	metrics.PlannerRun(time.Since(started), err)
+	if plan != nil {
+		span.SetAttributes(attribute.Int64("planner.plan_id", plan.ID), attribute.Int("planner.changes", len(plan.Diff)))
+	}
+	tracing.End(span, err)
+	return plan, err
+}
+
+// replan runs the planner phases, each in its own span: compute, diff, store and publish.
+// The transaction holding the planner lock commits with the stored plan.
+func (p *PlannerService) replan(ctx context.Context, trigger string, planStart time.Time, wait bool) (*models.Plan, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start plan: %w", err)
+	}
+	defer tx.Rollback()
+	if err := lockPlanner(ctx, tx, wait); err != nil {
+		return nil, err
+	}
+
+	computeCtx, span := tracing.Start(ctx, "planner.compute")
+	assignments, err := p.assignmentService.CreateAssignments(computeCtx, planStart)
+	tracing.End(span, err)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := p.diff(ctx, trigger, planStart, assignments)
+	if err != nil {
+		return nil, err
+	}
+
+	assignmentsJSON, staged, err := p.store(ctx, tx, plan)
+	if err != nil {
+		return nil, err
+	}
+
+	p.announce(ctx, plan, assignmentsJSON, staged)
+	return plan, nil
+}
+
+// lockPlanner takes the planner lock for the rest of tx, waiting for it or returning ErrPlannerBusy
+func lockPlanner(ctx context.Context, tx *sql.Tx, wait bool) error {
+	if wait {
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", plannerLockKey); err != nil {
+			return fmt.Errorf("failed to lock planner: %w", err)
+		}
+		return nil
+	}
+
+	var locked bool
+	if err := tx.QueryRowContext(ctx, "SELECT pg_try_advisory_xact_lock($1)", plannerLockKey).Scan(&locked); err != nil {
+		return fmt.Errorf("failed to lock planner: %w", err)
+	}
+	if !locked {
+		return ErrPlannerBusy
+	}
+	return nil
+}
+
+// diff builds the plan with its changes against the latest stored plan
+func (p *PlannerService) diff(ctx context.Context, trigger string, planStart time.Time, assignments []models.Assignment) (plan *models.Plan, err error) {
+	ctx, span := tracing.Start(ctx, "planner.diff")
+	defer func() { tracing.End(span, err) }()
+
+	previous, err := p.LatestPlan(ctx)
+	if err != nil && !errors.Is(err, ErrPlanNotFound) {
+		return nil, err
+	}
+	var previousAssignments []models.Assignment
+	if previous != nil {
+		previousAssignments = previous.Assignments
+	}
+
+	return &models.Plan{
+		Trigger:     trigger,
+		PlanStart:   planStart,
+		Assignments: assignments,
+		Diff:        DiffAssignments(previousAssignments, assignments),
+	}, nil
+}
+
+// store saves the plan, setting its ID, with its audit entry and its plan.created and
+// assignment.unassigned events, and commits tx. It returns the encoded assignments for the cache and the staged events.
+func (p *PlannerService) store(ctx context.Context, tx *sql.Tx, plan *models.Plan) (assignmentsJSON []byte, staged []events.Event, err error) {
+	ctx, span := tracing.Start(ctx, "planner.store")
+	defer func() { tracing.End(span, err) }()
+
+	assignmentsJSON, err = json.Marshal(plan.Assignments)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode plan: %w", err)
+	}
+	diffJSON, err := json.Marshal(plan.Diff)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode plan diff: %w", err)
+	}
+
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO plans (trigger, plan_start, assignments, diff) VALUES ($1, $2, $3, $4) RETURNING id, created_at",
+		plan.Trigger, plan.PlanStart, assignmentsJSON, diffJSON,
+	).Scan(&plan.ID, &plan.CreatedAt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to store plan: %w", err)
+	}
+	// The audited plan keeps every priority score, ETA and unassigned reason behind the decision
+	if err := RecordAudit(ctx, tx, AuditPlanComputed, "plan", strconv.FormatInt(plan.ID, 10), nil, plan); err != nil {
+		return nil, nil, err
+	}
+
+	event, err := p.publisher.Stage(ctx, tx, events.PlanCreated, plan)
+	if err != nil {
+		return nil, nil, err
+	}
+	staged = append(staged, event)
+	for _, change := range plan.Diff {
+		if change.Change != models.ChangeUnassigned {
+			continue
+		}
+		event, err := p.publisher.Stage(ctx, tx, events.AssignmentUnassigned, change)
+		if err != nil {
+			return nil, nil, err
+		}
+		staged = append(staged, event)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to store plan: %w", err)
+	}
+	return assignmentsJSON, staged, nil
+}
+
+// announce caches and broadcasts a stored plan, failures don't invalidate the stored plan
+func (p *PlannerService) announce(ctx context.Context, plan *models.Plan, assignmentsJSON []byte, staged []events.Event) {
+	ctx, span := tracing.Start(ctx, "planner.publish")
+	defer span.End()
+	logger := logging.FromContext(ctx).With(slog.Int64("plan_id", plan.ID))
+
+	if err := p.rdb.Set(ctx, PlanCacheKey, assignmentsJSON, planCacheTTL).Err(); err != nil {
+		logger.Error("failed to cache plan", slog.Any("error", err))
+	}
+	if err := p.publisher.Broadcast(ctx, staged...); err != nil {
+		logger.Error("failed to broadcast plan", slog.Any("error", err))
+	}
+}
+
+// LatestPlan fetches the most recent plan
+func (p *PlannerService) LatestPlan(ctx context.Context) (*models.Plan, error) {
+	return p.scanPlan(p.db.QueryRowContext(ctx, "SELECT id, trigger, plan_start, assignments, diff, created_at FROM plans ORDER BY id DESC LIMIT 1"))
+}
+
+// GetPlan fetches a plan by ID
+func (p *PlannerService) GetPlan(ctx context.Context, id int64) (*models.Plan, error) {
+	return p.scanPlan(p.db.QueryRowContext(ctx, "SELECT id, trigger, plan_start, assignments, diff, created_at FROM plans WHERE id = $1", id))
+}
+
+func (p *PlannerService) scanPlan(row *sql.Row) (*models.Plan, error) {
+	var plan models.Plan
+	var assignmentsJSON, diffJSON []byte
+	if err := row.Scan(&plan.ID, &plan.Trigger, &plan.PlanStart, &assignmentsJSON, &diffJSON, &plan.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPlanNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch plan: %w", err)
+	}
+
+	if err := json.Unmarshal(assignmentsJSON, &plan.Assignments); err != nil {
+		return nil, fmt.Errorf("failed to parse plan assignments: %w", err)
+	}
+	if err := json.Unmarshal(diffJSON, &plan.Diff); err != nil {
+		return nil, fmt.Errorf("failed to parse plan diff: %w", err)
+	}
+
+	return &plan, nil
+}
+
+// DiffAssignments lists the areas whose truck changed between two plans
+func DiffAssignments(previous, current []models.Assignment) []models.AssignmentChange {
+	before := make(map[string]string, len(previous))
+	for _, assignment := range previous {
+		before[assignment.AreaID] = assignment.TruckID
+	}
+
+	changes := []models.AssignmentChange{}
+	seen := make(map[string]bool, len(current))
+	for _, assignment := range current {
+		seen[assignment.AreaID] = true
+		previousTruck, existed := before[assignment.AreaID]
+
+		switch {
+		case previousTruck == assignment.TruckID:
+			continue
+		case assignment.TruckID == "" && previousTruck != "":
+			changes = append(changes, models.AssignmentChange{
+				AreaID:          assignment.AreaID,
+				Change:          models.ChangeUnassigned,
+				PreviousTruckID: previousTruck,
+				Description:     fmt.Sprintf("area %s unassigned from %s", assignment.AreaID, previousTruck),
+			})
+		case previousTruck == "":
+			if !existed && assignment.TruckID == "" {
+				continue
+			}
+			changes = append(changes, models.AssignmentChange{
+				AreaID:      assignment.AreaID,
+				Change:      models.ChangeAssigned,
+				TruckID:     assignment.TruckID,
+				Description: fmt.Sprintf("area %s assigned to %s", assignment.AreaID, assignment.TruckID),
+			})
+		default:
+			changes = append(changes, models.AssignmentChange{
+				AreaID:          assignment.AreaID,
+				Change:          models.ChangeReassigned,
+				PreviousTruckID: previousTruck,
+				TruckID:         assignment.TruckID,
+				Description:     fmt.Sprintf("area %s reassigned from %s to %s", assignment.AreaID, previousTruck, assignment.TruckID),
+			})
+		}
+	}
+
+	// Areas that disappeared from the plan entirely
+	for _, assignment := range previous {
+		if seen[assignment.AreaID] {
+			continue
+		}
+		changes = append(changes, models.AssignmentChange{
+			AreaID:          assignment.AreaID,
+			Change:          models.ChangeRemoved,
+			PreviousTruckID: assignment.TruckID,
+			Description:     fmt.Sprintf("area %s removed from the plan", assignment.AreaID),
+		})
+	}
+
+	return changes
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+	"workship-disaster-api/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestDiffAssignments(t *testing.T) {
+	previous := []models.Assignment{
+		{AreaID: "same", TruckID: "T1"},
+		{AreaID: "reassigned", TruckID: "T2"},
+		{AreaID: "unassigned", TruckID: "T3"},
+		{AreaID: "now-assigned"},
+		{AreaID: "still-open"},
+		{AreaID: "removed", TruckID: "T4"},
+	}
+	current := []models.Assignment{
+		{AreaID: "same", TruckID: "T1"},
+		{AreaID: "reassigned", TruckID: "T5"},
+		{AreaID: "unassigned"},
+		{AreaID: "now-assigned", TruckID: "T6"},
+		{AreaID: "still-open"},
+		{AreaID: "new", TruckID: "T7"},
+		{AreaID: "new-open"},
+	}
+
+	want := []models.AssignmentChange{
+		{AreaID: "reassigned", Change: models.ChangeReassigned, PreviousTruckID: "T2", TruckID: "T5", Description: "area reassigned reassigned from T2 to T5"},
+		{AreaID: "unassigned", Change: models.ChangeUnassigned, PreviousTruckID: "T3", Description: "area unassigned unassigned from T3"},
+		{AreaID: "now-assigned", Change: models.ChangeAssigned, TruckID: "T6", Description: "area now-assigned assigned to T6"},
+		{AreaID: "new", Change: models.ChangeAssigned, TruckID: "T7", Description: "area new assigned to T7"},
+		{AreaID: "removed", Change: models.ChangeRemoved, PreviousTruckID: "T4", Description: "area removed removed from the plan"},
+	}
+	if got := DiffAssignments(previous, current); !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffAssignments =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestDiffAssignmentsWithoutPreviousPlan(t *testing.T) {
+	got := DiffAssignments(nil, []models.Assignment{{AreaID: "A1", TruckID: "T1"}, {AreaID: "A2"}})
+	if len(got) != 1 || got[0].AreaID != "A1" || got[0].Change != models.ChangeAssigned {
+		t.Errorf("DiffAssignments = %+v, want only A1 assigned", got)
+	}
+	if got := DiffAssignments(nil, nil); got == nil || len(got) != 0 {
+		t.Errorf("DiffAssignments(nil, nil) = %#v, want an empty list for JSON", got)
+	}
+}
+
+// TestResetTimerDropsStaleTick covers a debounce timer that fired while a change was handled,
+// the leftover tick must not end the new quiet period early
+func TestResetTimerDropsStaleTick(t *testing.T) {
+	timer := time.NewTimer(time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+
+	resetTimer(timer, 100*time.Millisecond)
+
+	select {
+	case <-timer.C:
+		t.Fatal("timer ticked before the new quiet period ended")
+	case <-time.After(40 * time.Millisecond):
+	}
+	select {
+	case <-timer.C:
+	case <-time.After(time.Second):
+		t.Fatal("timer never ticked after reset")
+	}
+}
+
+func TestDebounceWaitCapsDelay(t *testing.T) {
+	planner := NewPlannerService(nil, nil, nil, nil, PlannerConfig{Debounce: 10 * time.Second, MaxDelay: time.Minute}, nil)
+	first := planStart
+
+	tests := []struct {
+		now  time.Time
+		want time.Duration
+	}{
+		{first, 10 * time.Second},
+		{first.Add(45 * time.Second), 10 * time.Second},
+		{first.Add(55 * time.Second), 5 * time.Second},
+		{first.Add(2 * time.Minute), 0},
+	}
+	for _, tt := range tests {
+		if got := planner.debounceWait(first, tt.now); got != tt.want {
+			t.Errorf("%v after the first change waits %v, want %v", tt.now.Sub(first), got, tt.want)
+		}
+	}
+}
+
+// TestNotifyChangeReachesEveryReplica checks changes go out over Redis, where every worker subscribes,
+// and stay local when Redis is down
+func TestNotifyChangeReachesEveryReplica(t *testing.T) {
+	server := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	planner := NewPlannerService(nil, rdb, nil, nil, PlannerConfig{}, nil)
+
+	other := rdb.Subscribe(context.Background(), plannerChannel)
+	defer other.Close()
+	if _, err := other.Receive(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	planner.NotifyChange("area.created")
+	select {
+	case msg := <-other.Channel():
+		if msg.Payload != "area.created" {
+			t.Errorf("payload %q", msg.Payload)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("other replica never heard of the change")
+	}
+	if len(planner.changes) != 0 {
+		t.Error("change queued locally as well, the subscription delivers it")
+	}
+
+	server.Close()
+	planner.NotifyChange("truck.created")
+	select {
+	case reason := <-planner.changes:
+		if reason != "truck.created" {
+			t.Errorf("queued %q", reason)
+		}
+	default:
+		t.Error("change lost while Redis is down")
+	}
+}
+
+// TestBackgroundReplanSkipsWhileLocked checks a worker leaves the replan to the replica holding the lock
+func TestBackgroundReplanSkipsWhileLocked(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	planner := NewPlannerService(db, nil, nil, nil, PlannerConfig{}, nil)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT pg_try_advisory_xact_lock\(\$1\)`).WithArgs(plannerLockKey).
+		WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(false))
+	mock.ExpectRollback()
+
+	if _, err := planner.replanLocked(context.Background(), "schedule", planStart, false); !errors.Is(err, ErrPlannerBusy) {
+		t.Errorf("replan = %v, want ErrPlannerBusy", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
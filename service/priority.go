@@ -0,0 +1,192 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"workship-disaster-api/models"
+)
+
+// PriorityConfig controls how areas are ranked before trucks are matched.
+// Each component is normalised to 0..1 and multiplied by its weight.
+type PriorityConfig struct {
+	UrgencyWeight       float64
+	PopulationWeight    float64
+	VulnerableWeight    float64
+	TimeRemainingWeight float64
+	WaitingWeight       float64
+
+	// PopulationScale is the population at which the population component reaches 0.5
+	PopulationScale float64
+	// VulnerableScale is the weighted vulnerable count at which the vulnerable component reaches 0.5
+	VulnerableScale float64
+	// VulnerableGroupWeights says how much one unit of a group counts, e.g. one hospital = 50 people.
+	// Groups not listed count as 1.
+	VulnerableGroupWeights map[string]float64
+	// TimeHorizon is how far away a deadline must be before it stops adding priority
+	TimeHorizon time.Duration
+	// WaitingScale is the waiting time at which the waiting component reaches 0.5
+	WaitingScale time.Duration
+}
+
+// DefaultPriorityConfig weights urgency highest, followed by the deadline
+func DefaultPriorityConfig() PriorityConfig {
+	return PriorityConfig{
+		UrgencyWeight:       0.4,
+		PopulationWeight:    0.15,
+		VulnerableWeight:    0.15,
+		TimeRemainingWeight: 0.2,
+		WaitingWeight:       0.1,
+		PopulationScale:     1000,
+		VulnerableScale:     50,
+		VulnerableGroupWeights: map[string]float64{
+			"hospitals": 50,
+		},
+		TimeHorizon:  24 * time.Hour,
+		WaitingScale: 6 * time.Hour,
+	}
+}
+
+// LoadPriorityConfig reads PRIORITY_* environment variables on top of the defaults
+func LoadPriorityConfig() (PriorityConfig, error) {
+	cfg := DefaultPriorityConfig()
+
+	// A zero weight turns its component off on purpose
+	weights := map[string]*float64{
+		"PRIORITY_WEIGHT_URGENCY":        &cfg.UrgencyWeight,
+		"PRIORITY_WEIGHT_POPULATION":     &cfg.PopulationWeight,
+		"PRIORITY_WEIGHT_VULNERABLE":     &cfg.VulnerableWeight,
+		"PRIORITY_WEIGHT_TIME_REMAINING": &cfg.TimeRemainingWeight,
+		"PRIORITY_WEIGHT_WAITING":        &cfg.WaitingWeight,
+	}
+	for key, target := range weights {
+		raw := os.Getenv(key)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil || value < 0 {
+			return cfg, fmt.Errorf("invalid %s: %q", key, raw)
+		}
+		*target = value
+	}
+
+	// A zero scale would silently zero its component, like a zero duration below
+	scales := map[string]*float64{
+		"PRIORITY_POPULATION_SCALE": &cfg.PopulationScale,
+		"PRIORITY_VULNERABLE_SCALE": &cfg.VulnerableScale,
+	}
+	for key, target := range scales {
+		raw := os.Getenv(key)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil || value <= 0 {
+			return cfg, fmt.Errorf("invalid %s: %q, must be positive", key, raw)
+		}
+		*target = value
+	}
+
+	durations := map[string]*time.Duration{
+		"PRIORITY_TIME_HORIZON":  &cfg.TimeHorizon,
+		"PRIORITY_WAITING_SCALE": &cfg.WaitingScale,
+	}
+	for key, target := range durations {
+		raw := os.Getenv(key)
+		if raw == "" {
+			continue
+		}
+		value, err := time.ParseDuration(raw)
+		if err != nil || value <= 0 {
+			return cfg, fmt.Errorf("invalid %s: %q", key, raw)
+		}
+		*target = value
+	}
+
+	// PRIORITY_VULNERABLE_GROUPS=hospitals=50,elderly=1
+	if raw := os.Getenv("PRIORITY_VULNERABLE_GROUPS"); raw != "" {
+		cfg.VulnerableGroupWeights = map[string]float64{}
+		for _, pair := range strings.Split(raw, ",") {
+			name, weight, found := strings.Cut(strings.TrimSpace(pair), "=")
+			value, err := strconv.ParseFloat(weight, 64)
+			if !found || name == "" || err != nil || value < 0 {
+				return cfg, fmt.Errorf("invalid PRIORITY_VULNERABLE_GROUPS entry: %q", pair)
+			}
+			cfg.VulnerableGroupWeights[name] = value
+		}
+	}
+
+	return cfg, nil
+}
+
+// Score computes the weighted priority of an area at planStart
+func (c PriorityConfig) Score(area AreaData, planStart time.Time) models.PriorityScore {
+	urgency := float64(area.Urgency) / 5
+
+	population := saturate(float64(area.Population), c.PopulationScale)
+
+	var vulnerableCount float64
+	for group, count := range area.VulnerableGroups {
+		weight, ok := c.VulnerableGroupWeights[group]
+		if !ok {
+			weight = 1
+		}
+		vulnerableCount += float64(count) * weight
+	}
+	vulnerable := saturate(vulnerableCount, c.VulnerableScale)
+
+	// Closer deadlines rank higher, overdue areas get the full component
+	_, latest := area.ArrivalWindow(planStart)
+	timeRemaining := 1 - latest.Sub(planStart).Hours()/c.TimeHorizon.Hours()
+	timeRemaining = math.Max(0, math.Min(1, timeRemaining))
+
+	var waiting float64
+	if !area.CreatedAt.IsZero() && planStart.After(area.CreatedAt) {
+		waiting = saturate(planStart.Sub(area.CreatedAt).Hours(), c.WaitingScale.Hours())
+	}
+
+	score := models.PriorityScore{
+		Urgency:       round(c.UrgencyWeight * urgency),
+		Population:    round(c.PopulationWeight * population),
+		Vulnerable:    round(c.VulnerableWeight * vulnerable),
+		TimeRemaining: round(c.TimeRemainingWeight * timeRemaining),
+		Waiting:       round(c.WaitingWeight * waiting),
+	}
+	score.Total = round(score.Urgency + score.Population + score.Vulnerable + score.TimeRemaining + score.Waiting)
+
+	return score
+}
+
+// RankAreas scores every area and returns them highest priority first.
+// Ties keep the incoming order, which GetAllAreas makes deterministic.
+func (c PriorityConfig) RankAreas(areas []AreaData, planStart time.Time) ([]AreaData, map[string]models.PriorityScore) {
+	scores := make(map[string]models.PriorityScore, len(areas))
+	for _, area := range areas {
+		scores[area.ID] = c.Score(area, planStart)
+	}
+
+	ranked := make([]AreaData, len(areas))
+	copy(ranked, areas)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return scores[ranked[i].ID].Total > scores[ranked[j].ID].Total
+	})
+
+	return ranked, scores
+}
+
+// saturate maps 0..inf to 0..1, reaching 0.5 at scale
+func saturate(value, scale float64) float64 {
+	if value <= 0 || scale <= 0 {
+		return 0
+	}
+	return value / (value + scale)
+}
+
+func round(value float64) float64 {
+	return math.Round(value*10000) / 10000
+}
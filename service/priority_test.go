@@ -0,0 +1,163 @@
+package service
+
+import (
+	"testing"
+	"time"
+	"workship-disaster-api/models"
+)
+
+var planStart = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func at(offset time.Duration) *time.Time {
+	t := planStart.Add(offset)
+	return &t
+}
+
+func TestSaturate(t *testing.T) {
+	tests := []struct {
+		value, scale, want float64
+	}{
+		{0, 1000, 0},
+		{1000, 1000, 0.5},
+		{3000, 1000, 0.75},
+		{-5, 10, 0},
+		{10, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := saturate(tt.value, tt.scale); got != tt.want {
+			t.Errorf("saturate(%v, %v) = %v, want %v", tt.value, tt.scale, got, tt.want)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	// With the defaults every component below sits at half its range unless the case says otherwise
+	base := AreaData{
+		ID:               "A1",
+		Urgency:          5,
+		Population:       1000,
+		VulnerableGroups: map[string]int{"hospitals": 1},
+		LatestArrival:    at(12 * time.Hour),
+		CreatedAt:        planStart.Add(-6 * time.Hour),
+	}
+
+	tests := []struct {
+		name   string
+		config func(*PriorityConfig)
+		area   func(*AreaData)
+		want   models.PriorityScore
+	}{
+		{
+			name: "every component at half range",
+			want: models.PriorityScore{Urgency: 0.4, Population: 0.075, Vulnerable: 0.075, TimeRemaining: 0.1, Waiting: 0.05, Total: 0.7},
+		},
+		{
+			name: "unlisted vulnerable groups count one each",
+			area: func(a *AreaData) { a.VulnerableGroups = map[string]int{"elderly": 25} },
+			want: models.PriorityScore{Urgency: 0.4, Population: 0.075, Vulnerable: 0.05, TimeRemaining: 0.1, Waiting: 0.05, Total: 0.675},
+		},
+		{
+			name: "overdue deadline takes the full component",
+			area: func(a *AreaData) { a.LatestArrival = at(-time.Hour) },
+			want: models.PriorityScore{Urgency: 0.4, Population: 0.075, Vulnerable: 0.075, TimeRemaining: 0.2, Waiting: 0.05, Total: 0.8},
+		},
+		{
+			name: "deadline beyond the horizon adds nothing",
+			area: func(a *AreaData) { a.LatestArrival = at(48 * time.Hour) },
+			want: models.PriorityScore{Urgency: 0.4, Population: 0.075, Vulnerable: 0.075, TimeRemaining: 0, Waiting: 0.05, Total: 0.6},
+		},
+		{
+			name: "time constraint stands in for a missing latest arrival",
+			area: func(a *AreaData) { a.LatestArrival, a.TimeConstraint = nil, 360 },
+			want: models.PriorityScore{Urgency: 0.4, Population: 0.075, Vulnerable: 0.075, TimeRemaining: 0.15, Waiting: 0.05, Total: 0.75},
+		},
+		{
+			name: "areas created after the plan start haven't waited",
+			area: func(a *AreaData) { a.CreatedAt = planStart.Add(time.Hour) },
+			want: models.PriorityScore{Urgency: 0.4, Population: 0.075, Vulnerable: 0.075, TimeRemaining: 0.1, Waiting: 0, Total: 0.65},
+		},
+		{
+			name:   "a zero weight turns its component off",
+			config: func(c *PriorityConfig) { c.PopulationWeight = 0 },
+			want:   models.PriorityScore{Urgency: 0.4, Population: 0, Vulnerable: 0.075, TimeRemaining: 0.1, Waiting: 0.05, Total: 0.625},
+		},
+		{
+			name:   "a larger scale lowers the component",
+			config: func(c *PriorityConfig) { c.PopulationScale = 3000 },
+			want:   models.PriorityScore{Urgency: 0.4, Population: 0.0375, Vulnerable: 0.075, TimeRemaining: 0.1, Waiting: 0.05, Total: 0.6625},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultPriorityConfig()
+			if tt.config != nil {
+				tt.config(&config)
+			}
+			area := base
+			if tt.area != nil {
+				tt.area(&area)
+			}
+			if got := config.Score(area, planStart); got != tt.want {
+				t.Errorf("Score = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRankAreas(t *testing.T) {
+	deadline := at(12 * time.Hour)
+	areas := []AreaData{
+		{ID: "low", Urgency: 1, LatestArrival: deadline},
+		{ID: "tie-first", Urgency: 3, LatestArrival: deadline},
+		{ID: "crowded", Urgency: 3, Population: 500, LatestArrival: deadline},
+		{ID: "tie-second", Urgency: 3, LatestArrival: deadline},
+		{ID: "urgent", Urgency: 5, LatestArrival: deadline},
+	}
+
+	ranked, scores := DefaultPriorityConfig().RankAreas(areas, planStart)
+
+	want := []string{"urgent", "crowded", "tie-first", "tie-second", "low"}
+	for i, area := range ranked {
+		if area.ID != want[i] {
+			t.Fatalf("ranked %v, want %v", ids(ranked), want)
+		}
+	}
+	if len(scores) != len(areas) {
+		t.Errorf("got %d scores, want one per area", len(scores))
+	}
+	if areas[0].ID != "low" {
+		t.Error("RankAreas reordered its input")
+	}
+}
+
+func TestLoadPriorityConfigScales(t *testing.T) {
+	tests := []struct {
+		key, value string
+		wantErr    bool
+	}{
+		{"PRIORITY_POPULATION_SCALE", "0", true},
+		{"PRIORITY_VULNERABLE_SCALE", "0", true},
+		{"PRIORITY_POPULATION_SCALE", "-10", true},
+		{"PRIORITY_POPULATION_SCALE", "500", false},
+		{"PRIORITY_WEIGHT_POPULATION", "0", false},
+		{"PRIORITY_WEIGHT_POPULATION", "-1", true},
+		{"PRIORITY_TIME_HORIZON", "0s", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+			_, err := LoadPriorityConfig()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadPriorityConfig() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func ids(areas []AreaData) []string {
+	out := make([]string, len(areas))
+	for i, area := range areas {
+		out[i] = area.ID
+	}
+	return out
+}
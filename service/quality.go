@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+	"workship-disaster-api/models"
+	"workship-disaster-api/tracing"
+
+	"github.com/lib/pq"
+)
+
+// DataQualityService finds references between areas and trucks that can't be planned with
+type DataQualityService struct {
+	db *sql.DB
+}
+
+func NewDataQualityService(db *sql.DB) *DataQualityService {
+	return &DataQualityService{db: db}
+}
+
+// Report lists travel times to unregistered areas, areas no truck has a route to,
+// and resources areas need that no truck carries
+func (s *DataQualityService) Report(ctx context.Context) (report models.DataQualityReport, err error) {
+	ctx, span := tracing.Start(ctx, "DataQualityService.Report")
+	defer func() { tracing.End(span, err) }()
+
+	report = models.DataQualityReport{
+		CheckedAt:          time.Now().UTC(),
+		OrphanTravel:       []models.OrphanTravel{},
+		UnreachableAreas:   []models.UnreachableArea{},
+		UncarriedResources: []models.UncarriedResource{},
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT truck_id, area_id, minutes FROM truck_area_travel_unresolved ORDER BY truck_id, area_id")
+	if err != nil {
+		return report, fmt.Errorf("failed to fetch orphan travel times: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var orphan models.OrphanTravel
+		if err := rows.Scan(&orphan.TruckID, &orphan.AreaID, &orphan.Minutes); err != nil {
+			return report, fmt.Errorf("failed to parse orphan travel time: %w", err)
+		}
+		report.OrphanTravel = append(report.OrphanTravel, orphan)
+	}
+	if err := rows.Err(); err != nil {
+		return report, fmt.Errorf("failed to fetch orphan travel times: %w", err)
+	}
+
+	rows, err = s.db.QueryContext(ctx, `
+		SELECT area_id, urgency_level FROM areas
+		WHERE NOT EXISTS (SELECT 1 FROM truck_area_travel t WHERE t.area_id = areas.area_id)
+		ORDER BY urgency_level DESC, area_id`)
+	if err != nil {
+		return report, fmt.Errorf("failed to fetch unreachable areas: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var area models.UnreachableArea
+		if err := rows.Scan(&area.AreaID, &area.UrgencyLevel); err != nil {
+			return report, fmt.Errorf("failed to parse unreachable area: %w", err)
+		}
+		report.UnreachableAreas = append(report.UnreachableAreas, area)
+	}
+	if err := rows.Err(); err != nil {
+		return report, fmt.Errorf("failed to fetch unreachable areas: %w", err)
+	}
+
+	rows, err = s.db.QueryContext(ctx, `
+		SELECT resource, sum(quantity), array_agg(area_id ORDER BY area_id) FROM area_requirements r
+		WHERE quantity > 0 AND NOT EXISTS (SELECT 1 FROM truck_inventory i WHERE i.resource = r.resource AND i.quantity > 0)
+		GROUP BY resource ORDER BY resource`)
+	if err != nil {
+		return report, fmt.Errorf("failed to fetch uncarried resources: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var resource models.UncarriedResource
+		if err := rows.Scan(&resource.Resource, &resource.Required, pq.Array(&resource.AreaIDs)); err != nil {
+			return report, fmt.Errorf("failed to parse uncarried resource: %w", err)
+		}
+		report.UncarriedResources = append(report.UncarriedResources, resource)
+	}
+	if err := rows.Err(); err != nil {
+		return report, fmt.Errorf("failed to fetch uncarried resources: %w", err)
+	}
+
+	return report, nil
+}
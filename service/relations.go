@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"workship-disaster-api/apperr"
+
+	"github.com/lib/pq"
+)
+
+// execer is a *sql.DB or *sql.Tx, the rows of a record are checked and written together with the record
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// saveAreaRequirements replaces the resources an area needs, estimated lists those derived by the demand estimator
+func saveAreaRequirements(ctx context.Context, tx execer, areaID string, resources map[string]int, estimated []string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM area_requirements WHERE area_id = $1", areaID); err != nil {
+		return fmt.Errorf("failed to save area requirements: %w", err)
+	}
+	if len(resources) == 0 {
+		return nil
+	}
+	names, quantities := splitCounts(resources)
+	// A nil slice is sent as NULL and ANY(NULL) is NULL, not false
+	if estimated == nil {
+		estimated = []string{}
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO area_requirements (area_id, resource, quantity, estimated) SELECT $1, r.resource, r.quantity, COALESCE(r.resource = ANY($4::text[]), false) FROM unnest($2::text[], $3::int[]) AS r(resource, quantity)",
+		areaID, pq.Array(names), pq.Array(quantities), pq.Array(estimated),
+	); err != nil {
+		return fmt.Errorf("failed to save area requirements: %w", err)
+	}
+	return nil
+}
+
+// saveTruckInventory replaces the resources a truck carries
+func saveTruckInventory(ctx context.Context, tx execer, truckID string, resources map[string]int) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM truck_inventory WHERE truck_id = $1", truckID); err != nil {
+		return fmt.Errorf("failed to save truck inventory: %w", err)
+	}
+	if len(resources) == 0 {
+		return nil
+	}
+	names, quantities := splitCounts(resources)
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO truck_inventory (truck_id, resource, quantity) SELECT $1, r.resource, r.quantity FROM unnest($2::text[], $3::int[]) AS r(resource, quantity)",
+		truckID, pq.Array(names), pq.Array(quantities),
+	); err != nil {
+		return fmt.Errorf("failed to save truck inventory: %w", err)
+	}
+	return nil
+}
+
+// saveTruckTravel replaces the travel times of a truck. Times to areas that aren't registered
+// yet are kept apart and move over when the area is created, see migration 012.
+func saveTruckTravel(ctx context.Context, tx execer, truckID string, travel map[string]int) error {
+	for _, table := range []string{"truck_area_travel", "truck_area_travel_unresolved"} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE truck_id = $1", truckID); err != nil {
+			return fmt.Errorf("failed to save truck travel times: %w", err)
+		}
+	}
+	if len(travel) == 0 {
+		return nil
+	}
+	areaIDs, minutes := splitCounts(travel)
+	for table, condition := range map[string]string{
+		"truck_area_travel":            "EXISTS",
+		"truck_area_travel_unresolved": "NOT EXISTS",
+	} {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO "+table+" (truck_id, area_id, minutes) SELECT $1, tr.area_id, tr.minutes FROM unnest($2::text[], $3::int[]) AS tr(area_id, minutes)"+
+				" WHERE "+condition+" (SELECT 1 FROM areas WHERE areas.area_id = tr.area_id)",
+			truckID, pq.Array(areaIDs), pq.Array(minutes),
+		); err != nil {
+			return fmt.Errorf("failed to save truck travel times: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkTravelAreas looks up the areas a truck lists travel times to. Unknown areas are
+// returned as warnings, or rejected with UNKNOWN_AREA when policy is UnknownAreaReject.
+func checkTravelAreas(ctx context.Context, tx execer, policy string, travel map[string]int) ([]apperr.FieldError, error) {
+	if len(travel) == 0 || policy == UnknownAreaIgnore {
+		return nil, nil
+	}
+	areaIDs, _ := splitCounts(travel)
+	rows, err := tx.QueryContext(ctx,
+		"SELECT id FROM unnest($1::text[]) AS id WHERE NOT EXISTS (SELECT 1 FROM areas WHERE areas.area_id = id) ORDER BY id",
+		pq.Array(areaIDs),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check travel time areas: %w", err)
+	}
+	defer rows.Close()
+
+	var unknown []apperr.FieldError
+	for rows.Next() {
+		var areaID string
+		if err := rows.Scan(&areaID); err != nil {
+			return nil, fmt.Errorf("failed to check travel time areas: %w", err)
+		}
+		unknown = append(unknown, apperr.FieldError{Field: "travelTimeToArea[" + areaID + "]", Rule: "area", Message: "is not a known area"})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to check travel time areas: %w", err)
+	}
+
+	if len(unknown) > 0 && policy == UnknownAreaReject {
+		return nil, &apperr.Error{Status: http.StatusBadRequest, Code: apperr.CodeUnknownArea, Detail: "The travel times refer to areas that don't exist", Fields: unknown}
+	}
+	return unknown, nil
+}
+
+// recordDelivery takes delivered units off the truck's inventory and the area's requirements, neither goes below zero
+func recordDelivery(ctx context.Context, tx execer, areaID, truckID string, delivered map[string]int) error {
+	if len(delivered) == 0 {
+		return nil
+	}
+	names, quantities := splitCounts(delivered)
+	for _, target := range []struct{ table, key, id string }{
+		{"truck_inventory", "truck_id", truckID},
+		{"area_requirements", "area_id", areaID},
+	} {
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE "+target.table+" t SET quantity = GREATEST(t.quantity - d.quantity, 0) FROM unnest($2::text[], $3::int[]) AS d(resource, quantity)"+
+				" WHERE t."+target.key+" = $1 AND t.resource = d.resource",
+			target.id, pq.Array(names), pq.Array(quantities),
+		); err != nil {
+			return fmt.Errorf("failed to record delivery: %w", err)
+		}
+	}
+	return nil
+}
+
+// splitCounts turns a map into parallel arrays sorted by key, for unnest
+func splitCounts(counts map[string]int) ([]string, []int64) {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	values := make([]int64, len(keys))
+	for i, key := range keys {
+		values[i] = int64(counts[key])
+	}
+	return keys, values
+}
+
+// NullIfEmpty stores empty optional strings as NULL
+func NullIfEmpty(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+func nullIfZero(value int) interface{} {
+	if value == 0 {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"workship-disaster-api/apperr"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// TestCheckTravelAreas checks travel times to unknown areas are warned about, rejected or
+// skipped without a lookup depending on the policy
+func TestCheckTravelAreas(t *testing.T) {
+	travel := map[string]int{"A1": 30, "A9": 45, "B7": 60}
+	tests := []struct {
+		policy   string
+		lookup   bool
+		warnings []string
+		reject   bool
+	}{
+		{UnknownAreaWarn, true, []string{"travelTimeToArea[A9]", "travelTimeToArea[B7]"}, false},
+		{UnknownAreaReject, true, nil, true},
+		{UnknownAreaIgnore, false, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			if tt.lookup {
+				mock.ExpectQuery(`SELECT id FROM unnest\(\$1::text\[\]\) AS id WHERE NOT EXISTS`).
+					WithArgs(textArray(`{"A1","A9","B7"}`)).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("A9").AddRow("B7"))
+			}
+
+			warnings, err := checkTravelAreas(context.Background(), db, tt.policy, travel)
+			var appErr *apperr.Error
+			switch {
+			case tt.reject:
+				if !errors.As(err, &appErr) || appErr.Code != apperr.CodeUnknownArea || len(appErr.Fields) != 2 {
+					t.Fatalf("checkTravelAreas = %v, want UNKNOWN_AREA listing both areas", err)
+				}
+			case err != nil:
+				t.Fatalf("checkTravelAreas: %v", err)
+			}
+
+			var fields []string
+			for _, warning := range warnings {
+				fields = append(fields, warning.Field)
+			}
+			if !equalStrings(fields, tt.warnings) {
+				t.Errorf("warnings = %v, want %v", fields, tt.warnings)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// TestCheckTravelAreasAllKnown checks even the reject policy passes when every area exists
+func TestCheckTravelAreasAllKnown(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.ExpectQuery("SELECT id FROM unnest").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	warnings, err := checkTravelAreas(context.Background(), db, UnknownAreaReject, map[string]int{"A1": 30})
+	if err != nil || len(warnings) != 0 {
+		t.Errorf("checkTravelAreas = %v, %v, want no warnings", warnings, err)
+	}
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// LoadResourceTypes reads RESOURCE_TYPES, a comma separated list such as "water,food,medicine".
+// An empty list accepts any resource type.
+func LoadResourceTypes() []string {
+	var types []string
+	for _, name := range strings.Split(os.Getenv("RESOURCE_TYPES"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			types = append(types, name)
+		}
+	}
+	return types
+}
+
+// RegisterResourceValidation enables the "resource" binding rule, which rejects resource
+// types outside the given list, so typos like "watter" don't create resources no truck carries
+func RegisterResourceValidation(types []string) {
+	known := make(map[string]bool, len(types))
+	for _, name := range types {
+		known[name] = true
+	}
+
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterValidation("resource", func(fl validator.FieldLevel) bool {
+			return len(known) == 0 || known[fl.Field().String()]
+		})
+	}
+}
+
+func init() {
+	// The rule must exist before any request is bound, until configured it accepts every type
+	RegisterResourceValidation(nil)
+}
@@ -1,51 +1,346 @@
 package service
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"os"
+	"time"
+	"workship-disaster-api/apperr"
+	"workship-disaster-api/events"
+	"workship-disaster-api/models"
+	"workship-disaster-api/tracing"
 )
 
 type TruckData struct {
 	ID                 string
 	AvailableResources map[string]int
 	TravelTimeToArea   map[string]int
+	Status             string
+	Shift              *ShiftWindow
+	AvailableFrom      *time.Time
+	Latitude           *float64
+	Longitude          *float64
+}
+
+// ShiftWindow is a daily driver shift stored as offsets from midnight, End may be before Start
+// for shifts that cross midnight.
+type ShiftWindow struct {
+	Start time.Duration
+	End   time.Duration
+	// Location is the time zone the shift clock times are in, nil uses the departure's zone
+	Location *time.Location
+}
+
+// ParseShiftWindow parses "HH:MM" or "HH:MM:SS" shift boundaries
+func ParseShiftWindow(start, end string) (*ShiftWindow, error) {
+	s, err := parseClock(start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid shift start %q: %w", start, err)
+	}
+	e, err := parseClock(end)
+	if err != nil {
+		return nil, fmt.Errorf("invalid shift end %q: %w", end, err)
+	}
+	return &ShiftWindow{Start: s, End: e}, nil
+}
+
+// String formats the shift as "HH:MM-HH:MM"
+func (w ShiftWindow) String() string {
+	clock := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return clock(w.Start) + "-" + clock(w.End)
+}
+
+func parseClock(value string) (time.Duration, error) {
+	layout := "15:04:05"
+	if len(value) == len("15:04") {
+		layout = "15:04"
+	}
+	t, err := time.Parse(layout, value)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second, nil
+}
+
+// fit returns the earliest departure at or after depart that lets a trip of the given length
+// finish inside a single shift.
+func (w ShiftWindow) fit(depart time.Time, travel time.Duration) (time.Time, bool) {
+	length := w.End - w.Start
+	if length <= 0 {
+		length += 24 * time.Hour
+	}
+	if travel > length {
+		return time.Time{}, false
+	}
+
+	if w.Location != nil {
+		depart = depart.In(w.Location)
+	}
+	day := time.Date(depart.Year(), depart.Month(), depart.Day(), 0, 0, 0, 0, depart.Location())
+	// Yesterday's shift covers overnight shifts that are still running
+	for offset := -1; offset <= 1; offset++ {
+		start := day.AddDate(0, 0, offset).Add(w.Start)
+		end := start.Add(length)
+		if depart.After(end.Add(-travel)) {
+			continue
+		}
+		if depart.Before(start) {
+			return start, true
+		}
+		return depart, true
+	}
+
+	return time.Time{}, false
+}
+
+// Departure returns when the truck can leave for a trip of the given length, taking status,
+// availableFrom and the driver shift into account. ok is false when the truck can't go at all.
+func (t TruckData) Departure(planStart time.Time, travel time.Duration) (depart time.Time, ok bool) {
+	switch t.Status {
+	case models.TruckStatusMaintenance, models.TruckStatusOffline:
+		return time.Time{}, false
+	case models.TruckStatusBusy:
+		// A busy truck is only usable once we know when it frees up
+		if t.AvailableFrom == nil {
+			return time.Time{}, false
+		}
+	}
+
+	depart = planStart
+	if t.AvailableFrom != nil && t.AvailableFrom.After(depart) {
+		depart = *t.AvailableFrom
+	}
+
+	if t.Shift == nil {
+		return depart, true
+	}
+	return t.Shift.fit(depart, travel)
+}
+
+// Policies for travel times to areas that aren't registered
+const (
+	UnknownAreaWarn   = "warn"
+	UnknownAreaReject = "reject"
+	UnknownAreaIgnore = "ignore"
+)
+
+// LoadUnknownAreaPolicy reads UNKNOWN_AREA_POLICY, by default unknown areas are stored and reported as warnings
+func LoadUnknownAreaPolicy() (string, error) {
+	switch policy := os.Getenv("UNKNOWN_AREA_POLICY"); policy {
+	case "":
+		return UnknownAreaWarn, nil
+	case UnknownAreaWarn, UnknownAreaReject, UnknownAreaIgnore:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid UNKNOWN_AREA_POLICY: %q, expected warn, reject or ignore", policy)
+	}
+}
+
+// LoadShiftLocation reads SHIFT_TIMEZONE, the IANA zone driver shifts are given in, by default Asia/Bangkok.
+// Shifts don't follow the server's zone, containers usually run in UTC.
+func LoadShiftLocation() (*time.Location, error) {
+	name := os.Getenv("SHIFT_TIMEZONE")
+	if name == "" {
+		name = "Asia/Bangkok"
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SHIFT_TIMEZONE: %q: %w", name, err)
+	}
+	return location, nil
 }
 
 type TruckService struct {
-	db *sql.DB
+	db            *sql.DB
+	unknownAreas  string
+	shiftLocation *time.Location
+	publisher     *events.Publisher
 }
 
-func NewTruckService(db *sql.DB) *TruckService {
-	return &TruckService{db: db}
+// NewTruckService handles travel times to unknown areas according to the unknownAreas policy,
+// shift windows are read in shiftLocation
+func NewTruckService(db *sql.DB, unknownAreas string, shiftLocation *time.Location, publisher *events.Publisher) *TruckService {
+	return &TruckService{db: db, unknownAreas: unknownAreas, shiftLocation: shiftLocation, publisher: publisher}
 }
 
 // GetAllTrucks fetches all trucks from the database
-func (s *TruckService) GetAllTrucks() ([]TruckData, error) {
-	truckRows, err := s.db.Query("SELECT truck_id, available_resources, travel_time_to_area FROM trucks")
+func (s *TruckService) GetAllTrucks(ctx context.Context) (trucks []TruckData, err error) {
+	ctx, span := tracing.Start(ctx, "TruckService.GetAllTrucks")
+	defer func() { tracing.End(span, err) }()
+
+	truckRows, err := s.db.QueryContext(ctx, "SELECT truck_id, truck_inventory_json(truck_id), truck_travel_json(truck_id), status, shift_start, shift_end, available_from, latitude, longitude FROM trucks")
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch trucks: %w", err)
 	}
 	defer truckRows.Close()
 
-	var trucks []TruckData
+	var resourcesJSON, travelTimeJSON [][]byte
+	var shiftStarts, shiftEnds []sql.NullString
 	for truckRows.Next() {
 		var truck TruckData
-		var resourcesJSON, travelTimeJSON []byte
-		if err := truckRows.Scan(&truck.ID, &resourcesJSON, &travelTimeJSON); err != nil {
+		var resources, travelTime []byte
+		var shiftStart, shiftEnd sql.NullString
+		if err := truckRows.Scan(&truck.ID, &resources, &travelTime, &truck.Status, &shiftStart, &shiftEnd, &truck.AvailableFrom, &truck.Latitude, &truck.Longitude); err != nil {
 			return nil, fmt.Errorf("failed to parse truck data: %w", err)
 		}
+		trucks = append(trucks, truck)
+		resourcesJSON = append(resourcesJSON, resources)
+		travelTimeJSON = append(travelTimeJSON, travelTime)
+		shiftStarts = append(shiftStarts, shiftStart)
+		shiftEnds = append(shiftEnds, shiftEnd)
+	}
+	if err := truckRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to fetch trucks: %w", err)
+	}
 
-		if err := json.Unmarshal(resourcesJSON, &truck.AvailableResources); err != nil {
+	// Decoding gets its own span so slow JSON shows apart from the query
+	_, decode := tracing.Start(ctx, "trucks.decode")
+	defer decode.End()
+	for i := range trucks {
+		truck := &trucks[i]
+		if err := json.Unmarshal(resourcesJSON[i], &truck.AvailableResources); err != nil {
 			return nil, fmt.Errorf("failed to parse truck resources: %w", err)
 		}
 
-		if err := json.Unmarshal(travelTimeJSON, &truck.TravelTimeToArea); err != nil {
+		if err := json.Unmarshal(travelTimeJSON[i], &truck.TravelTimeToArea); err != nil {
 			return nil, fmt.Errorf("failed to parse truck travel times: %w", err)
 		}
 
-		trucks = append(trucks, truck)
+		if shiftStarts[i].Valid && shiftEnds[i].Valid {
+			if truck.Shift, err = ParseShiftWindow(shiftStarts[i].String, shiftEnds[i].String); err != nil {
+				return nil, fmt.Errorf("failed to parse truck shift: %w", err)
+			}
+			truck.Shift.Location = s.shiftLocation
+		}
 	}
 
 	return trucks, nil
 }
+
+// CreateTruck stores a new truck with its inventory, travel times, audit entry and truck.created event in one transaction.
+// An existing truck ID fails with a unique violation. Travel times to unknown areas are
+// returned as warnings or rejected, depending on the unknown area policy.
+func (s *TruckService) CreateTruck(ctx context.Context, req models.CreateTruckRequest) (warnings []apperr.FieldError, err error) {
+	ctx, span := tracing.Start(ctx, "TruckService.CreateTruck")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create truck: %w", err)
+	}
+	defer tx.Rollback()
+
+	if warnings, err = checkTravelAreas(ctx, tx, s.unknownAreas, req.TravelTimeToArea); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO trucks (truck_id, status, shift_start, shift_end, available_from, latitude, longitude) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		req.TruckID, req.Status, NullIfEmpty(req.ShiftStart), NullIfEmpty(req.ShiftEnd), req.AvailableFrom, req.Latitude, req.Longitude,
+	); err != nil {
+		return nil, fmt.Errorf("failed to create truck: %w", err)
+	}
+	if err := saveTruckRelations(ctx, tx, req); err != nil {
+		return nil, err
+	}
+	if err := RecordAudit(ctx, tx, AuditCreate, "truck", req.TruckID, nil, req); err != nil {
+		return nil, err
+	}
+	event, err := s.publisher.Stage(ctx, tx, events.TruckCreated, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to create truck: %w", err)
+	}
+	broadcast(ctx, s.publisher, event)
+	return warnings, nil
+}
+
+// truckSorts are the fields GET /api/trucks may be sorted by
+var truckSorts = map[string]sortColumn{
+	"truckId":   {"truck_id", "text"},
+	"status":    {"status", "text"},
+	"createdAt": {"created_at", "timestamptz"},
+}
+
+// ListTrucks returns one page of trucks matching filter, filtering, sorting and paging all run in SQL
+func (s *TruckService) ListTrucks(ctx context.Context, filter models.TruckFilter) (page models.Page[models.Truck], err error) {
+	ctx, span := tracing.Start(ctx, "TruckService.ListTrucks")
+	defer func() { tracing.End(span, err) }()
+
+	sort, err := parseSort(filter.Sort, truckSorts, "truckId")
+	if err != nil {
+		return page, err
+	}
+	cursor, err := decodeCursor(filter.Cursor, sort)
+	if err != nil {
+		return page, err
+	}
+	limit := filter.Limit
+	if limit == 0 {
+		limit = defaultPageSize
+	}
+
+	var q listQuery
+	if filter.Status != "" {
+		q.where("status = $%d", filter.Status)
+	}
+	for _, resource := range filter.HasResource {
+		q.where("EXISTS (SELECT 1 FROM truck_inventory i WHERE i.truck_id = trucks.truck_id AND i.resource = $%d AND i.quantity > 0)", resource)
+	}
+	if filter.RouteToArea != "" {
+		q.where("EXISTS (SELECT 1 FROM truck_area_travel t WHERE t.truck_id = trucks.truck_id AND t.area_id = $%d)", filter.RouteToArea)
+	}
+	if filter.CreatedSince != nil {
+		q.where("created_at >= $%d", *filter.CreatedSince)
+	}
+
+	if err := s.db.QueryRowContext(ctx, "SELECT count(*) FROM trucks"+q.clause(), q.args...).Scan(&page.Total); err != nil {
+		return page, fmt.Errorf("failed to count trucks: %w", err)
+	}
+
+	if cursor != nil {
+		sort.after(&q, "truck_id", *cursor)
+	}
+	q.args = append(q.args, limit+1)
+	query := "SELECT truck_id, truck_inventory_json(truck_id), truck_travel_json(truck_id), status, to_char(shift_start, 'HH24:MI'), to_char(shift_end, 'HH24:MI'), available_from, latitude, longitude, created_at, " + sort.value() +
+		" FROM trucks" + q.clause() + sort.orderBy("truck_id") + fmt.Sprintf(" LIMIT $%d", len(q.args))
+
+	rows, err := s.db.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		return page, fmt.Errorf("failed to fetch trucks: %w", err)
+	}
+	defer rows.Close()
+
+	page.Items = []models.Truck{}
+	var lastValue string
+	for rows.Next() {
+		var truck models.Truck
+		var resources, travelTime []byte
+		var shiftStart, shiftEnd sql.NullString
+		var sortValue string
+		if err := rows.Scan(&truck.TruckID, &resources, &travelTime, &truck.Status, &shiftStart, &shiftEnd, &truck.AvailableFrom, &truck.Latitude, &truck.Longitude, &truck.CreatedAt, &sortValue); err != nil {
+			return page, fmt.Errorf("failed to parse truck data: %w", err)
+		}
+		if len(page.Items) == limit {
+			// The extra row only tells there is another page
+			page.NextCursor = pageCursor{Sort: sort.String(), Value: lastValue, Key: page.Items[limit-1].TruckID}.encode()
+			break
+		}
+		if err := json.Unmarshal(resources, &truck.AvailableResources); err != nil {
+			return page, fmt.Errorf("failed to parse truck resources: %w", err)
+		}
+		if err := json.Unmarshal(travelTime, &truck.TravelTimeToArea); err != nil {
+			return page, fmt.Errorf("failed to parse truck travel times: %w", err)
+		}
+		truck.ShiftStart, truck.ShiftEnd = shiftStart.String, shiftEnd.String
+		page.Items = append(page.Items, truck)
+		lastValue = sortValue
+	}
+
+	return page, rows.Err()
+}
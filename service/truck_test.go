@@ -0,0 +1,84 @@
+package service
+
+import (
+	"testing"
+	"time"
+	"workship-disaster-api/models"
+)
+
+func shift(t *testing.T, start, end string, location *time.Location) *ShiftWindow {
+	t.Helper()
+	window, err := ParseShiftWindow(start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	window.Location = location
+	return window
+}
+
+func TestDeparture(t *testing.T) {
+	bangkok, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		t.Skip("no time zone data:", err)
+	}
+	nextDay := func(hour int) *time.Time {
+		t := time.Date(2025, 1, 2, hour, 0, 0, 0, time.UTC)
+		return &t
+	}
+
+	tests := []struct {
+		name   string
+		truck  TruckData
+		travel time.Duration
+		want   *time.Time
+	}{
+		{"available leaves at plan start", TruckData{Status: models.TruckStatusAvailable}, time.Hour, at(0)},
+		{"availableFrom delays the departure", TruckData{Status: models.TruckStatusAvailable, AvailableFrom: at(2 * time.Hour)}, time.Hour, at(2 * time.Hour)},
+		{"past availableFrom is ignored", TruckData{Status: models.TruckStatusAvailable, AvailableFrom: at(-time.Hour)}, time.Hour, at(0)},
+		{"busy without availableFrom can't go", TruckData{Status: models.TruckStatusBusy}, time.Hour, nil},
+		{"busy leaves once free", TruckData{Status: models.TruckStatusBusy, AvailableFrom: at(30 * time.Minute)}, time.Hour, at(30 * time.Minute)},
+		{"maintenance can't go", TruckData{Status: models.TruckStatusMaintenance, AvailableFrom: at(0)}, time.Hour, nil},
+		{"offline can't go", TruckData{Status: models.TruckStatusOffline}, time.Hour, nil},
+		{"inside the shift", TruckData{Status: models.TruckStatusAvailable, Shift: shift(t, "08:00", "16:00", nil)}, time.Hour, at(0)},
+		{"waits for the shift to start", TruckData{Status: models.TruckStatusAvailable, Shift: shift(t, "13:00", "21:00", nil)}, time.Hour, at(time.Hour)},
+		{"trip past the shift end waits for tomorrow", TruckData{Status: models.TruckStatusAvailable, Shift: shift(t, "08:00", "12:30", nil)}, time.Hour, nextDay(8)},
+		{"overnight shift from yesterday", TruckData{Status: models.TruckStatusAvailable, Shift: shift(t, "20:00", "13:30", nil)}, time.Hour, at(0)},
+		{"trip longer than the shift", TruckData{Status: models.TruckStatusAvailable, Shift: shift(t, "08:00", "10:00", nil)}, 3 * time.Hour, nil},
+		{"shift and availableFrom combine", TruckData{Status: models.TruckStatusBusy, AvailableFrom: at(3 * time.Hour), Shift: shift(t, "08:00", "16:00", nil)}, 2 * time.Hour, nextDay(8)},
+		// 08:00-16:00 in Bangkok is 01:00-09:00 UTC, so 12:00 UTC is after the shift
+		{"shift in its own time zone", TruckData{Status: models.TruckStatusAvailable, Shift: shift(t, "08:00", "16:00", bangkok)}, time.Hour, nextDay(1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			depart, ok := tt.truck.Departure(planStart, tt.travel)
+			if tt.want == nil {
+				if ok {
+					t.Errorf("departs at %v, want no departure", depart)
+				}
+				return
+			}
+			if !ok || !depart.Equal(*tt.want) {
+				t.Errorf("departs at %v (ok %v), want %v", depart, ok, *tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadShiftLocation(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		t.Setenv("SHIFT_TIMEZONE", "")
+		location, err := LoadShiftLocation()
+		if err != nil {
+			t.Skip("no time zone data:", err)
+		}
+		if location.String() != "Asia/Bangkok" {
+			t.Errorf("location %v, want Asia/Bangkok", location)
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		t.Setenv("SHIFT_TIMEZONE", "Mars/Olympus")
+		if _, err := LoadShiftLocation(); err == nil {
+			t.Error("want an error for an unknown zone")
+		}
+	})
+}
@@ -0,0 +1,489 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	mathrand "math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+	"workship-disaster-api/events"
+	"workship-disaster-api/models"
+
+	"github.com/lib/pq"
+)
+
+// ErrWebhookNotFound is returned when a subscription or delivery doesn't exist
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// Signature headers sent with every delivery
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// deactivatedError is the last_error of pending deliveries closed because their subscription was deactivated
+const deactivatedError = "subscription deactivated"
+
+// WebhookConfig controls outbox delivery
+type WebhookConfig struct {
+	MaxAttempts  int
+	Timeout      time.Duration
+	PollInterval time.Duration
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	BatchSize    int
+}
+
+// LoadWebhookConfig reads WEBHOOK_MAX_ATTEMPTS and WEBHOOK_TIMEOUT
+func LoadWebhookConfig() (WebhookConfig, error) {
+	cfg := WebhookConfig{
+		MaxAttempts:  8,
+		Timeout:      10 * time.Second,
+		PollInterval: 5 * time.Second,
+		BaseBackoff:  30 * time.Second,
+		MaxBackoff:   time.Hour,
+		BatchSize:    20,
+	}
+
+	if raw := os.Getenv("WEBHOOK_MAX_ATTEMPTS"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 1 {
+			return cfg, fmt.Errorf("invalid WEBHOOK_MAX_ATTEMPTS: %q", raw)
+		}
+		cfg.MaxAttempts = value
+	}
+
+	if raw := os.Getenv("WEBHOOK_TIMEOUT"); raw != "" {
+		value, err := time.ParseDuration(raw)
+		if err != nil || value <= 0 {
+			return cfg, fmt.Errorf("invalid WEBHOOK_TIMEOUT: %q", raw)
+		}
+		cfg.Timeout = value
+	}
+
+	return cfg, nil
+}
+
+// WebhookService manages subscriptions and delivers the outbox, it is also the publisher's sink
+type WebhookService struct {
+	db     *sql.DB
+	client *http.Client
+	config WebhookConfig
+	logger *slog.Logger
+}
+
+func NewWebhookService(db *sql.DB, config WebhookConfig, logger *slog.Logger) *WebhookService {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &WebhookService{
+		db: db,
+		// Redirects aren't followed, the signed payload only goes to the registered URL and a 3xx
+		// counts as a failed attempt
+		client: &http.Client{
+			Timeout: config.Timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		config: config,
+		logger: logger.With(slog.String("component", "webhooks")),
+	}
+}
+
+// Enqueue writes one pending delivery per active subscription interested in the event, in the
+// transaction of the change so a delivery is queued exactly when the change commits
+func (s *WebhookService) Enqueue(ctx context.Context, tx events.Execer, event events.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload)
+		SELECT id, $1, $2, $3 FROM webhook_subscriptions
+		WHERE active AND ($2 = ANY(event_types) OR '*' = ANY(event_types))`,
+		event.ID, event.Type, payload,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue webhook deliveries: %w", err)
+	}
+
+	return nil
+}
+
+// CreateSubscription stores a subscription with its audit entry and returns it with its secret
+func (s *WebhookService) CreateSubscription(ctx context.Context, req models.CreateWebhookRequest) (*models.WebhookSubscription, error) {
+	secret := req.Secret
+	if secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+		}
+		secret = hex.EncodeToString(buf)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+	defer tx.Rollback()
+
+	subscription, err := scanSubscription(tx.QueryRowContext(ctx,
+		"INSERT INTO webhook_subscriptions (url, secret, event_types, description) VALUES ($1, $2, $3, $4) RETURNING "+subscriptionColumns,
+		req.URL, secret, pq.Array(req.EventTypes), req.Description,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+	// The audit trail gets the subscription without its signing secret
+	if err := RecordAudit(ctx, tx, AuditCreate, "webhook", strconv.FormatInt(subscription.ID, 10), nil, subscription); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	subscription.Secret = secret
+	return subscription, nil
+}
+
+// ListSubscriptions fetches every subscription without secrets
+func (s *WebhookService) ListSubscriptions() ([]models.WebhookSubscription, error) {
+	rows, err := s.db.Query("SELECT id, url, event_types, description, active, created_at, updated_at FROM webhook_subscriptions ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	subscriptions := []models.WebhookSubscription{}
+	for rows.Next() {
+		var subscription models.WebhookSubscription
+		if err := rows.Scan(&subscription.ID, &subscription.URL, pq.Array(&subscription.EventTypes), &subscription.Description, &subscription.Active, &subscription.CreatedAt, &subscription.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to parse webhook: %w", err)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	return subscriptions, rows.Err()
+}
+
+// subscriptionColumns are read by scanSubscription, the secret is never selected
+const subscriptionColumns = "id, url, event_types, description, active, created_at, updated_at"
+
+func scanSubscription(row *sql.Row) (*models.WebhookSubscription, error) {
+	var subscription models.WebhookSubscription
+	err := row.Scan(&subscription.ID, &subscription.URL, pq.Array(&subscription.EventTypes), &subscription.Description, &subscription.Active, &subscription.CreatedAt, &subscription.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// GetSubscription fetches a subscription without its secret
+func (s *WebhookService) GetSubscription(id int64) (*models.WebhookSubscription, error) {
+	subscription, err := scanSubscription(s.db.QueryRow("SELECT "+subscriptionColumns+" FROM webhook_subscriptions WHERE id = $1", id))
+	if err != nil && !errors.Is(err, ErrWebhookNotFound) {
+		return nil, fmt.Errorf("failed to fetch webhook: %w", err)
+	}
+	return subscription, err
+}
+
+// UpdateSubscription replaces the URL, event types, description and active flag and audits the change,
+// deactivating closes the subscription's pending deliveries as dead
+func (s *WebhookService) UpdateSubscription(ctx context.Context, id int64, req models.UpdateWebhookRequest) (*models.WebhookSubscription, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update webhook: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := scanSubscription(tx.QueryRowContext(ctx, "SELECT "+subscriptionColumns+" FROM webhook_subscriptions WHERE id = $1 FOR UPDATE", id))
+	if err != nil {
+		return nil, wrapWebhookError(err, "failed to update webhook")
+	}
+	subscription, err := scanSubscription(tx.QueryRowContext(ctx,
+		"UPDATE webhook_subscriptions SET url = $2, event_types = $3, description = $4, active = $5, updated_at = CURRENT_TIMESTAMP WHERE id = $1 RETURNING "+subscriptionColumns,
+		id, req.URL, pq.Array(req.EventTypes), req.Description, *req.Active,
+	))
+	if err != nil {
+		return nil, wrapWebhookError(err, "failed to update webhook")
+	}
+	if before.Active && !subscription.Active {
+		if err := closeInactiveDeliveries(ctx, tx); err != nil {
+			return nil, err
+		}
+	}
+	if err := RecordAudit(ctx, tx, AuditUpdate, "webhook", strconv.FormatInt(id, 10), before, subscription); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to update webhook: %w", err)
+	}
+
+	return subscription, nil
+}
+
+// DeleteSubscription removes a subscription and its queued deliveries and audits the removal
+func (s *WebhookService) DeleteSubscription(ctx context.Context, id int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := scanSubscription(tx.QueryRowContext(ctx, "DELETE FROM webhook_subscriptions WHERE id = $1 RETURNING "+subscriptionColumns, id))
+	if err != nil {
+		return wrapWebhookError(err, "failed to delete webhook")
+	}
+	if err := RecordAudit(ctx, tx, AuditDelete, "webhook", strconv.FormatInt(id, 10), before, nil); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	return nil
+}
+
+// wrapWebhookError keeps ErrWebhookNotFound as is and adds msg to anything else
+func wrapWebhookError(err error, msg string) error {
+	if errors.Is(err, ErrWebhookNotFound) {
+		return err
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+// ListDeliveries fetches the latest deliveries, optionally filtered by status, e.g. "dead"
+func (s *WebhookService) ListDeliveries(status string, limit int) ([]models.WebhookDelivery, error) {
+	rows, err := s.db.Query(`
+		SELECT id, subscription_id, event_id, event_type, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at
+		FROM webhook_deliveries
+		WHERE $1 = '' OR status = $1
+		ORDER BY id DESC
+		LIMIT $2`, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		if err := rows.Scan(&delivery.ID, &delivery.SubscriptionID, &delivery.EventID, &delivery.EventType, &delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt, &delivery.LastStatusCode, &delivery.LastError, &delivery.CreatedAt, &delivery.DeliveredAt); err != nil {
+			return nil, fmt.Errorf("failed to parse webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// RetryDelivery puts a dead delivery back in the queue with a fresh attempt budget and audits the retry
+func (s *WebhookService) RetryDelivery(ctx context.Context, id int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to retry webhook delivery: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		"UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = CURRENT_TIMESTAMP WHERE id = $1 AND status = 'dead'", id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to retry webhook delivery: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrWebhookNotFound
+	}
+	if err := RecordAudit(ctx, tx, AuditUpdate, "webhook_delivery", strconv.FormatInt(id, 10),
+		map[string]string{"status": models.DeliveryStatusDead}, map[string]string{"status": models.DeliveryStatusPending},
+	); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to retry webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+// Run delivers due outbox rows until ctx is done, several replicas can run it side by side
+func (s *WebhookService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.deliverDue(ctx); err != nil && ctx.Err() == nil {
+				s.logger.Error("webhook delivery run failed", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+type dueDelivery struct {
+	id       int64
+	attempts int
+	url      string
+	secret   string
+	event    string
+	payload  []byte
+}
+
+func (s *WebhookService) deliverDue(ctx context.Context) error {
+	// The claim below skips inactive subscriptions, close their deliveries instead of leaving them
+	// pending, e.g. ones retried or enqueued while the subscription was being deactivated
+	if err := closeInactiveDeliveries(ctx, s.db); err != nil {
+		return err
+	}
+
+	// Claim a batch by pushing next_attempt_at past the HTTP timeout, so no other replica picks it up
+	lease := s.config.Timeout*2 + time.Minute
+	rows, err := s.db.QueryContext(ctx, `
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = CURRENT_TIMESTAMP + $1 * INTERVAL '1 second'
+		FROM webhook_subscriptions ws
+		WHERE ws.id = d.subscription_id
+		AND d.id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= CURRENT_TIMESTAMP
+			ORDER BY next_attempt_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		AND ws.active
+		RETURNING d.id, d.attempts, ws.url, ws.secret, d.event_type, d.payload`,
+		lease.Seconds(), s.config.BatchSize,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+
+	var due []dueDelivery
+	for rows.Next() {
+		var delivery dueDelivery
+		if err := rows.Scan(&delivery.id, &delivery.attempts, &delivery.url, &delivery.secret, &delivery.event, &delivery.payload); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to parse webhook delivery: %w", err)
+		}
+		due = append(due, delivery)
+	}
+	rows.Close()
+
+	for _, delivery := range due {
+		statusCode, sendErr := s.send(ctx, delivery)
+		if err := s.recordAttempt(ctx, delivery, statusCode, sendErr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// closeInactiveDeliveries marks the pending deliveries of inactive subscriptions dead, they can be
+// retried once the subscription is active again
+func closeInactiveDeliveries(ctx context.Context, tx events.Execer) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE webhook_deliveries d
+		SET status = 'dead', last_error = $1
+		FROM webhook_subscriptions ws
+		WHERE ws.id = d.subscription_id AND NOT ws.active AND d.status = 'pending'`,
+		deactivatedError,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to close deliveries of inactive webhooks: %w", err)
+	}
+	return nil
+}
+
+func (s *WebhookService) send(ctx context.Context, delivery dueDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.url, bytes.NewReader(delivery.payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, delivery.event)
+	req.Header.Set(WebhookDeliveryHeader, strconv.FormatInt(delivery.id, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(delivery.secret, time.Now(), delivery.payload))
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("endpoint responded %s", res.Status)
+	}
+	return res.StatusCode, nil
+}
+
+func (s *WebhookService) recordAttempt(ctx context.Context, delivery dueDelivery, statusCode int, sendErr error) error {
+	var code interface{}
+	if statusCode != 0 {
+		code = statusCode
+	}
+
+	var err error
+	attempts := delivery.attempts + 1
+	switch {
+	case sendErr == nil:
+		_, err = s.db.ExecContext(ctx,
+			"UPDATE webhook_deliveries SET status = 'delivered', attempts = $2, last_status_code = $3, last_error = NULL, delivered_at = CURRENT_TIMESTAMP WHERE id = $1",
+			delivery.id, attempts, code)
+	case attempts >= s.config.MaxAttempts:
+		_, err = s.db.ExecContext(ctx,
+			"UPDATE webhook_deliveries SET status = 'dead', attempts = $2, last_status_code = $3, last_error = $4 WHERE id = $1",
+			delivery.id, attempts, code, sendErr.Error())
+	default:
+		_, err = s.db.ExecContext(ctx,
+			"UPDATE webhook_deliveries SET attempts = $2, last_status_code = $3, last_error = $4, next_attempt_at = CURRENT_TIMESTAMP + $5 * INTERVAL '1 second' WHERE id = $1",
+			delivery.id, attempts, code, sendErr.Error(), s.backoff(attempts).Seconds())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record webhook attempt: %w", err)
+	}
+
+	return nil
+}
+
+// backoff doubles the wait after every failed attempt, with up to 20% jitter
+func (s *WebhookService) backoff(attempts int) time.Duration {
+	wait := float64(s.config.BaseBackoff) * math.Pow(2, float64(attempts-1))
+	wait = math.Min(wait, float64(s.config.MaxBackoff))
+	wait += wait * 0.2 * mathrand.Float64()
+	return time.Duration(wait)
+}
+
+// SignWebhook returns the signature header value "t=<unix>,v1=<hex hmac-sha256 of "<unix>.<body>">".
+// Receivers recompute it with their secret and reject stale timestamps.
+func SignWebhook(secret string, at time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return fmt.Sprintf("t=%s,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"workship-disaster-api/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// TestDeactivatingClosesPendingDeliveries checks the pending deliveries of a deactivated subscription
+// are marked dead in the same transaction, the claim query would otherwise skip them forever
+func TestDeactivatingClosesPendingDeliveries(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	service := NewWebhookService(db, WebhookConfig{Timeout: time.Second}, nil)
+
+	columns := []string{"id", "url", "event_types", "description", "active", "created_at", "updated_at"}
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT .* FROM webhook_subscriptions WHERE id = \\$1 FOR UPDATE").WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(7, "https://example.com/hook", "{*}", "", true, now, now))
+	mock.ExpectQuery("UPDATE webhook_subscriptions SET").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(7, "https://example.com/hook", "{*}", "", false, now, now))
+	mock.ExpectExec("UPDATE webhook_deliveries d SET status = 'dead', last_error = \\$1 .* NOT ws.active AND d.status = 'pending'").
+		WithArgs(deactivatedError).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("INSERT INTO audit_events").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	active := false
+	subscription, err := service.UpdateSubscription(context.Background(), 7, models.UpdateWebhookRequest{
+		URL: "https://example.com/hook", EventTypes: []string{"*"}, Active: &active,
+	})
+	if err != nil {
+		t.Fatalf("UpdateSubscription: %v", err)
+	}
+	if subscription.Active {
+		t.Error("subscription is still active")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestSendDoesNotFollowRedirects checks a redirect fails the attempt without posting the signed payload elsewhere
+func TestSendDoesNotFollowRedirects(t *testing.T) {
+	redirected := false
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirected = true
+	}))
+	defer target.Close()
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusTemporaryRedirect)
+	}))
+	defer endpoint.Close()
+
+	service := NewWebhookService(nil, WebhookConfig{Timeout: time.Second}, nil)
+	status, err := service.send(context.Background(), dueDelivery{id: 1, url: endpoint.URL, secret: "s", event: "area.created", payload: []byte(`{}`)})
+	if status != http.StatusTemporaryRedirect || err == nil {
+		t.Errorf("send = %d, %v, want a failed 307 attempt", status, err)
+	}
+	if redirected {
+		t.Error("the redirect was followed")
+	}
+}
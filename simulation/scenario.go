@@ -0,0 +1,196 @@
+// Package simulation replays a disaster response offline. A scenario describes the trucks,
+// the areas and a timeline of events, the simulator runs the assignment engine at every tick
+// and measures how quickly and how completely demand is met.
+package simulation
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Event types a scenario timeline may contain
+const (
+	EventAreaAppears    = "area_appears"
+	EventUrgency        = "urgency_changes"
+	EventTruckBreakdown = "truck_breakdown"
+	EventRoadClosed     = "road_closed"
+)
+
+// DefaultStart is the simulated clock's start when the scenario doesn't set one, fixed so runs repeat
+var DefaultStart = time.Date(2025, 1, 1, 6, 0, 0, 0, time.UTC)
+
+// Scenario is a simulated disaster, durations are Go durations such as "90m" or "2h"
+type Scenario struct {
+	Name string `yaml:"name"`
+	// Seed drives travel time jitter and random breakdowns, the CLI -seed flag overrides it
+	Seed     uint64        `yaml:"seed"`
+	Start    time.Time     `yaml:"start"`
+	Duration time.Duration `yaml:"duration"`
+	Tick     time.Duration `yaml:"tick"`
+	Priority *Weights      `yaml:"priority"`
+	Random   Randomness    `yaml:"random"`
+	Trucks   []Truck       `yaml:"trucks"`
+	Areas    []Area        `yaml:"areas"`
+	Events   []Event       `yaml:"events"`
+}
+
+// Weights override the priority weights of the assignment engine, to compare strategies
+type Weights struct {
+	Urgency       *float64 `yaml:"urgency"`
+	Population    *float64 `yaml:"population"`
+	Vulnerable    *float64 `yaml:"vulnerable"`
+	TimeRemaining *float64 `yaml:"timeRemaining"`
+	Waiting       *float64 `yaml:"waiting"`
+}
+
+// Randomness adds seeded noise to the timeline
+type Randomness struct {
+	// TravelJitter varies actual travel times by up to this fraction either way, e.g. 0.2
+	TravelJitter float64 `yaml:"travelJitter"`
+	// BreakdownsPerHour is the chance per truck and hour of an unplanned breakdown
+	BreakdownsPerHour float64 `yaml:"breakdownsPerHour"`
+	// RepairTime is how long a random breakdown lasts
+	RepairTime time.Duration `yaml:"repairTime"`
+}
+
+// Truck starts at the depot, TravelMinutes are one-way times from the depot to each area
+type Truck struct {
+	ID            string         `yaml:"id"`
+	Resources     map[string]int `yaml:"resources"`
+	TravelMinutes map[string]int `yaml:"travelMinutes"`
+	// Shift is an optional daily driver shift, "HH:MM-HH:MM"
+	Shift string `yaml:"shift"`
+}
+
+// Area needs Resources within Deadline of appearing
+type Area struct {
+	ID               string         `yaml:"id"`
+	Urgency          int            `yaml:"urgency"`
+	Resources        map[string]int `yaml:"resources"`
+	Deadline         time.Duration  `yaml:"deadline"`
+	Population       int            `yaml:"population"`
+	VulnerableGroups map[string]int `yaml:"vulnerableGroups"`
+}
+
+// Event happens At after the start. Which fields apply depends on Type:
+//   - area_appears: Area
+//   - urgency_changes: AreaID and Urgency
+//   - truck_breakdown: TruckID, and For when the truck gets repaired
+//   - road_closed: AreaID, TruckID to close one truck's route only, For when it reopens
+type Event struct {
+	At      time.Duration `yaml:"at"`
+	Type    string        `yaml:"type"`
+	Area    *Area         `yaml:"area"`
+	AreaID  string        `yaml:"areaId"`
+	TruckID string        `yaml:"truckId"`
+	Urgency int           `yaml:"urgency"`
+	For     time.Duration `yaml:"for"`
+}
+
+// LoadScenario reads a YAML or JSON scenario file
+func LoadScenario(path string) (*Scenario, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// JSON is valid YAML, so one decoder reads both
+	var scenario Scenario
+	if err := yaml.Unmarshal(raw, &scenario); err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %w", path, err)
+	}
+	if err := scenario.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %w", path, err)
+	}
+	return &scenario, nil
+}
+
+// Validate checks the scenario is complete and every event refers to a truck or area that exists by then
+func (s *Scenario) Validate() error {
+	if s.Duration <= 0 {
+		return errors.New("duration must be positive")
+	}
+	if s.Tick <= 0 || s.Tick > s.Duration {
+		return errors.New("tick must be positive and no longer than duration")
+	}
+	if s.Random.TravelJitter < 0 || s.Random.TravelJitter >= 1 {
+		return errors.New("random.travelJitter must be at least 0 and below 1")
+	}
+	if s.Random.BreakdownsPerHour < 0 || (s.Random.BreakdownsPerHour > 0 && s.Random.RepairTime <= 0) {
+		return errors.New("random.breakdownsPerHour must not be negative and needs a positive random.repairTime")
+	}
+
+	trucks := map[string]bool{}
+	for i, truck := range s.Trucks {
+		if truck.ID == "" || trucks[truck.ID] {
+			return fmt.Errorf("trucks[%d]: missing or duplicate id %q", i, truck.ID)
+		}
+		trucks[truck.ID] = true
+	}
+
+	areas := map[string]bool{}
+	addArea := func(field string, area *Area) error {
+		if area.ID == "" || areas[area.ID] {
+			return fmt.Errorf("%s: missing or duplicate id %q", field, area.ID)
+		}
+		if area.Urgency < 1 || area.Urgency > 5 {
+			return fmt.Errorf("%s: urgency must be between 1 and 5", field)
+		}
+		if area.Deadline <= 0 {
+			return fmt.Errorf("%s: deadline must be positive", field)
+		}
+		areas[area.ID] = true
+		return nil
+	}
+	for i := range s.Areas {
+		if err := addArea(fmt.Sprintf("areas[%d]", i), &s.Areas[i]); err != nil {
+			return err
+		}
+	}
+
+	var last time.Duration
+	for i, event := range s.Events {
+		field := fmt.Sprintf("events[%d]", i)
+		if event.At < last {
+			return fmt.Errorf("%s: events must be in time order", field)
+		}
+		last = event.At
+
+		switch event.Type {
+		case EventAreaAppears:
+			if event.Area == nil {
+				return fmt.Errorf("%s: %s needs an area", field, event.Type)
+			}
+			if err := addArea(field+".area", event.Area); err != nil {
+				return err
+			}
+		case EventUrgency:
+			if !areas[event.AreaID] {
+				return fmt.Errorf("%s: unknown areaId %q", field, event.AreaID)
+			}
+			if event.Urgency < 1 || event.Urgency > 5 {
+				return fmt.Errorf("%s: urgency must be between 1 and 5", field)
+			}
+		case EventTruckBreakdown:
+			if !trucks[event.TruckID] {
+				return fmt.Errorf("%s: unknown truckId %q", field, event.TruckID)
+			}
+		case EventRoadClosed:
+			if !areas[event.AreaID] {
+				return fmt.Errorf("%s: unknown areaId %q", field, event.AreaID)
+			}
+			if event.TruckID != "" && !trucks[event.TruckID] {
+				return fmt.Errorf("%s: unknown truckId %q", field, event.TruckID)
+			}
+		default:
+			return fmt.Errorf("%s: unknown type %q, expected %s, %s, %s or %s", field, event.Type, EventAreaAppears, EventUrgency, EventTruckBreakdown, EventRoadClosed)
+		}
+		if event.For < 0 {
+			return fmt.Errorf("%s: for must not be negative", field)
+		}
+	}
+	return nil
+}
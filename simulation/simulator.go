@@ -0,0 +1,491 @@
+package simulation
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"time"
+	"workship-disaster-api/models"
+	"workship-disaster-api/service"
+)
+
+// Kinds of entries in the simulation log
+const (
+	LogAreaAppeared = "area_appeared"
+	LogUrgency      = "urgency_changed"
+	LogBreakdown    = "breakdown"
+	LogRepaired     = "repaired"
+	LogRoadClosed   = "road_closed"
+	LogRoadReopened = "road_reopened"
+	LogDispatched   = "dispatched"
+	LogDelivered    = "delivered"
+	LogTripAborted  = "trip_aborted"
+)
+
+// Result is the outcome of a simulation run
+type Result struct {
+	Scenario string        `json:"scenario"`
+	Seed     uint64        `json:"seed"`
+	Start    time.Time     `json:"start"`
+	End      time.Time     `json:"end"`
+	Metrics  Metrics       `json:"metrics"`
+	Areas    []AreaOutcome `json:"areas"`
+	Timeline []Sample      `json:"timeline"`
+	Log      []LogEntry    `json:"log"`
+}
+
+// Metrics summarise a run, lateness counts unserved areas as late until the end of the run
+type Metrics struct {
+	AreasServed                    int     `json:"areasServed"`
+	AreasUnserved                  int     `json:"areasUnserved"`
+	AverageResponseMinutes         float64 `json:"averageResponseMinutes"`
+	MaxResponseMinutes             float64 `json:"maxResponseMinutes"`
+	LateAreas                      int     `json:"lateAreas"`
+	UrgencyWeightedLatenessMinutes float64 `json:"urgencyWeightedLatenessMinutes"`
+	UnmetUnits                     int     `json:"unmetUnits"`
+	Breakdowns                     int     `json:"breakdowns"`
+}
+
+// AreaOutcome is what happened to one area
+type AreaOutcome struct {
+	AreaID          string     `json:"areaId"`
+	Urgency         int        `json:"urgency"`
+	AppearedAt      time.Time  `json:"appearedAt"`
+	Deadline        time.Time  `json:"deadline"`
+	TruckID         string     `json:"truckId,omitempty"`
+	DeliveredAt     *time.Time `json:"deliveredAt,omitempty"`
+	ResponseMinutes *float64   `json:"responseMinutes,omitempty"`
+	LateMinutes     float64    `json:"lateMinutes"`
+}
+
+// Sample is the state of the response at one tick
+type Sample struct {
+	At           time.Time `json:"at"`
+	UnmetUnits   int       `json:"unmetUnits"`
+	WaitingAreas int       `json:"waitingAreas"`
+	EnRoute      int       `json:"enRoute"`
+	Returning    int       `json:"returning"`
+	AtDepot      int       `json:"atDepot"`
+	BrokenDown   int       `json:"brokenDown"`
+}
+
+// LogEntry is one thing that happened during the run
+type LogEntry struct {
+	At      time.Time `json:"at"`
+	Kind    string    `json:"kind"`
+	TruckID string    `json:"truckId,omitempty"`
+	AreaID  string    `json:"areaId,omitempty"`
+	Detail  string    `json:"detail,omitempty"`
+}
+
+type areaState struct {
+	Area
+	appearedAt  time.Time
+	deadline    time.Time
+	truckID     string
+	deliveredAt *time.Time
+}
+
+// trip is a truck's run from the depot to an area, the truck drives back after delivering
+type trip struct {
+	area   *areaState
+	depart time.Time
+	arrive time.Time
+	travel time.Duration
+}
+
+type truckState struct {
+	Truck
+	stock    map[string]int
+	shift    *service.ShiftWindow
+	trip     *trip
+	returnAt time.Time
+	broken   bool
+	// repairAt is when a broken truck is back, zero when it never is
+	repairAt time.Time
+}
+
+// road closes the route of one truck, or of every truck when truckID is empty
+type road struct {
+	truckID, areaID string
+}
+
+type simulator struct {
+	scenario *Scenario
+	priority service.PriorityConfig
+	rng      *rand.Rand
+	now      time.Time
+	areas    []*areaState
+	areaByID map[string]*areaState
+	trucks   []*truckState
+	truckBy  map[string]*truckState
+	// closed roads and when they reopen, zero when they stay closed
+	closed map[road]time.Time
+	result *Result
+}
+
+// Run simulates the scenario. Trucks are dispatched from the depot by the same assignment engine
+// as the API, committed trips are not replanned, and trucks drive back to the depot after delivering.
+// The same scenario, seed and priority config always produce the same result.
+func Run(scenario *Scenario, seed uint64, priority service.PriorityConfig) (*Result, error) {
+	if err := scenario.Validate(); err != nil {
+		return nil, err
+	}
+	if w := scenario.Priority; w != nil {
+		for target, value := range map[*float64]*float64{
+			&priority.UrgencyWeight:       w.Urgency,
+			&priority.PopulationWeight:    w.Population,
+			&priority.VulnerableWeight:    w.Vulnerable,
+			&priority.TimeRemainingWeight: w.TimeRemaining,
+			&priority.WaitingWeight:       w.Waiting,
+		} {
+			if value != nil {
+				*target = *value
+			}
+		}
+	}
+
+	start := scenario.Start
+	if start.IsZero() {
+		start = DefaultStart
+	}
+	s := &simulator{
+		scenario: scenario,
+		priority: priority,
+		rng:      rand.New(rand.NewPCG(seed, seed)),
+		now:      start,
+		areaByID: map[string]*areaState{},
+		truckBy:  map[string]*truckState{},
+		closed:   map[road]time.Time{},
+		result: &Result{
+			Scenario: scenario.Name,
+			Seed:     seed,
+			Start:    start,
+			End:      start.Add(scenario.Duration),
+			Areas:    []AreaOutcome{},
+			Timeline: []Sample{},
+			Log:      []LogEntry{},
+		},
+	}
+
+	for _, truck := range scenario.Trucks {
+		state := &truckState{Truck: truck, stock: map[string]int{}}
+		for resource, units := range truck.Resources {
+			state.stock[resource] = units
+		}
+		if truck.Shift != "" {
+			from, to, ok := strings.Cut(truck.Shift, "-")
+			if !ok {
+				return nil, fmt.Errorf("truck %s: invalid shift %q, expected HH:MM-HH:MM", truck.ID, truck.Shift)
+			}
+			shift, err := service.ParseShiftWindow(from, to)
+			if err != nil {
+				return nil, fmt.Errorf("truck %s: %w", truck.ID, err)
+			}
+			state.shift = shift
+		}
+		s.trucks = append(s.trucks, state)
+		s.truckBy[truck.ID] = state
+	}
+	for _, area := range scenario.Areas {
+		s.addArea(area, start)
+	}
+
+	next := 0
+	for t := start; !t.After(s.result.End); t = t.Add(scenario.Tick) {
+		s.now = t
+		for ; next < len(scenario.Events) && !start.Add(scenario.Events[next].At).After(t); next++ {
+			s.apply(scenario.Events[next], start.Add(scenario.Events[next].At))
+		}
+		s.restore()
+		s.randomBreakdowns()
+		s.advance()
+		s.dispatch()
+		s.sample()
+	}
+
+	s.finish()
+	return s.result, nil
+}
+
+func (s *simulator) log(at time.Time, kind, truckID, areaID, detail string) {
+	s.result.Log = append(s.result.Log, LogEntry{At: at, Kind: kind, TruckID: truckID, AreaID: areaID, Detail: detail})
+}
+
+func (s *simulator) addArea(area Area, at time.Time) {
+	state := &areaState{Area: area, appearedAt: at, deadline: at.Add(area.Deadline)}
+	s.areas = append(s.areas, state)
+	s.areaByID[area.ID] = state
+	s.log(at, LogAreaAppeared, "", area.ID, fmt.Sprintf("urgency %d, deadline %s", area.Urgency, state.deadline.Format(time.RFC3339)))
+}
+
+// apply runs a timeline event, at is when it was scheduled
+func (s *simulator) apply(event Event, at time.Time) {
+	switch event.Type {
+	case EventAreaAppears:
+		s.addArea(*event.Area, at)
+	case EventUrgency:
+		area := s.areaByID[event.AreaID]
+		s.log(at, LogUrgency, "", area.ID, fmt.Sprintf("%d to %d", area.Urgency, event.Urgency))
+		area.Urgency = event.Urgency
+	case EventTruckBreakdown:
+		s.breakdown(s.truckBy[event.TruckID], at, event.For)
+	case EventRoadClosed:
+		var reopen time.Time
+		if event.For > 0 {
+			reopen = at.Add(event.For)
+		}
+		s.closed[road{event.TruckID, event.AreaID}] = reopen
+		s.log(at, LogRoadClosed, event.TruckID, event.AreaID, untilDetail(reopen))
+	}
+}
+
+// breakdown stops a truck for repair, or for good when repair is zero. A truck on the road is
+// delayed by the repair, a truck that never recovers leaves its area to be planned again.
+func (s *simulator) breakdown(truck *truckState, at time.Time, repair time.Duration) {
+	if truck.broken {
+		return
+	}
+	s.result.Metrics.Breakdowns++
+	truck.broken = true
+	truck.repairAt = time.Time{}
+	if repair > 0 {
+		truck.repairAt = at.Add(repair)
+	}
+	s.log(at, LogBreakdown, truck.ID, "", untilDetail(truck.repairAt))
+
+	switch {
+	case truck.trip != nil && repair > 0:
+		truck.trip.arrive = truck.trip.arrive.Add(repair)
+	case truck.trip != nil:
+		area := truck.trip.area
+		area.truckID = ""
+		truck.trip = nil
+		s.log(at, LogTripAborted, truck.ID, area.ID, "the truck can't be repaired")
+	case truck.returnAt.After(at) && repair > 0:
+		truck.returnAt = truck.returnAt.Add(repair)
+	}
+}
+
+// restore repairs trucks and reopens roads whose time has come
+func (s *simulator) restore() {
+	for _, truck := range s.trucks {
+		if truck.broken && !truck.repairAt.IsZero() && !truck.repairAt.After(s.now) {
+			truck.broken = false
+			s.log(truck.repairAt, LogRepaired, truck.ID, "", "")
+		}
+	}
+	for _, r := range sortedRoads(s.closed) {
+		if reopen := s.closed[r]; !reopen.IsZero() && !reopen.After(s.now) {
+			delete(s.closed, r)
+			s.log(reopen, LogRoadReopened, r.truckID, r.areaID, "")
+		}
+	}
+}
+
+// randomBreakdowns breaks each working truck down with the configured hourly chance
+func (s *simulator) randomBreakdowns() {
+	rate := s.scenario.Random.BreakdownsPerHour
+	if rate == 0 {
+		return
+	}
+	chance := 1 - math.Exp(-rate*s.scenario.Tick.Hours())
+	for _, truck := range s.trucks {
+		if !truck.broken && s.rng.Float64() < chance {
+			s.breakdown(truck, s.now, s.scenario.Random.RepairTime)
+		}
+	}
+}
+
+// advance completes the deliveries that arrived since the last tick
+func (s *simulator) advance() {
+	for _, truck := range s.trucks {
+		trip := truck.trip
+		if trip == nil || trip.arrive.After(s.now) {
+			continue
+		}
+		arrived := trip.arrive
+		trip.area.deliveredAt = &arrived
+		for resource, units := range trip.area.Resources {
+			truck.stock[resource] -= units
+		}
+		truck.returnAt = arrived.Add(trip.travel)
+		truck.trip = nil
+		s.log(arrived, LogDelivered, truck.ID, trip.area.ID, "")
+	}
+}
+
+// dispatch runs the assignment engine over the waiting areas and the trucks at the depot
+func (s *simulator) dispatch() {
+	var open []service.AreaData
+	for _, area := range s.areas {
+		if area.truckID != "" || area.deliveredAt != nil {
+			continue
+		}
+		deadline := area.deadline
+		open = append(open, service.AreaData{
+			ID:               area.ID,
+			RequiredResource: area.Resources,
+			Urgency:          area.Urgency,
+			TimeConstraint:   int(math.Max(0, deadline.Sub(s.now).Minutes())),
+			LatestArrival:    &deadline,
+			Population:       area.Population,
+			VulnerableGroups: area.VulnerableGroups,
+			CreatedAt:        area.appearedAt,
+		})
+	}
+
+	var free []service.TruckData
+	for _, truck := range s.trucks {
+		if truck.broken || truck.trip != nil || truck.returnAt.After(s.now) {
+			continue
+		}
+		travel := map[string]int{}
+		for areaID, minutes := range truck.TravelMinutes {
+			if !s.isClosed(truck.ID, areaID) {
+				travel[areaID] = minutes
+			}
+		}
+		free = append(free, service.TruckData{
+			ID:                 truck.ID,
+			AvailableResources: truck.stock,
+			TravelTimeToArea:   travel,
+			Status:             models.TruckStatusAvailable,
+			Shift:              truck.shift,
+		})
+	}
+	if len(open) == 0 || len(free) == 0 {
+		return
+	}
+
+	for _, assignment := range service.BuildAssignments(open, free, s.now, s.priority) {
+		if assignment.TruckID == "" {
+			continue
+		}
+		truck, area := s.truckBy[assignment.TruckID], s.areaByID[assignment.AreaID]
+		travel := s.jitter(time.Duration(truck.TravelMinutes[area.ID]) * time.Minute)
+		depart := *assignment.DepartureTime
+		truck.trip = &trip{area: area, depart: depart, arrive: depart.Add(travel), travel: travel}
+		area.truckID = truck.ID
+		s.log(depart, LogDispatched, truck.ID, area.ID, fmt.Sprintf("planned arrival %s, actual %s",
+			assignment.EstimatedArrival.Format(time.RFC3339), truck.trip.arrive.Format(time.RFC3339)))
+	}
+}
+
+func (s *simulator) isClosed(truckID, areaID string) bool {
+	_, all := s.closed[road{"", areaID}]
+	_, one := s.closed[road{truckID, areaID}]
+	return all || one
+}
+
+// jitter varies a travel time by up to the configured fraction, the planner only sees the plain time
+func (s *simulator) jitter(travel time.Duration) time.Duration {
+	j := s.scenario.Random.TravelJitter
+	if j == 0 {
+		return travel
+	}
+	factor := 1 + (s.rng.Float64()*2-1)*j
+	return time.Duration(float64(travel) * factor).Round(time.Second)
+}
+
+func (s *simulator) sample() {
+	sample := Sample{At: s.now}
+	for _, area := range s.areas {
+		if area.deliveredAt != nil {
+			continue
+		}
+		if area.truckID == "" {
+			sample.WaitingAreas++
+		}
+		for _, units := range area.Resources {
+			sample.UnmetUnits += units
+		}
+	}
+	for _, truck := range s.trucks {
+		switch {
+		case truck.broken:
+			sample.BrokenDown++
+		case truck.trip != nil && !truck.trip.depart.After(s.now):
+			sample.EnRoute++
+		case truck.returnAt.After(s.now):
+			sample.Returning++
+		default:
+			sample.AtDepot++
+		}
+	}
+	s.result.Timeline = append(s.result.Timeline, sample)
+}
+
+// finish works out each area's outcome and the run metrics
+func (s *simulator) finish() {
+	m := &s.result.Metrics
+	var totalResponse float64
+	for _, area := range s.areas {
+		outcome := AreaOutcome{
+			AreaID:     area.ID,
+			Urgency:    area.Urgency,
+			AppearedAt: area.appearedAt,
+			Deadline:   area.deadline,
+			TruckID:    area.truckID,
+		}
+
+		end := s.result.End
+		if area.deliveredAt != nil {
+			end = *area.deliveredAt
+			response := roundMinutes(end.Sub(area.appearedAt))
+			outcome.DeliveredAt, outcome.ResponseMinutes = area.deliveredAt, &response
+			m.AreasServed++
+			totalResponse += response
+			m.MaxResponseMinutes = math.Max(m.MaxResponseMinutes, response)
+		} else {
+			m.AreasUnserved++
+			for _, units := range area.Resources {
+				m.UnmetUnits += units
+			}
+		}
+
+		if late := end.Sub(area.deadline); late > 0 {
+			outcome.LateMinutes = roundMinutes(late)
+			m.LateAreas++
+			m.UrgencyWeightedLatenessMinutes += float64(area.Urgency) * outcome.LateMinutes
+		}
+		s.result.Areas = append(s.result.Areas, outcome)
+	}
+	if m.AreasServed > 0 {
+		m.AverageResponseMinutes = roundMinutes(time.Duration(totalResponse / float64(m.AreasServed) * float64(time.Minute)))
+	}
+	m.UrgencyWeightedLatenessMinutes = math.Round(m.UrgencyWeightedLatenessMinutes*10) / 10
+
+	// Entries are logged as ticks find them, order them by when they happened
+	sort.SliceStable(s.result.Log, func(i, j int) bool {
+		return s.result.Log[i].At.Before(s.result.Log[j].At)
+	})
+}
+
+func untilDetail(until time.Time) string {
+	if until.IsZero() {
+		return "until the end of the run"
+	}
+	return "until " + until.Format(time.RFC3339)
+}
+
+// sortedRoads keeps reopen log entries in a stable order
+func sortedRoads(closed map[road]time.Time) []road {
+	roads := make([]road, 0, len(closed))
+	for r := range closed {
+		roads = append(roads, r)
+	}
+	sort.Slice(roads, func(i, j int) bool {
+		if roads[i].areaID != roads[j].areaID {
+			return roads[i].areaID < roads[j].areaID
+		}
+		return roads[i].truckID < roads[j].truckID
+	})
+	return roads
+}
+
+func roundMinutes(d time.Duration) float64 {
+	return math.Round(d.Minutes()*10) / 10
+}
@@ -0,0 +1,29 @@
+package simulation
+
+import (
+	"reflect"
+	"testing"
+	"workship-disaster-api/service"
+)
+
+// TestRunIsReproducible checks the same scenario and seed replay to the same result,
+// 0 included since the CLI accepts it as an explicit seed
+func TestRunIsReproducible(t *testing.T) {
+	for _, seed := range []uint64{0, 42} {
+		var results []*Result
+		for i := 0; i < 2; i++ {
+			scenario, err := LoadScenario("example.yaml")
+			if err != nil {
+				t.Fatalf("LoadScenario: %v", err)
+			}
+			result, err := Run(scenario, seed, service.DefaultPriorityConfig())
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			results = append(results, result)
+		}
+		if !reflect.DeepEqual(results[0], results[1]) {
+			t.Errorf("seed %d gave two different results", seed)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Package tracing sets up OpenTelemetry tracing: HTTP requests, SQL statements,
+// Redis commands and planner phases become spans of one trace per request or planner run.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName is the default service.name, OTEL_SERVICE_NAME overrides it
+const ServiceName = "workship-disaster-api"
+
+// Exporters
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+)
+
+// Config selects where spans go
+type Config struct {
+	// Exporter is none, otlp, stdout or file. otlp is configured by the standard
+	// OTEL_EXPORTER_OTLP_* variables and sampling by OTEL_TRACES_SAMPLER.
+	Exporter string
+	// File receives one JSON span per line when Exporter is file
+	File string
+}
+
+// LoadConfig reads TRACING_EXPORTER and TRACING_FILE
+func LoadConfig() (Config, error) {
+	cfg := Config{
+		Exporter: strings.ToLower(os.Getenv("TRACING_EXPORTER")),
+		File:     os.Getenv("TRACING_FILE"),
+	}
+	if cfg.Exporter == "" {
+		cfg.Exporter = ExporterNone
+	}
+
+	switch cfg.Exporter {
+	case ExporterNone, ExporterOTLP, ExporterStdout:
+	case ExporterFile:
+		if cfg.File == "" {
+			return cfg, fmt.Errorf("TRACING_FILE is required when TRACING_EXPORTER is file")
+		}
+	default:
+		return cfg, fmt.Errorf("invalid TRACING_EXPORTER: %q, expected none, otlp, stdout or file", cfg.Exporter)
+	}
+
+	return cfg, nil
+}
+
+// Setup installs the global tracer provider and the W3C traceparent and baggage propagators.
+// The returned shutdown flushes buffered spans, call it before exiting.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	// Incoming traceparent headers are honoured even when spans aren't exported
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var closer io.Closer
+	var err error
+	switch cfg.Exporter {
+	case ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterFile:
+		var file *os.File
+		file, err = os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err == nil {
+			closer = file
+			exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %w", cfg.Exporter, err)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(ServiceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			closer.Close()
+		}
+		return err
+	}, nil
+}
+
+// Start begins a span as a child of any span in ctx, end it with End
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(ServiceName).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// End records err, if any, on span and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
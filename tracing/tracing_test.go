@@ -0,0 +1,102 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans routes spans to an in-memory recorder for the rest of the test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+// TestRedisHook checks commands become child spans named after the command, a missing key
+// isn't an error and keys and values stay out of the span
+func TestRedisHook(t *testing.T) {
+	recorder := recordSpans(t)
+	server := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	rdb.AddHook(RedisHook{})
+	defer rdb.Close()
+
+	ctx, parent := Start(context.Background(), "request")
+	rdb.Set(ctx, "api-key:secret-value", "token", 0)
+	rdb.Get(ctx, "missing")
+	rdb.Incr(ctx, "api-key:secret-value")
+	End(parent, nil)
+
+	spans := recorder.Ended()
+	if len(spans) != 4 {
+		t.Fatalf("recorded %d spans, want three commands and their parent", len(spans))
+	}
+	tests := []struct {
+		name   string
+		status codes.Code
+	}{
+		{"redis SET", codes.Unset},
+		{"redis GET", codes.Unset},
+		{"redis INCR", codes.Error},
+	}
+	for i, tt := range tests {
+		span := spans[i]
+		if span.Name() != tt.name || span.Status().Code != tt.status {
+			t.Errorf("span %d = %s %v, want %s %v", i, span.Name(), span.Status().Code, tt.name, tt.status)
+		}
+		if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("%s is not a child of the request span", span.Name())
+		}
+		for _, attr := range span.Attributes() {
+			if value := attr.Value.Emit(); value == "api-key:secret-value" || value == "token" {
+				t.Errorf("%s carries %s = %s", span.Name(), attr.Key, value)
+			}
+		}
+	}
+}
+
+func TestEndRecordsError(t *testing.T) {
+	recorder := recordSpans(t)
+
+	_, span := Start(context.Background(), "PlannerService.Replan")
+	End(span, errors.New("lock not available"))
+
+	ended := recorder.Ended()
+	if len(ended) != 1 || ended[0].Status().Code != codes.Error || ended[0].Status().Description != "lock not available" {
+		t.Fatalf("ended %+v, want one span with the error status", ended)
+	}
+	if events := ended[0].Events(); len(events) != 1 || events[0].Name != "exception" {
+		t.Errorf("events = %+v, want the recorded error", events)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		exporter, file string
+		wantErr        bool
+	}{
+		{"", "", false},
+		{"OTLP", "", false},
+		{"file", "spans.json", false},
+		{"file", "", true},
+		{"jaeger", "", true},
+	}
+	for _, tt := range tests {
+		t.Setenv("TRACING_EXPORTER", tt.exporter)
+		t.Setenv("TRACING_FILE", tt.file)
+		if _, err := LoadConfig(); (err != nil) != tt.wantErr {
+			t.Errorf("LoadConfig(%q, %q) error = %v, want error %v", tt.exporter, tt.file, err, tt.wantErr)
+		}
+	}
+}